@@ -1,15 +1,42 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
+	"html/template"
 	"log"
 	"net/http"
+	"time"
 
+	"github.com/adettelle/go-url-shortener/internal/analytics"
 	"github.com/adettelle/go-url-shortener/internal/api"
+	"github.com/adettelle/go-url-shortener/internal/apikey"
+	"github.com/adettelle/go-url-shortener/internal/auth"
+	"github.com/adettelle/go-url-shortener/internal/authz"
+	"github.com/adettelle/go-url-shortener/internal/clientip"
 	"github.com/adettelle/go-url-shortener/internal/config"
+	"github.com/adettelle/go-url-shortener/internal/events"
+	"github.com/adettelle/go-url-shortener/internal/linkcheck"
+	"github.com/adettelle/go-url-shortener/internal/metadata"
 	"github.com/adettelle/go-url-shortener/internal/mware"
+	"github.com/adettelle/go-url-shortener/internal/oidc"
+	"github.com/adettelle/go-url-shortener/internal/policy"
+	"github.com/adettelle/go-url-shortener/internal/safehttp"
+	"github.com/adettelle/go-url-shortener/internal/session"
 	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/adettelle/go-url-shortener/internal/targeting"
+	"github.com/adettelle/go-url-shortener/internal/unlock"
+	"github.com/adettelle/go-url-shortener/internal/webhook"
+	"github.com/adettelle/go-url-shortener/internal/workspace"
 	"github.com/go-chi/chi/v5"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// unlockAttempts wrong passwords per link are allowed within unlockWindow.
+const (
+	unlockAttempts = 5
+	unlockWindow   = 15 * time.Minute
 )
 
 func main() {
@@ -27,13 +54,193 @@ func run() error {
 	log.Println("Config:", cfg)
 	addressStorage := storage.New()
 
-	handlers := api.New(addressStorage, cfg)
+	ips, err := clientip.New(cfg.TrustedProxies)
+	if err != nil {
+		return err
+	}
+
+	clickStore, err := newClickStore(cfg)
+	if err != nil {
+		return err
+	}
+	clickRecorder := analytics.NewRecorder(clickStore, cfg.AnalyticsBufferSize,
+		cfg.AnalyticsBatchSize, cfg.AnalyticsFlushInterval)
+	go clickRecorder.Run(context.Background())
+
+	opts := []api.Option{
+		api.WithClientIP(ips),
+		api.WithClickRecorder(clickRecorder, cfg.AnalyticsSalt),
+		api.WithStats(clickStore),
+		api.WithUnlock(unlock.NewSigner([]byte(cfg.CookieSecret), cfg.UnlockTTL),
+			unlock.NewThrottle(unlockAttempts, unlockWindow)),
+	}
+	if len(cfg.AllowlistFiles) > 0 || len(cfg.DenylistFiles) > 0 {
+		urlPolicy, err := policy.New(cfg.AllowlistFiles, cfg.DenylistFiles)
+		if err != nil {
+			return err
+		}
+		go urlPolicy.Watch(context.Background(), cfg.PolicyReloadInterval)
+		opts = append(opts, api.WithPolicy(urlPolicy))
+	}
+
+	if cfg.ComingSoonPage != "" {
+		page, err := template.ParseFiles(cfg.ComingSoonPage)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, api.WithComingSoonPage(page))
+	}
+
+	if cfg.GeoIPDatabase != "" {
+		geo, err := targeting.OpenGeoIPDB(cfg.GeoIPDatabase)
+		if err != nil {
+			return err
+		}
+		defer geo.Close()
+		opts = append(opts, api.WithGeoIP(geo))
+	}
+
+	apiKeys := apikey.NewStore()
+	if cfg.AdminAPIKey != "" {
+		if err := apiKeys.Bootstrap(cfg.AdminAPIKey, []string{auth.ScopeAdmin}); err != nil {
+			return err
+		}
+	}
+	opts = append(opts, api.WithAPIKeys(apiKeys))
+
+	// без OIDC bearer-токены проверяются только как API-ключи
+	var tokens mware.TokenVerifier
+	var sessions mware.SessionReader
+	if cfg.OIDCIssuer != "" {
+		oidcClient, err := oidc.New(context.Background(), oidc.Config{
+			Issuer:       cfg.OIDCIssuer,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Scopes:       cfg.OIDCScopes,
+			Audience:     cfg.OIDCAudience,
+		})
+		if err != nil {
+			return err
+		}
+		sessionStore := session.NewStore(cfg.SessionTTL)
+		tokens, sessions = oidcClient, sessionStore
+		opts = append(opts, api.WithLogin(oidcClient, sessionStore))
+	}
+
+	opts = append(opts, api.WithWorkspaces(workspace.NewStore()))
+
+	if !cfg.MetadataDisabled {
+		refresher := metadata.NewRefresher(metadata.NewFetcher(cfg.MetadataTimeout, cfg.MetadataMaxBytes),
+			addressStorage, cfg.MetadataWorkers, cfg.MetadataMaxAge)
+		go refresher.Run(context.Background())
+		opts = append(opts, api.WithMetadata(refresher))
+	}
+
+	bus := events.NewBus()
+	webhooks := webhook.NewDispatcher(webhook.NewStore(), safehttp.NewClient(cfg.WebhookTimeout, nil),
+		cfg.WebhookMaxAttempts, cfg.WebhookRetryDelay, cfg.WebhookMaxRetryDelay, cfg.WebhookConcurrency)
+	go webhooks.Run(context.Background(), bus.Subscribe(cfg.EventsBufferSize))
+	opts = append(opts, api.WithEvents(bus), api.WithWebhooks(webhooks))
+
+	if !cfg.LinkCheckDisabled {
+		var notify *linkcheck.Webhook
+		if cfg.LinkCheckWebhook != "" {
+			notify = linkcheck.NewWebhook(cfg.LinkCheckWebhook, cfg.LinkCheckTimeout)
+		}
+		onBroken := func(link *storage.Link) {
+			bus.Publish(linkcheck.BrokenEvent(link))
+			if notify != nil {
+				notify.LinkBroken(link)
+			}
+		}
+		checker := linkcheck.NewChecker(safehttp.NewClient(cfg.LinkCheckTimeout, nil), addressStorage,
+			cfg.LinkCheckInterval, cfg.LinkCheckConcurrency, cfg.LinkCheckHostDelay, onBroken)
+		go checker.Run(context.Background())
+	}
+
+	// роли уже проверены в config.New
+	anonymousRole, _ := authz.ParseRole(cfg.AnonymousRole)
+	userRole, _ := authz.ParseRole(cfg.UserRole)
+	opts = append(opts, api.WithAccessPolicy(authz.New(anonymousRole, userRole, cfg.AdminUsers)))
+
+	handlers := api.New(addressStorage, cfg, opts...)
+	createLimiter := mware.NewRateLimiter(cfg.CreateRateLimit, cfg.CreateRateBurst, ips)
+	redirectLimiter := mware.NewRateLimiter(cfg.RedirectRateLimit, cfg.RedirectRateBurst, ips)
+
+	// scoped проверяет API-ключ, JWT или сессию; без REQUIRE_API_KEY анонимные запросы
+	// разрешены везде, кроме удаления и управления ключами
+	keyAuth := mware.Authenticate(apiKeys, tokens, sessions)
+	scoped := func(scope string, h http.HandlerFunc) http.HandlerFunc {
+		anonymous := !cfg.RequireAPIKey && scope != auth.ScopeDelete && scope != auth.ScopeAdmin
+		return mware.WithLogging(keyAuth(mware.RequireScope(scope, anonymous)(h)))
+	}
 
 	r := chi.NewRouter()
-	r.Post("/", mware.WithLogging(handlers.CreateShortAddressPlainText))
-	r.Get("/{id}", mware.WithLogging(handlers.GetFullAddress))
-	r.Post("/api/shorten", mware.WithLogging(handlers.CreateShortAddressJSON))
+	r.Post("/", scoped(auth.ScopeCreate, createLimiter.Limit(handlers.CreateShortAddressPlainText)))
+	r.Get("/{id}", mware.WithLogging(redirectLimiter.Limit(handlers.GetFullAddress)))
+	r.Head("/{id}", mware.WithLogging(redirectLimiter.Limit(handlers.GetFullAddress)))
+	r.Post("/{id}", mware.WithLogging(redirectLimiter.Limit(handlers.UnlockLink)))
+	r.Get("/{id}/qr", mware.WithLogging(redirectLimiter.Limit(handlers.GetQRCode)))
+	r.Post("/api/shorten", scoped(auth.ScopeCreate, createLimiter.Limit(handlers.CreateShortAddressJSON)))
+	r.Get("/api/links", scoped(auth.ScopeRead, handlers.ListLinks))
+	r.Get("/api/links/search", scoped(auth.ScopeRead, handlers.SearchLinks))
+	r.Get("/api/links/broken", scoped(auth.ScopeRead, handlers.ListBrokenLinks))
+	r.Get("/api/links/{id}", scoped(auth.ScopeRead, handlers.GetLink))
+	r.Patch("/api/links/{id}", scoped(auth.ScopeCreate, createLimiter.Limit(handlers.UpdateLink)))
+	r.Delete("/api/links/{id}", scoped(auth.ScopeDelete, handlers.DeleteLink))
+	r.Get("/api/links/{id}/stats", scoped(auth.ScopeRead, handlers.GetLinkStats))
+	r.Get("/api/links/{id}/history", scoped(auth.ScopeRead, handlers.GetLinkHistory))
+	r.Post("/api/links/{id}/rollback/{rev}", scoped(auth.ScopeCreate, createLimiter.Limit(handlers.RollbackLink)))
+	r.Put("/api/links/{id}/labels", scoped(auth.ScopeCreate, handlers.SetLinkLabels))
+	r.Get("/api/tags", scoped(auth.ScopeRead, handlers.ListTags))
+	r.Post("/api/tags/rename", scoped(auth.ScopeCreate, handlers.RenameTag))
+	r.Post("/api/tags/merge", scoped(auth.ScopeCreate, handlers.MergeTags))
+	r.Post("/api/admin/keys", scoped(auth.ScopeAdmin, handlers.IssueAPIKey))
+	r.Get("/api/admin/keys", scoped(auth.ScopeAdmin, handlers.ListAPIKeys))
+	r.Delete("/api/admin/keys/{keyID}", scoped(auth.ScopeAdmin, handlers.RevokeAPIKey))
+	r.Post("/api/workspaces", scoped(auth.ScopeCreate, handlers.CreateWorkspace))
+	r.Get("/api/workspaces", scoped(auth.ScopeRead, handlers.ListWorkspaces))
+	r.Get("/api/workspaces/{workspaceID}", scoped(auth.ScopeRead, handlers.GetWorkspace))
+	r.Get("/api/workspaces/{workspaceID}/stats", scoped(auth.ScopeRead, handlers.GetWorkspaceStats))
+	r.Put("/api/workspaces/{workspaceID}/members/{member}", scoped(auth.ScopeCreate, handlers.SetWorkspaceMember))
+	r.Delete("/api/workspaces/{workspaceID}/members/{member}", scoped(auth.ScopeCreate, handlers.RemoveWorkspaceMember))
+	r.Post("/api/webhooks", scoped(auth.ScopeCreate, handlers.CreateWebhook))
+	r.Get("/api/webhooks", scoped(auth.ScopeRead, handlers.ListWebhooks))
+	r.Delete("/api/webhooks/{webhookID}", scoped(auth.ScopeCreate, handlers.DeleteWebhook))
+	r.Get("/api/webhooks/{webhookID}/deliveries", scoped(auth.ScopeRead, handlers.ListWebhookDeliveries))
+	r.Get("/api/webhooks/{webhookID}/dead-letters", scoped(auth.ScopeRead, handlers.ListWebhookDeadLetters))
+	r.Post("/api/webhooks/{webhookID}/dead-letters/{deliveryID}/retry",
+		scoped(auth.ScopeCreate, handlers.RetryWebhookDelivery))
+	r.Get("/auth/login", mware.WithLogging(handlers.Login))
+	r.Get("/auth/callback", mware.WithLogging(handlers.LoginCallback))
+	r.Post("/auth/logout", mware.WithLogging(handlers.Logout))
+	r.Get("/auth/me", mware.WithLogging(keyAuth(handlers.Me)))
 
 	fmt.Printf("Starting server on port %s\n", cfg.Address)
 	return http.ListenAndServe(cfg.Address, r)
 }
+
+// clickStore both saves clicks and computes reports over them.
+type clickStore interface {
+	analytics.Store
+	analytics.StatsStore
+}
+
+// newClickStore returns a PostgreSQL store when a database is configured
+// and an in-memory store otherwise.
+func newClickStore(cfg *config.Config) (clickStore, error) {
+	if cfg.DatabaseDSN == "" {
+		return analytics.NewMemoryStore(), nil
+	}
+
+	db, err := sql.Open("pgx", cfg.DatabaseDSN)
+	if err != nil {
+		return nil, err
+	}
+	store := analytics.NewSQLStore(db)
+	if err := store.Init(context.Background()); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
@@ -0,0 +1,33 @@
+// Package analytics collects redirect events ("clicks") and stores them
+// for later reporting. Recording never blocks the redirect itself:
+// clicks go through a buffered Recorder that writes them in batches.
+package analytics
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Click is a single redirect of a short link.
+type Click struct {
+	Time      time.Time
+	ShortID   string
+	Referrer  string
+	UserAgent string
+	UAFamily  string // see UserAgentFamily
+	IPHash    string // salted hash of the client address, the address itself is never stored
+	Variant   string // A/B variant that was served; empty for links without variants
+}
+
+// Store persists clicks.
+type Store interface {
+	SaveClicks(ctx context.Context, clicks []Click) error
+}
+
+// HashIP returns a salted SHA-256 of the client address.
+func HashIP(salt, ip string) string {
+	sum := sha256.Sum256([]byte(salt + "|" + ip))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,110 @@
+package analytics
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps clicks in memory. It is used when no database is configured.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	clicks []Click
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (s *MemoryStore) SaveClicks(_ context.Context, clicks []Click) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clicks = append(s.clicks, clicks...)
+	return nil
+}
+
+// Clicks returns a copy of all stored clicks.
+func (s *MemoryStore) Clicks() []Click {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]Click(nil), s.clicks...)
+}
+
+func (s *MemoryStore) Stats(_ context.Context, q StatsQuery) (*Stats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := &Stats{}
+	visitors := make(map[string]struct{})
+	perDay := make(map[time.Time]int)
+	perHour := make(map[time.Time]int)
+	referrers := make(map[string]int)
+	agents := make(map[string]int)
+	variants := make(map[string]int)
+	links := make(map[string]bool)
+	for _, id := range q.shortIDs() {
+		links[id] = true
+	}
+
+	for _, c := range s.clicks {
+		if !links[c.ShortID] || c.Time.Before(q.From) || !c.Time.Before(q.To) {
+			continue
+		}
+		stats.TotalClicks++
+		visitors[c.IPHash] = struct{}{}
+
+		t := c.Time.UTC()
+		perDay[t.Truncate(24*time.Hour)]++
+		perHour[t.Truncate(time.Hour)]++
+
+		referrer := c.Referrer
+		if referrer == "" {
+			referrer = DirectReferrer
+		}
+		referrers[referrer]++
+		agents[c.UAFamily]++
+		if c.Variant != "" {
+			variants[c.Variant]++
+		}
+	}
+
+	stats.UniqueVisitors = len(visitors)
+	stats.PerDay = sortedBuckets(perDay)
+	stats.PerHour = sortedBuckets(perHour)
+	stats.TopReferrers = topCounts(referrers, q.Top)
+	stats.TopUserAgents = topCounts(agents, q.Top)
+	stats.Variants = topCounts(variants, 0)
+	return stats, nil
+}
+
+func sortedBuckets(m map[time.Time]int) []Bucket {
+	buckets := make([]Bucket, 0, len(m))
+	for start, clicks := range m {
+		buckets = append(buckets, Bucket{Start: start, Clicks: clicks})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].Start.Before(buckets[j].Start)
+	})
+	return buckets
+}
+
+// topCounts returns the n most frequent values, ties are ordered by value.
+func topCounts(m map[string]int, n int) []Count {
+	counts := make([]Count, 0, len(m))
+	for value, clicks := range m {
+		counts = append(counts, Count{Value: value, Clicks: clicks})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Clicks != counts[j].Clicks {
+			return counts[i].Clicks > counts[j].Clicks
+		}
+		return counts[i].Value < counts[j].Value
+	})
+	if n > 0 && len(counts) > n {
+		counts = counts[:n]
+	}
+	return counts
+}
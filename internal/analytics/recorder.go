@@ -0,0 +1,99 @@
+package analytics
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Recorder buffers clicks and writes them to a Store in batches from a
+// single background goroutine started with Run.
+type Recorder struct {
+	store         Store
+	clicks        chan Click
+	batchSize     int
+	flushInterval time.Duration
+
+	dropped       atomic.Uint64
+	reportedDrops uint64
+}
+
+// NewRecorder creates a Recorder with a buffer of bufferSize clicks.
+// A batch is written when it reaches batchSize or every flushInterval.
+func NewRecorder(store Store, bufferSize, batchSize int, flushInterval time.Duration) *Recorder {
+	return &Recorder{
+		store:         store,
+		clicks:        make(chan Click, bufferSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+	}
+}
+
+// Record queues c without blocking. If the buffer is full the click is
+// dropped and counted; Record then returns false.
+func (r *Recorder) Record(c Click) bool {
+	select {
+	case r.clicks <- c:
+		return true
+	default:
+		r.dropped.Add(1)
+		return false
+	}
+}
+
+// Dropped returns the number of clicks lost because the buffer was full.
+func (r *Recorder) Dropped() uint64 {
+	return r.dropped.Load()
+}
+
+// Run writes queued clicks until ctx is cancelled. Clicks still in the
+// buffer at that moment are flushed before Run returns.
+func (r *Recorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Click, 0, r.batchSize)
+	for {
+		select {
+		case c := <-r.clicks:
+			batch = append(batch, c)
+			if len(batch) >= r.batchSize {
+				batch = r.flush(batch)
+			}
+		case <-ticker.C:
+			batch = r.flush(batch)
+		case <-ctx.Done():
+			for {
+				select {
+				case c := <-r.clicks:
+					batch = append(batch, c)
+				default:
+					r.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *Recorder) flush(batch []Click) []Click {
+	if dropped := r.Dropped(); dropped != r.reportedDrops {
+		logger.Logger.Warn("clicks dropped, analytics buffer is full",
+			zap.Uint64("dropped", dropped-r.reportedDrops))
+		r.reportedDrops = dropped
+	}
+	if len(batch) == 0 {
+		return batch
+	}
+
+	// не даём медленной базе задерживать следующий батч бесконечно
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := r.store.SaveClicks(ctx, batch); err != nil {
+		logger.Logger.Error("error in saving clicks", zap.Error(err), zap.Int("count", len(batch)))
+	}
+	return batch[:0]
+}
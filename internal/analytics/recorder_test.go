@@ -0,0 +1,82 @@
+package analytics
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// batchStore remembers the size of every batch it receives.
+type batchStore struct {
+	mu      sync.Mutex
+	batches []int
+	clicks  []Click
+}
+
+func (s *batchStore) SaveClicks(_ context.Context, clicks []Click) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, len(clicks))
+	s.clicks = append(s.clicks, clicks...)
+	return nil
+}
+
+func (s *batchStore) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clicks)
+}
+
+func TestRecorderBatches(t *testing.T) {
+	store := &batchStore{}
+	rec := NewRecorder(store, 10, 3, time.Hour)
+
+	for i := 0; i < 7; i++ {
+		require.True(t, rec.Record(Click{ShortID: "abc"}))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		rec.Run(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool { return store.count() >= 6 }, time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	require.Equal(t, 7, store.count())
+	require.Equal(t, []int{3, 3, 1}, store.batches)
+}
+
+func TestRecorderFlushInterval(t *testing.T) {
+	store := &batchStore{}
+	rec := NewRecorder(store, 10, 100, 10*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go rec.Run(ctx)
+
+	rec.Record(Click{ShortID: "abc"})
+	require.Eventually(t, func() bool { return store.count() == 1 }, time.Second, time.Millisecond)
+}
+
+func TestRecorderDropsWhenFull(t *testing.T) {
+	rec := NewRecorder(NewMemoryStore(), 2, 10, time.Hour)
+
+	require.True(t, rec.Record(Click{}))
+	require.True(t, rec.Record(Click{}))
+	require.False(t, rec.Record(Click{}))
+	require.False(t, rec.Record(Click{}))
+	require.Equal(t, uint64(2), rec.Dropped())
+}
+
+func TestHashIP(t *testing.T) {
+	require.Equal(t, HashIP("salt", "1.2.3.4"), HashIP("salt", "1.2.3.4"))
+	require.NotEqual(t, HashIP("salt", "1.2.3.4"), HashIP("other", "1.2.3.4"))
+	require.NotContains(t, HashIP("salt", "1.2.3.4"), "1.2.3.4")
+}
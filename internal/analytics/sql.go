@@ -0,0 +1,149 @@
+package analytics
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// SQLStore keeps clicks in a PostgreSQL table.
+type SQLStore struct {
+	db *sql.DB
+}
+
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+// Init creates the clicks table if it does not exist yet.
+func (s *SQLStore) Init(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `
+		create table if not exists clicks (
+			id bigserial primary key,
+			short_id text not null,
+			clicked_at timestamptz not null,
+			referrer text not null default '',
+			user_agent text not null default '',
+			ip_hash text not null default ''
+		);
+		alter table clicks add column if not exists ua_family text not null default '';
+		alter table clicks add column if not exists variant text not null default '';
+		create index if not exists clicks_short_id_clicked_at_idx on clicks (short_id, clicked_at);`)
+	return err
+}
+
+// SaveClicks inserts the batch in a single transaction.
+func (s *SQLStore) SaveClicks(ctx context.Context, clicks []Click) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		insert into clicks (short_id, clicked_at, referrer, user_agent, ip_hash, ua_family, variant)
+		values ($1, $2, $3, $4, $5, $6, $7)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, c := range clicks {
+		_, err = stmt.ExecContext(ctx, c.ShortID, c.Time, c.Referrer, c.UserAgent, c.IPHash, c.UAFamily, c.Variant)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Stats aggregates clicks in the database, only the results are transferred.
+func (s *SQLStore) Stats(ctx context.Context, q StatsQuery) (*Stats, error) {
+	stats := &Stats{}
+	const where = `where short_id = any($1) and clicked_at >= $2 and clicked_at < $3`
+
+	row := s.db.QueryRowContext(ctx,
+		`select count(*), count(distinct ip_hash) from clicks `+where, q.shortIDs(), q.From, q.To)
+	if err := row.Scan(&stats.TotalClicks, &stats.UniqueVisitors); err != nil {
+		return nil, err
+	}
+
+	var err error
+	stats.PerDay, err = s.buckets(ctx, "day", where, q)
+	if err != nil {
+		return nil, err
+	}
+	stats.PerHour, err = s.buckets(ctx, "hour", where, q)
+	if err != nil {
+		return nil, err
+	}
+	stats.TopReferrers, err = s.top(ctx, `coalesce(nullif(referrer, ''), '`+DirectReferrer+`')`, where, q)
+	if err != nil {
+		return nil, err
+	}
+	stats.TopUserAgents, err = s.top(ctx, "ua_family", where, q)
+	if err != nil {
+		return nil, err
+	}
+	// вариантов немного, поэтому возвращаем все
+	all := q
+	all.Top = 0
+	stats.Variants, err = s.top(ctx, "variant", where+` and variant <> ''`, all)
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func (s *SQLStore) buckets(ctx context.Context, unit, where string, q StatsQuery) ([]Bucket, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		select date_trunc('`+unit+`', clicked_at at time zone 'UTC') as bucket, count(*)
+		from clicks `+where+`
+		group by bucket order by bucket`, q.shortIDs(), q.From, q.To)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []Bucket
+	for rows.Next() {
+		var b Bucket
+		if err := rows.Scan(&b.Start, &b.Clicks); err != nil {
+			return nil, err
+		}
+		b.Start = time.Date(b.Start.Year(), b.Start.Month(), b.Start.Day(),
+			b.Start.Hour(), 0, 0, 0, time.UTC)
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
+
+func (s *SQLStore) top(ctx context.Context, column, where string, q StatsQuery) ([]Count, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		select `+column+` as value, count(*) as clicks
+		from clicks `+where+`
+		group by value order by clicks desc, value
+		limit $4`, q.shortIDs(), q.From, q.To, limit(q.Top))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []Count
+	for rows.Next() {
+		var c Count
+		if err := rows.Scan(&c.Value, &c.Clicks); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
+
+// limit converts a list size to a LIMIT argument; null means no limit.
+func limit(n int) any {
+	if n <= 0 {
+		return nil
+	}
+	return n
+}
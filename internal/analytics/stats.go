@@ -0,0 +1,58 @@
+package analytics
+
+import (
+	"context"
+	"time"
+)
+
+// DirectReferrer is reported for clicks without a Referer header.
+const DirectReferrer = "(direct)"
+
+// StatsQuery selects clicks of one link, or of several links together,
+// in the half-open range [From, To).
+type StatsQuery struct {
+	ShortID  string
+	ShortIDs []string // links reported together, used when ShortID is empty
+	From     time.Time
+	To       time.Time
+	Top      int // size of the top referrers and user agents lists
+}
+
+// shortIDs returns the links selected by the query.
+func (q StatsQuery) shortIDs() []string {
+	if q.ShortID != "" {
+		return []string{q.ShortID}
+	}
+	if q.ShortIDs == nil {
+		return []string{} // пустой массив, а не NULL в SQL
+	}
+	return q.ShortIDs
+}
+
+// Bucket is the number of clicks in an hour or a day starting at Start (UTC).
+type Bucket struct {
+	Start  time.Time
+	Clicks int
+}
+
+// Count is the number of clicks with the same value.
+type Count struct {
+	Value  string
+	Clicks int
+}
+
+// Stats is the aggregated report for a StatsQuery.
+type Stats struct {
+	TotalClicks    int
+	UniqueVisitors int
+	PerDay         []Bucket
+	PerHour        []Bucket
+	TopReferrers   []Count
+	TopUserAgents  []Count
+	Variants       []Count // clicks per A/B variant, all variants that got clicks
+}
+
+// StatsStore computes reports over stored clicks.
+type StatsStore interface {
+	Stats(ctx context.Context, q StatsQuery) (*Stats, error)
+}
@@ -0,0 +1,98 @@
+package analytics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreStats(t *testing.T) {
+	store := NewMemoryStore()
+	day := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	err := store.SaveClicks(context.Background(), []Click{
+		{Time: day.Add(9 * time.Hour), ShortID: "abc", IPHash: "a", UAFamily: "Chrome", Referrer: "https://t.me/"},
+		{Time: day.Add(9*time.Hour + 30*time.Minute), ShortID: "abc", IPHash: "a", UAFamily: "Chrome"},
+		{Time: day.Add(26 * time.Hour), ShortID: "abc", IPHash: "b", UAFamily: "Firefox", Referrer: "https://t.me/", Variant: "b"},
+		{Time: day.Add(27 * time.Hour), ShortID: "abc", IPHash: "c", UAFamily: "Safari", Referrer: "https://vk.com/", Variant: "a"},
+		{Time: day.Add(10 * time.Hour), ShortID: "other", IPHash: "a", UAFamily: "Chrome", Variant: "a"},
+		{Time: day.Add(-time.Hour), ShortID: "abc", IPHash: "d", UAFamily: "Chrome"},
+		{Time: day.Add(48 * time.Hour), ShortID: "abc", IPHash: "e", UAFamily: "Chrome"},
+	})
+	require.NoError(t, err)
+
+	stats, err := store.Stats(context.Background(), StatsQuery{
+		ShortID: "abc",
+		From:    day,
+		To:      day.Add(48 * time.Hour),
+		Top:     2,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, 4, stats.TotalClicks)
+	require.Equal(t, 3, stats.UniqueVisitors)
+	require.Equal(t, []Bucket{
+		{Start: day, Clicks: 2},
+		{Start: day.Add(24 * time.Hour), Clicks: 2},
+	}, stats.PerDay)
+	require.Equal(t, []Bucket{
+		{Start: day.Add(9 * time.Hour), Clicks: 2},
+		{Start: day.Add(26 * time.Hour), Clicks: 1},
+		{Start: day.Add(27 * time.Hour), Clicks: 1},
+	}, stats.PerHour)
+	require.Equal(t, []Count{
+		{Value: "https://t.me/", Clicks: 2},
+		{Value: DirectReferrer, Clicks: 1},
+	}, stats.TopReferrers)
+	require.Equal(t, []Count{
+		{Value: "Chrome", Clicks: 2},
+		{Value: "Firefox", Clicks: 1},
+	}, stats.TopUserAgents)
+	require.Equal(t, []Count{
+		{Value: "a", Clicks: 1},
+		{Value: "b", Clicks: 1},
+	}, stats.Variants)
+}
+
+func TestMemoryStoreStatsSeveralLinks(t *testing.T) {
+	store := NewMemoryStore()
+	day := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	err := store.SaveClicks(context.Background(), []Click{
+		{Time: day.Add(time.Hour), ShortID: "abc", IPHash: "a"},
+		{Time: day.Add(2 * time.Hour), ShortID: "def", IPHash: "a"},
+		{Time: day.Add(3 * time.Hour), ShortID: "def", IPHash: "b"},
+		{Time: day.Add(4 * time.Hour), ShortID: "other", IPHash: "c"},
+	})
+	require.NoError(t, err)
+
+	q := StatsQuery{ShortIDs: []string{"abc", "def"}, From: day, To: day.Add(24 * time.Hour), Top: 10}
+	stats, err := store.Stats(context.Background(), q)
+	require.NoError(t, err)
+	require.Equal(t, 3, stats.TotalClicks)
+	require.Equal(t, 2, stats.UniqueVisitors)
+
+	// пустой список ссылок не совпадает ни с чем
+	q.ShortIDs = nil
+	stats, err = store.Stats(context.Background(), q)
+	require.NoError(t, err)
+	require.Zero(t, stats.TotalClicks)
+}
+
+func TestUserAgentFamily(t *testing.T) {
+	tests := map[string]string{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36":           "Chrome",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36 Edg/120.0": "Edge",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_2) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15":    "Safari",
+		"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0":                                                "Firefox",
+		"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)":                                              "Bot",
+		"curl/8.4.0": "curl",
+		"":           "Unknown",
+		"Lynx/2.8.9": "Other",
+	}
+	for ua, want := range tests {
+		require.Equal(t, want, UserAgentFamily(ua), ua)
+	}
+}
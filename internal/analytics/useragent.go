@@ -0,0 +1,41 @@
+package analytics
+
+import "strings"
+
+// uaFamilies is checked in order, so more specific tokens go first:
+// Edge and Opera also send "Chrome", Chrome also sends "Safari".
+var uaFamilies = []struct {
+	token  string
+	family string
+}{
+	{"bot", "Bot"},
+	{"crawler", "Bot"},
+	{"spider", "Bot"},
+	{"curl/", "curl"},
+	{"wget/", "Wget"},
+	{"python-requests", "Python"},
+	{"go-http-client", "Go"},
+	{"edg/", "Edge"},
+	{"opr/", "Opera"},
+	{"samsungbrowser/", "Samsung Internet"},
+	{"yabrowser/", "Yandex Browser"},
+	{"firefox/", "Firefox"},
+	{"fxios/", "Firefox"},
+	{"crios/", "Chrome"},
+	{"chrome/", "Chrome"},
+	{"safari/", "Safari"},
+}
+
+// UserAgentFamily maps a User-Agent header to a coarse browser family.
+func UserAgentFamily(ua string) string {
+	if ua == "" {
+		return "Unknown"
+	}
+	ua = strings.ToLower(ua)
+	for _, f := range uaFamilies {
+		if strings.Contains(ua, f.token) {
+			return f.family
+		}
+	}
+	return "Other"
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/apikey"
+	"github.com/adettelle/go-url-shortener/internal/authz"
+	"go.uber.org/zap"
+)
+
+// APIKeyManager issues and revokes API keys.
+type APIKeyManager interface {
+	Issue(name string, scopes []string) (string, *apikey.Key, error)
+	List() []apikey.Key
+	Revoke(id string) error
+}
+
+// WithAPIKeys enables the /api/admin/keys endpoints.
+func WithAPIKeys(keys APIKeyManager) Option {
+	return func(h *Handlers) {
+		h.apiKeys = keys
+	}
+}
+
+type apiKeyCreateRequestDTO struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"` // create, read, delete, admin
+}
+
+type apiKeyDTO struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+type apiKeyCreateResponseDTO struct {
+	apiKeyDTO
+	Key string `json:"key"` // показывается только один раз
+}
+
+func toAPIKeyDTO(key *apikey.Key) apiKeyDTO {
+	dto := apiKeyDTO{
+		ID:        key.ID,
+		Name:      key.Name,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+	}
+	if !key.LastUsedAt.IsZero() {
+		lastUsed := key.LastUsedAt
+		dto.LastUsedAt = &lastUsed
+	}
+	return dto
+}
+
+// IssueAPIKey handles POST /api/admin/keys. The response is the only place
+// the plaintext key ever appears.
+func (h *Handlers) IssueAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorize(w, r, authz.ActionManageKeys, nil) {
+		return
+	}
+	if h.apiKeys == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	var requestBody apiKeyCreateRequestDTO
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		errlog.Error("error in unmarshalling json", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if requestBody.Name == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	token, key, err := h.apiKeys.Issue(requestBody.Name, requestBody.Scopes)
+	if err != nil {
+		var invalidScope *apikey.InvalidScopeError
+		if errors.As(err, &invalidScope) {
+			errlog.Info("invalid api key scopes", zap.Error(err))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		errlog.Error("error in issuing api key", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	errlog.Info("api key issued", zap.String("id", key.ID), zap.String("by", requestActor(r)),
+		zap.Strings("scopes", key.Scopes))
+	writeJSON(w, http.StatusCreated, apiKeyCreateResponseDTO{apiKeyDTO: toAPIKeyDTO(key), Key: token})
+}
+
+// ListAPIKeys handles GET /api/admin/keys.
+func (h *Handlers) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorize(w, r, authz.ActionManageKeys, nil) {
+		return
+	}
+	if h.apiKeys == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	keys := h.apiKeys.List()
+	dtos := make([]apiKeyDTO, 0, len(keys))
+	for i := range keys {
+		dtos = append(dtos, toAPIKeyDTO(&keys[i]))
+	}
+	writeJSON(w, http.StatusOK, dtos)
+}
+
+// RevokeAPIKey handles DELETE /api/admin/keys/{keyID}.
+func (h *Handlers) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorize(w, r, authz.ActionManageKeys, nil) {
+		return
+	}
+	if h.apiKeys == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	id := r.PathValue("keyID")
+	if err := h.apiKeys.Revoke(id); err != nil {
+		var noKey *apikey.NoKeyError
+		if errors.As(err, &noKey) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		errlog.Error("error in revoking api key", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	errlog.Info("api key revoked", zap.String("id", id), zap.String("by", requestActor(r)))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adettelle/go-url-shortener/internal/apikey"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyEndpoints(t *testing.T) {
+	keys := apikey.NewStore()
+	handlers := New(storage.New(), nil, WithAPIKeys(keys))
+
+	// выпуск
+	request := withPrincipal(httptest.NewRequest(http.MethodPost, "/api/admin/keys",
+		strings.NewReader(`{"name":"ci","scopes":["create","read"]}`)), adminKey)
+	response := httptest.NewRecorder()
+	handlers.IssueAPIKey(response, request)
+	require.Equal(t, http.StatusCreated, response.Code)
+
+	var issued apiKeyCreateResponseDTO
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &issued))
+	require.True(t, strings.HasPrefix(issued.Key, apikey.TokenPrefix))
+	require.Equal(t, "ci", issued.Name)
+	require.Equal(t, []string{"create", "read"}, issued.Scopes)
+	require.Nil(t, issued.LastUsedAt)
+
+	_, err := keys.Authenticate(issued.Key)
+	require.NoError(t, err)
+
+	// список не содержит секретов, но показывает последнее использование
+	request = withPrincipal(httptest.NewRequest(http.MethodGet, "/api/admin/keys", nil), adminKey)
+	response = httptest.NewRecorder()
+	handlers.ListAPIKeys(response, request)
+	require.Equal(t, http.StatusOK, response.Code)
+	require.NotContains(t, response.Body.String(), issued.Key)
+
+	var listed []apiKeyDTO
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &listed))
+	require.Len(t, listed, 1)
+	require.Equal(t, issued.ID, listed[0].ID)
+	require.NotNil(t, listed[0].LastUsedAt)
+
+	// отзыв
+	request = withPrincipal(httptest.NewRequest(http.MethodDelete, "/api/admin/keys/"+issued.ID, nil), adminKey)
+	request.SetPathValue("keyID", issued.ID)
+	response = httptest.NewRecorder()
+	handlers.RevokeAPIKey(response, request)
+	require.Equal(t, http.StatusNoContent, response.Code)
+
+	_, err = keys.Authenticate(issued.Key)
+	require.Error(t, err)
+
+	response = httptest.NewRecorder()
+	handlers.RevokeAPIKey(response, request)
+	require.Equal(t, http.StatusNotFound, response.Code)
+}
+
+func TestIssueAPIKeyBadRequest(t *testing.T) {
+	handlers := New(storage.New(), nil, WithAPIKeys(apikey.NewStore()))
+
+	for _, body := range []string{
+		`{"name":"ci","scopes":["write"]}`,
+		`{"name":"ci"}`,
+		`{"scopes":["read"]}`,
+		`not json`,
+	} {
+		request := withPrincipal(httptest.NewRequest(http.MethodPost, "/api/admin/keys", strings.NewReader(body)), adminKey)
+		response := httptest.NewRecorder()
+		handlers.IssueAPIKey(response, request)
+		require.Equal(t, http.StatusBadRequest, response.Code, body)
+	}
+}
+
+func TestAPIKeyEndpointsDisabled(t *testing.T) {
+	handlers := New(storage.New(), nil)
+
+	request := withPrincipal(httptest.NewRequest(http.MethodGet, "/api/admin/keys", nil), adminKey)
+	response := httptest.NewRecorder()
+	handlers.ListAPIKeys(response, request)
+	require.Equal(t, http.StatusNotImplemented, response.Code)
+}
+
+func TestDeleteLink(t *testing.T) {
+	repo := storage.New()
+	handlers := New(repo, nil)
+	id, err := repo.AddLink(storage.Link{URL: "https://example.com/", CreatedBy: "alice"})
+	require.NoError(t, err)
+
+	request := withPrincipal(httptest.NewRequest(http.MethodDelete, "/api/links/"+id, nil), alice)
+	request.SetPathValue("id", id)
+	response := httptest.NewRecorder()
+	handlers.DeleteLink(response, request)
+	require.Equal(t, http.StatusNoContent, response.Code)
+
+	_, err = repo.GetLink(id)
+	require.Error(t, err)
+
+	response = httptest.NewRecorder()
+	handlers.DeleteLink(response, request)
+	require.Equal(t, http.StatusNotFound, response.Code)
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adettelle/go-url-shortener/internal/analytics"
+	"github.com/adettelle/go-url-shortener/internal/apikey"
+	"github.com/adettelle/go-url-shortener/internal/auth"
+	"github.com/adettelle/go-url-shortener/internal/authz"
+	"github.com/adettelle/go-url-shortener/internal/config"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	alice    = &auth.Principal{UserID: "alice", Scopes: auth.UserScopes}
+	bob      = &auth.Principal{UserID: "bob", Scopes: auth.UserScopes}
+	reader   = &auth.Principal{APIKeyID: "reader", Scopes: []string{auth.ScopeRead}}
+	deleter  = &auth.Principal{APIKeyID: "deleter", Scopes: []string{auth.ScopeCreate, auth.ScopeDelete}}
+	adminKey = &auth.Principal{APIKeyID: "root", Scopes: []string{auth.ScopeAdmin}}
+	carol    = &auth.Principal{UserID: "carol", Email: "carol@example.com", EmailVerified: true, Scopes: auth.UserScopes}
+)
+
+func withPrincipal(r *http.Request, p *auth.Principal) *http.Request {
+	return r.WithContext(auth.NewContext(r.Context(), p))
+}
+
+func TestAuthorizationMatrix(t *testing.T) {
+	roles := []struct {
+		name      string
+		principal *auth.Principal
+	}{
+		{"anonymous", nil},
+		{"viewer", reader},
+		{"owner", alice},
+		{"other creator", bob},
+		{"key with delete scope", deleter},
+		{"admin", carol},
+	}
+
+	type endpoint struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		ifMatch string
+		handler func(h *Handlers) http.HandlerFunc
+		want    [6]int // в порядке roles
+	}
+	endpoints := []endpoint{
+		{"shorten plain", http.MethodPost, "/", "https://example.com/new", "",
+			func(h *Handlers) http.HandlerFunc { return h.CreateShortAddressPlainText },
+			[6]int{401, 403, 201, 201, 201, 201}},
+		{"shorten json", http.MethodPost, "/api/shorten", `{"url":"https://example.com/new"}`, "",
+			func(h *Handlers) http.HandlerFunc { return h.CreateShortAddressJSON },
+			[6]int{401, 403, 201, 201, 201, 201}},
+		{"redirect", http.MethodGet, "/{id}", "", "",
+			func(h *Handlers) http.HandlerFunc { return h.GetFullAddress },
+			[6]int{307, 307, 307, 307, 307, 307}},
+		{"qr", http.MethodGet, "/{id}/qr", "", "",
+			func(h *Handlers) http.HandlerFunc { return h.GetQRCode },
+			[6]int{200, 200, 200, 200, 200, 200}},
+		{"get link", http.MethodGet, "/api/links/{id}", "", "",
+			func(h *Handlers) http.HandlerFunc { return h.GetLink },
+			[6]int{401, 403, 200, 403, 403, 200}},
+		{"history", http.MethodGet, "/api/links/{id}/history", "", "",
+			func(h *Handlers) http.HandlerFunc { return h.GetLinkHistory },
+			[6]int{401, 403, 200, 403, 403, 200}},
+		{"stats", http.MethodGet, "/api/links/{id}/stats", "", "",
+			func(h *Handlers) http.HandlerFunc { return h.GetLinkStats },
+			[6]int{401, 403, 200, 403, 403, 200}},
+		{"update", http.MethodPatch, "/api/links/{id}", `{"url":"https://example.com/v2"}`, `"1"`,
+			func(h *Handlers) http.HandlerFunc { return h.UpdateLink },
+			[6]int{401, 403, 200, 403, 403, 200}},
+		{"rollback", http.MethodPost, "/api/links/{id}/rollback/1", "", "",
+			func(h *Handlers) http.HandlerFunc { return h.RollbackLink },
+			[6]int{401, 403, 200, 403, 403, 200}},
+		{"delete", http.MethodDelete, "/api/links/{id}", "", "",
+			func(h *Handlers) http.HandlerFunc { return h.DeleteLink },
+			[6]int{401, 403, 204, 403, 403, 204}},
+		{"issue key", http.MethodPost, "/api/admin/keys", `{"name":"ci","scopes":["read"]}`, "",
+			func(h *Handlers) http.HandlerFunc { return h.IssueAPIKey },
+			[6]int{401, 403, 403, 403, 403, 201}},
+		{"list keys", http.MethodGet, "/api/admin/keys", "", "",
+			func(h *Handlers) http.HandlerFunc { return h.ListAPIKeys },
+			[6]int{401, 403, 403, 403, 403, 200}},
+		{"revoke key", http.MethodDelete, "/api/admin/keys/nope", "", "",
+			func(h *Handlers) http.HandlerFunc { return h.RevokeAPIKey },
+			[6]int{401, 403, 403, 403, 403, 404}},
+		{"me", http.MethodGet, "/auth/me", "", "",
+			func(h *Handlers) http.HandlerFunc { return h.Me },
+			[6]int{401, 200, 200, 200, 200, 200}},
+	}
+
+	for _, ep := range endpoints {
+		for i, role := range roles {
+			t.Run(fmt.Sprintf("%s as %s", ep.name, role.name), func(t *testing.T) {
+				repo := storage.New()
+				handlers := New(repo, &config.Config{Domains: []string{"http://localhost:8080"}},
+					WithStats(analytics.NewMemoryStore()),
+					WithAPIKeys(apikey.NewStore()),
+					WithAccessPolicy(authz.New(authz.RoleNone, authz.RoleCreator, []string{"carol@example.com"})))
+				id, err := repo.AddLink(storage.Link{URL: "https://example.com/v1", CreatedBy: "alice"})
+				require.NoError(t, err)
+
+				request := httptest.NewRequest(ep.method, strings.ReplaceAll(ep.path, "{id}", id), strings.NewReader(ep.body))
+				request.SetPathValue("id", id)
+				request.SetPathValue("rev", "1")
+				request.SetPathValue("keyID", "nope")
+				if ep.ifMatch != "" {
+					request.Header.Set("If-Match", ep.ifMatch)
+				}
+				if role.principal != nil {
+					request = withPrincipal(request, role.principal)
+				}
+				response := httptest.NewRecorder()
+				ep.handler(handlers)(response, request)
+
+				require.Equal(t, ep.want[i], response.Code)
+			})
+		}
+	}
+}
+
+func TestDefaultAccessPolicyAllowsAnonymousShortening(t *testing.T) {
+	repo := storage.New()
+	handlers := New(repo, &config.Config{Domains: []string{"http://localhost:8080"}})
+
+	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://example.com/"))
+	response := httptest.NewRecorder()
+	handlers.CreateShortAddressPlainText(response, request)
+	require.Equal(t, http.StatusCreated, response.Code)
+
+	// ссылку без владельца может менять только администратор
+	id := strings.TrimPrefix(response.Body.String(), "http://localhost:8080/")
+	response = patchLink(handlers, id, `"1"`, `{"url":"https://example.com/other"}`)
+	require.Equal(t, http.StatusForbidden, response.Code)
+}
+
+func TestDefaultAccessPolicyHidesLinksOfOthers(t *testing.T) {
+	repo := storage.New()
+	handlers := New(repo, &config.Config{Domains: []string{"http://localhost:8080"}})
+
+	response := call(handlers.CreateShortAddressJSON, alice, http.MethodPost, "/api/shorten",
+		`{"url":"https://internal.example.com/secret-doc","alias":"doc","password":"pw"}`)
+	require.Equal(t, http.StatusCreated, response.Code)
+
+	// анонимы по умолчанию создатели, но адрес чужой ссылки под паролем им не виден
+	for _, handler := range []http.HandlerFunc{handlers.GetLink, handlers.GetLinkHistory} {
+		response = call(handler, nil, http.MethodGet, "/api/links/doc", "", "id", "doc")
+		require.Equal(t, http.StatusUnauthorized, response.Code)
+		require.NotContains(t, response.Body.String(), "secret-doc")
+
+		response = call(handler, bob, http.MethodGet, "/api/links/doc", "", "id", "doc")
+		require.Equal(t, http.StatusForbidden, response.Code)
+		require.NotContains(t, response.Body.String(), "secret-doc")
+
+		response = call(handler, alice, http.MethodGet, "/api/links/doc", "", "id", "doc")
+		require.Equal(t, http.StatusOK, response.Code)
+		require.Contains(t, response.Body.String(), "secret-doc")
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adettelle/go-url-shortener/internal/config"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDomainNamespaces(t *testing.T) {
+	repo := storage.New()
+	handlers := New(repo, &config.Config{Domains: []string{"http://go.brand-a.com", "https://brand-b.link"}})
+
+	shorten := func(host, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "http://"+host+"/api/shorten", strings.NewReader(body))
+		response := httptest.NewRecorder()
+		handlers.CreateShortAddressJSON(response, request)
+		return response
+	}
+
+	// без domain ссылка создаётся на домене запроса
+	response := shorten("go.brand-a.com", `{"url":"https://brand-a.com/sale","alias":"sale"}`)
+	require.Equal(t, http.StatusCreated, response.Code)
+	require.JSONEq(t, `{"result":"http://go.brand-a.com/sale"}`, response.Body.String())
+
+	response = shorten("go.brand-a.com", `{"url":"https://brand-b.com/sale","alias":"sale","domain":"brand-b.link"}`)
+	require.Equal(t, http.StatusCreated, response.Code)
+	require.JSONEq(t, `{"result":"https://brand-b.link/sale"}`, response.Body.String())
+
+	response = shorten("brand-b.link", `{"url":"https://brand-b.com/other","alias":"sale"}`)
+	require.Equal(t, http.StatusConflict, response.Code)
+	response = shorten("brand-b.link", `{"url":"https://brand-b.com/other","domain":"brand-c.link"}`)
+	require.Equal(t, http.StatusBadRequest, response.Code)
+	response = shorten("brand-b.link", `{"url":"https://brand-b.com/other","alias":"a/b"}`)
+	require.Equal(t, http.StatusBadRequest, response.Code)
+
+	for host, want := range map[string]string{
+		"go.brand-a.com": "https://brand-a.com/sale",
+		"brand-b.link":   "https://brand-b.com/sale",
+		"10.0.0.1:8080":  "https://brand-a.com/sale", // незнакомый хост попадает на домен по умолчанию
+	} {
+		request := httptest.NewRequest(http.MethodGet, "http://"+host+"/sale", nil)
+		request.SetPathValue("id", "sale")
+		response := httptest.NewRecorder()
+		handlers.GetFullAddress(response, request)
+		require.Equal(t, http.StatusTemporaryRedirect, response.Code, host)
+		require.Equal(t, want, response.Header().Get("Location"), host)
+	}
+}
+
+func TestDomainAPI(t *testing.T) {
+	repo := storage.New()
+	handlers := New(repo, &config.Config{Domains: []string{"http://go.brand-a.com", "https://brand-b.link"}})
+	_, err := repo.AddLink(storage.Link{ID: "sale", Domain: "brand-b.link", URL: "https://brand-b.com/sale", CreatedBy: "alice"})
+	require.NoError(t, err)
+
+	request := httptest.NewRequest(http.MethodGet, "http://go.brand-a.com/api/links/sale?domain=brand-b.link", nil)
+	request.SetPathValue("id", "sale")
+	response := httptest.NewRecorder()
+	handlers.GetLink(response, withPrincipal(request, alice))
+	require.Equal(t, http.StatusOK, response.Code)
+	var dto linkResponseDTO
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &dto))
+	require.Equal(t, "https://brand-b.link/sale", dto.ShortURL)
+	require.Equal(t, "brand-b.link", dto.Domain)
+
+	// на домене по умолчанию такой ссылки нет
+	request = httptest.NewRequest(http.MethodGet, "http://go.brand-a.com/api/links/sale", nil)
+	request.SetPathValue("id", "sale")
+	response = httptest.NewRecorder()
+	handlers.GetLink(response, withPrincipal(request, alice))
+	require.Equal(t, http.StatusNotFound, response.Code)
+
+	request = httptest.NewRequest(http.MethodPost, "http://go.brand-a.com/?domain=brand-b.link", strings.NewReader("https://brand-b.com/"))
+	response = httptest.NewRecorder()
+	handlers.CreateShortAddressPlainText(response, request)
+	require.Equal(t, http.StatusCreated, response.Code)
+	require.True(t, strings.HasPrefix(response.Body.String(), "https://brand-b.link/"), response.Body.String())
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/adettelle/go-url-shortener/internal/analytics"
+	"github.com/adettelle/go-url-shortener/internal/events"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"go.uber.org/zap"
+)
+
+// EventPublisher receives what happens to links, see events.Bus.
+type EventPublisher interface {
+	Publish(e events.Event)
+}
+
+// WithEvents makes handlers publish an event to p whenever a link is
+// created, edited, deleted or followed.
+func WithEvents(p EventPublisher) Option {
+	return func(h *Handlers) {
+		h.events = p
+	}
+}
+
+type clickEventDTO struct {
+	ID              string `json:"id"`
+	ShortURL        string `json:"short_url"`
+	Domain          string `json:"domain"`
+	Destination     string `json:"destination"` // куда отправлен посетитель с учётом таргетинга и вариантов
+	Referrer        string `json:"referrer,omitempty"`
+	UserAgentFamily string `json:"user_agent_family,omitempty"`
+	Variant         string `json:"variant,omitempty"`
+}
+
+// publishLink publishes an event of link with the link as the API shows it.
+func (h *Handlers) publishLink(r *http.Request, eventType string, link *storage.Link) {
+	if h.events == nil {
+		return
+	}
+	h.events.Publish(events.Event{
+		Type:      eventType,
+		Owner:     link.CreatedBy,
+		Workspace: link.Workspace,
+		Actor:     requestActor(r),
+		Data:      h.toLinkDTO(link),
+	})
+}
+
+// publishCreated publishes the creation of the link stored under key. The
+// link is read back since the storage fills in its id, time and revision.
+func (h *Handlers) publishCreated(r *http.Request, key string) {
+	if h.events == nil {
+		return
+	}
+	link, err := h.repo.GetLink(key)
+	if err != nil {
+		errlog.Error("error in getting created link", zap.String("link", key), zap.Error(err))
+		return
+	}
+	h.publishLink(r, events.LinkCreated, link)
+}
+
+// publishClick publishes a redirect of a visitor to target. Visitors are
+// not identified in the event, not even by the hash of their address.
+func (h *Handlers) publishClick(r *http.Request, link *storage.Link, target, variant string) {
+	if h.events == nil {
+		return
+	}
+	d := h.linkDomain(link)
+	h.events.Publish(events.Event{
+		Type:      events.LinkClicked,
+		Time:      h.clock(),
+		Owner:     link.CreatedBy,
+		Workspace: link.Workspace,
+		Data: clickEventDTO{
+			ID:              link.ID,
+			ShortURL:        d.ShortURL(link.ID),
+			Domain:          d.Host,
+			Destination:     target,
+			Referrer:        r.Referer(),
+			UserAgentFamily: analytics.UserAgentFamily(r.UserAgent()),
+			Variant:         variant,
+		},
+	})
+}
@@ -4,12 +4,28 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
+	"html/template"
 	"io"
 	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
 
+	"github.com/adettelle/go-url-shortener/internal/analytics"
+	"github.com/adettelle/go-url-shortener/internal/auth"
+	"github.com/adettelle/go-url-shortener/internal/authz"
+	"github.com/adettelle/go-url-shortener/internal/clientip"
 	"github.com/adettelle/go-url-shortener/internal/config"
+	"github.com/adettelle/go-url-shortener/internal/domain"
 	"github.com/adettelle/go-url-shortener/internal/logger"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/adettelle/go-url-shortener/internal/targeting"
+	"github.com/adettelle/go-url-shortener/internal/unlock"
+	"github.com/adettelle/go-url-shortener/internal/workspace"
 	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
 )
 
 var errlog *zap.Logger = logger.Logger
@@ -20,18 +36,249 @@ var errlog *zap.Logger = logger.Logger
 type Storager interface {
 	GetAddress(name string) (string, error)
 	AddAddress(fullPath string) (string, error)
+	GetLink(name string) (*storage.Link, error)
+	AddLink(link storage.Link) (string, error)
+	UseClick(name string) error
+	UpdateAddress(name, fullAddress, actor string, ifRevision int) (*storage.Link, error)
+	RollbackAddress(name string, rev int, actor string, ifRevision int) (*storage.Link, error)
+	DeleteAddress(name string) error
+	ListLinks(filter storage.LinkFilter) ([]*storage.Link, error)
+	SetLabels(name string, tags []string, folder string) (*storage.Link, error)
+	ListTags(filter storage.LinkFilter) ([]storage.TagCount, error)
+	RenameTags(filter storage.LinkFilter, from []string, to string) (int, error)
+	SearchLinks(filter storage.LinkFilter, query string, limit int) ([]storage.SearchResult, error)
+}
+
+// URLPolicy decides whether a destination URL may be shortened or followed.
+// Check returns a non-nil error for blocked URLs.
+type URLPolicy interface {
+	Check(rawURL string) error
+}
+
+// ClickRecorder accepts click events without blocking the caller.
+type ClickRecorder interface {
+	Record(c analytics.Click) bool
 }
 
 type Handlers struct {
 	repo   Storager
 	config *config.Config
+	policy URLPolicy
+	clicks ClickRecorder
+	ipSalt string
+	ips    *clientip.Resolver
+	stats  StatsProvider
+
+	unlockSigner   *unlock.Signer
+	unlockThrottle *unlock.Throttle
+
+	now        func() time.Time
+	comingSoon *template.Template
+
+	geo  targeting.CountryResolver
+	intn func(n int) int // источник случайности для A/B вариантов; rand.IntN, если nil
+
+	apiKeys  APIKeyManager
+	login    LoginProvider
+	sessions SessionStore
+
+	access     *authz.Policy
+	workspaces WorkspaceStore
+
+	domains *domain.Set // домены, на которых работают короткие ссылки; первый по умолчанию
+
+	metadata MetadataQueue
+
+	events   EventPublisher
+	webhooks WebhookManager
+}
+
+// Option configures optional dependencies of Handlers.
+type Option func(*Handlers)
+
+// WithPolicy makes handlers consult p before shortening or redirecting.
+func WithPolicy(p URLPolicy) Option {
+	return func(h *Handlers) {
+		h.policy = p
+	}
+}
+
+// WithClickRecorder makes GetFullAddress report every redirect to rec.
+// Client addresses are hashed with salt before they leave the handler.
+func WithClickRecorder(rec ClickRecorder, salt string) Option {
+	return func(h *Handlers) {
+		h.clicks = rec
+		h.ipSalt = salt
+	}
+}
+
+// WithClientIP sets the resolver used to find the client address behind proxies.
+func WithClientIP(ips *clientip.Resolver) Option {
+	return func(h *Handlers) {
+		h.ips = ips
+	}
+}
+
+// WithClock replaces time.Now, which makes time-dependent behaviour testable.
+func WithClock(now func() time.Time) Option {
+	return func(h *Handlers) {
+		h.now = now
+	}
+}
+
+// WithComingSoonPage makes GetFullAddress render page for links that are not
+// active yet instead of answering 404. The template gets the activation time
+// as .NotBefore.
+func WithComingSoonPage(page *template.Template) Option {
+	return func(h *Handlers) {
+		h.comingSoon = page
+	}
+}
+
+// WithAccessPolicy sets who may do what. Without it anonymous callers
+// and users are creators, see defaultAccessPolicy.
+func WithAccessPolicy(p *authz.Policy) Option {
+	return func(h *Handlers) {
+		h.access = p
+	}
+}
+
+// defaultAccessPolicy keeps anonymous shortening working: everybody is
+// a creator and may change only their own links.
+var defaultAccessPolicy = authz.New(authz.RoleCreator, authz.RoleCreator, nil)
+
+// authorize asks the access policy whether the caller may perform action
+// on link (nil for actions without a link). If not, 401 or 403 is already
+// written and authorize returns false.
+func (h *Handlers) authorize(w http.ResponseWriter, r *http.Request, action authz.Action, link *storage.Link) bool {
+	req := authz.Request{Principal: auth.FromContext(r.Context()), Action: action}
+	if link != nil {
+		req.Resource = link.ID
+		req.Owner = link.CreatedBy
+		req.Workspace = h.workspaceOf(link)
+	}
+	return h.decide(w, req)
+}
+
+// authorizeWorkspace is authorize for actions on a workspace.
+func (h *Handlers) authorizeWorkspace(w http.ResponseWriter, r *http.Request, action authz.Action, ws *workspace.Workspace) bool {
+	return h.decide(w, authz.Request{
+		Principal: auth.FromContext(r.Context()),
+		Action:    action,
+		Resource:  ws.ID,
+		Workspace: ws,
+	})
+}
+
+func (h *Handlers) decide(w http.ResponseWriter, req authz.Request) bool {
+	policy := h.access
+	if policy == nil {
+		policy = defaultAccessPolicy
+	}
+	if policy.Authorize(req).Allowed {
+		return true
+	}
+	if req.Principal.Actor() == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+	w.WriteHeader(http.StatusForbidden)
+	return false
 }
 
-func New(s Storager, cfg *config.Config) *Handlers {
-	return &Handlers{
+func (h *Handlers) clock() time.Time {
+	if h.now == nil {
+		return time.Now()
+	}
+	return h.now()
+}
+
+func New(s Storager, cfg *config.Config, opts ...Option) *Handlers {
+	h := &Handlers{
 		repo:   s,
 		config: cfg,
 	}
+	if cfg != nil {
+		// адреса уже проверены в config.New
+		domains, err := domain.NewSet(cfg.Domains)
+		if err != nil {
+			errlog.Error("error in parsing domains", zap.Error(err))
+		}
+		h.domains = domains
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	if h.unlockSigner == nil {
+		h.unlockSigner = unlock.NewSigner(nil, defaultUnlockTTL)
+	}
+	if h.unlockThrottle == nil {
+		h.unlockThrottle = unlock.NewThrottle(defaultUnlockAttempts, defaultUnlockWindow)
+	}
+	return h
+}
+
+// requestDomain returns the domain the request was sent to.
+func (h *Handlers) requestDomain(r *http.Request) domain.Domain {
+	return h.domains.ForHost(r.Host)
+}
+
+// linkDomain returns the domain link lives on.
+func (h *Handlers) linkDomain(link *storage.Link) domain.Domain {
+	if d, ok := h.domains.Lookup(link.Domain); ok {
+		return d
+	}
+	// домен убрали из настроек, но ссылки на нём остались
+	return domain.Domain{Base: "https://" + link.Domain, Host: link.Domain, Name: link.Domain}
+}
+
+// lookupAPILink loads the link addressed by an API request: the id from
+// the path on the domain given by ?domain= or, without it, on the domain
+// of the request host. Unknown domains are answered with 400.
+func (h *Handlers) lookupAPILink(w http.ResponseWriter, r *http.Request) (*storage.Link, bool) {
+	d := h.requestDomain(r)
+	if name := r.URL.Query().Get("domain"); name != "" {
+		var ok bool
+		if d, ok = h.domains.Lookup(name); !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			return nil, false
+		}
+	}
+	return h.lookupLink(w, storage.Key(d.Name, r.PathValue("id")))
+}
+
+// lookupLink loads the link with the given key, see storage.Key. If it
+// fails, the error response is already written and lookupLink returns false.
+func (h *Handlers) lookupLink(w http.ResponseWriter, key string) (*storage.Link, bool) {
+	link, err := h.repo.GetLink(key)
+	if err != nil {
+		var noEntry *storage.NoEntryError
+		if errors.As(err, &noEntry) {
+			w.WriteHeader(http.StatusNotFound)
+			return nil, false
+		}
+		errlog.Error("error in getting address", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	if link.URL == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return nil, false
+	}
+	return link, true
+}
+
+// checkPolicy returns false if the policy blocks fullAddress.
+func (h *Handlers) checkPolicy(fullAddress string) bool {
+	if h.policy == nil {
+		return true
+	}
+	if err := h.policy.Check(fullAddress); err != nil {
+		errlog.Info("address blocked by policy", zap.String("address", fullAddress), zap.Error(err))
+		return false
+	}
+	return true
 }
 
 func (h *Handlers) CreateShortAddressPlainText(w http.ResponseWriter, r *http.Request) {
@@ -39,6 +286,9 @@ func (h *Handlers) CreateShortAddressPlainText(w http.ResponseWriter, r *http.Re
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
+	if !h.authorize(w, r, authz.ActionCreate, nil) {
+		return
+	}
 
 	var err error
 
@@ -57,14 +307,25 @@ func (h *Handlers) CreateShortAddressPlainText(w http.ResponseWriter, r *http.Re
 			return
 		}
 	*/
-	shortAddress, err := h.repo.AddAddress(string(body))
+	if !h.checkPolicy(string(body)) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	d, ok := h.createDomain(w, r, r.URL.Query().Get("domain"))
+	if !ok {
+		return
+	}
+
+	shortAddress, err := h.repo.AddLink(storage.Link{URL: string(body), Domain: d.Name})
 	if err != nil {
 		errlog.Error("error in adding address", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	h.fetchMetadata(storage.Key(d.Name, shortAddress), string(body))
+	h.publishCreated(r, storage.Key(d.Name, shortAddress))
 
-	shortenAddress := h.config.URLAddress + "/" + shortAddress
+	shortenAddress := d.ShortURL(shortAddress)
 
 	w.Header().Set("Content-Type", "text/plain")
 	w.WriteHeader(http.StatusCreated)
@@ -76,33 +337,190 @@ func (h *Handlers) CreateShortAddressPlainText(w http.ResponseWriter, r *http.Re
 	}
 }
 
+// createDomain returns the domain a new link is created on: the one named
+// in the request or, if name is empty, the domain of the request host.
+// Unknown domains are answered with 400.
+func (h *Handlers) createDomain(w http.ResponseWriter, r *http.Request, name string) (domain.Domain, bool) {
+	if name == "" {
+		return h.requestDomain(r), true
+	}
+	d, ok := h.domains.Lookup(name)
+	if !ok {
+		errlog.Info("unknown domain", zap.String("domain", name))
+		w.WriteHeader(http.StatusBadRequest)
+		return domain.Domain{}, false
+	}
+	return d, true
+}
+
+// GetFullAddress redirects to the destination of /{id}. HEAD requests get
+// the same answer without spending a click, /{id}+ and /{id}?preview=1
+// render a preview page instead of redirecting.
 func (h *Handlers) GetFullAddress(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
-	id := r.PathValue("id")
-	fullAddress, err := h.repo.GetAddress(id)
+	id, preview := parseLinkID(r)
+	link, ok := h.lookupLink(w, storage.Key(h.requestDomain(r).Name, id))
+	if !ok {
+		return
+	}
+	if !h.authorize(w, r, authz.ActionFollow, link) {
+		return
+	}
+	if !h.checkActive(w, link) {
+		return
+	}
+	// правила могли измениться после создания ссылки
+	if !h.checkPolicy(link.URL) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if link.PasswordHash != "" && !h.isUnlocked(r, link) {
+		h.renderPasswordForm(w, http.StatusOK, "")
+		return
+	}
+	if preview {
+		h.renderPreview(w, r, link)
+		return
+	}
+
+	target, variant := h.destination(w, r, link)
+	if target != link.URL && !h.checkPolicy(target) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	location, err := mergeQuery(target, r.URL.RawQuery, link.Query)
 	if err != nil {
-		errlog.Error("error in getting address", zap.Error(err))
-		w.WriteHeader(http.StatusInternalServerError)
+		errlog.Info("error in merging query", zap.String("destination", target), zap.Error(err))
+		location = target
+	}
+
+	// клик тратится последним, когда переход точно состоится;
+	// HEAD только показывает, куда ведёт ссылка, переход не засчитывается
+	counted := r.Method == http.MethodGet
+	if link.MaxClicks > 0 && !counted && link.RemainingClicks <= 0 {
+		w.WriteHeader(http.StatusGone)
 		return
 	}
+	if link.MaxClicks > 0 && counted {
+		err = h.repo.UseClick(link.Key())
+		if err != nil {
+			var exhausted *storage.ClicksExhaustedError
+			if errors.As(err, &exhausted) {
+				w.WriteHeader(http.StatusGone)
+				return
+			}
+			errlog.Error("error in using click", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	status := h.redirectStatus(link)
+	w.Header().Set("Cache-Control", h.redirectCacheControl(link, status))
+	if len(link.Targets) > 0 {
+		w.Header().Add("Vary", "User-Agent, Accept-Language")
+	}
+	if len(link.Variants) > 0 {
+		w.Header().Add("Vary", "Cookie")
+	}
+	w.Header().Set("Location", location)
+	w.WriteHeader(status)
+
+	if counted {
+		h.recordClick(r, link.Key(), variant)
+		h.publishClick(r, link, target, variant)
+	}
+}
 
-	if fullAddress == "" {
+// parseLinkID returns the link id of the request and whether a preview
+// was asked for, either Bitly-style with a trailing "+" or with ?preview=1.
+func parseLinkID(r *http.Request) (string, bool) {
+	id := r.PathValue("id")
+	if trimmed, ok := strings.CutSuffix(id, "+"); ok {
+		return trimmed, true
+	}
+	return id, r.URL.Query().Get("preview") == "1"
+}
+
+// checkActive answers 404 (or the coming soon page) before the activation
+// window of the link and 410 after it. It returns true for active links.
+func (h *Handlers) checkActive(w http.ResponseWriter, link *storage.Link) bool {
+	now := h.clock()
+	if !link.NotAfter.IsZero() && !now.Before(link.NotAfter) {
+		w.WriteHeader(http.StatusGone)
+		return false
+	}
+	if link.NotBefore.IsZero() || !now.Before(link.NotBefore) {
+		return true
+	}
+
+	if h.comingSoon == nil {
 		w.WriteHeader(http.StatusNotFound)
+		return false
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Retry-After", link.NotBefore.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+	err := h.comingSoon.Execute(w, struct{ NotBefore time.Time }{link.NotBefore})
+	if err != nil {
+		errlog.Error("error in rendering coming soon page", zap.Error(err))
+	}
+	return false
+}
+
+// recordClick reports a redirect of the link with the given key, see storage.Key.
+func (h *Handlers) recordClick(r *http.Request, key, variant string) {
+	if h.clicks == nil {
 		return
 	}
-	w.Header().Set("Location", fullAddress)
-	w.WriteHeader(http.StatusTemporaryRedirect)
+	h.clicks.Record(analytics.Click{
+		Time:      h.clock(),
+		ShortID:   key,
+		Referrer:  r.Referer(),
+		UserAgent: r.UserAgent(),
+		UAFamily:  analytics.UserAgentFamily(r.UserAgent()),
+		IPHash:    analytics.HashIP(h.ipSalt, h.ips.ClientIP(r)),
+		Variant:   variant,
+	})
 }
 
 type shortAddrCreateRequestDTO struct {
-	URL string `json:"url"`
+	URL       string `json:"url"`
+	QR        bool   `json:"qr,omitempty"`         // вернуть QR-код короткой ссылки
+	Password  string `json:"password,omitempty"`   // пароль для перехода по ссылке
+	MaxClicks int    `json:"max_clicks,omitempty"` // сколько раз можно перейти по ссылке
+
+	NotBefore *time.Time `json:"not_before,omitempty"` // ссылка начинает работать в этот момент
+	NotAfter  *time.Time `json:"not_after,omitempty"`  // и перестаёт в этот
+
+	RedirectType int `json:"redirect_type,omitempty"` // 301, 302, 307 или 308
+
+	Targets  []targetRuleDTO `json:"targets,omitempty"`  // куда вести разные устройства, языки и страны
+	Variants []variantDTO    `json:"variants,omitempty"` // A/B тест: посетители делятся между адресами по весам
+
+	Query *queryOptionsDTO `json:"query,omitempty"` // передача параметров запроса и UTM-метки
+
+	Workspace string `json:"workspace,omitempty"` // рабочее пространство, которому будет принадлежать ссылка
+
+	Title  string   `json:"title,omitempty"`  // название ссылки для людей и поиска
+	Tags   []string `json:"tags,omitempty"`   // метки для поиска ссылки
+	Folder string   `json:"folder,omitempty"` // папка вида marketing/2024
+
+	Domain string `json:"domain,omitempty"` // домен короткой ссылки; по умолчанию домен запроса
+	Alias  string `json:"alias,omitempty"`  // желаемый id вместо случайного, уникальный в пределах домена
 }
 
+// aliasPattern limits chosen ids to characters that need no escaping in URLs.
+var aliasPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
 type shortAddrCreateResponseDTO struct {
 	Result string `json:"result"`
+	QR     string `json:"qr,omitempty"` // PNG в виде data: URL
 }
 
 func helper(h *Handlers, body string) (string, error) {
@@ -112,7 +530,7 @@ func helper(h *Handlers, body string) (string, error) {
 		return "", err
 	}
 
-	shortenAddress := h.config.URLAddress + "/" + shortAddress
+	shortenAddress := h.domains.Default().ShortURL(shortAddress)
 	return shortenAddress, nil
 }
 
@@ -124,6 +542,9 @@ func (h *Handlers) CreateShortAddressJSON(w http.ResponseWriter, r *http.Request
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		return
 	}
+	if !h.authorize(w, r, authz.ActionCreate, nil) {
+		return
+	}
 
 	// Read the request body
 	_, err := buf.ReadFrom(r.Body)
@@ -148,18 +569,141 @@ func (h *Handlers) CreateShortAddressJSON(w http.ResponseWriter, r *http.Request
 			return
 		}
 	*/
-	shortAddress, err := h.repo.AddAddress(requestBody.URL) // shortAddress is: vN
+	if requestBody.Workspace != "" {
+		ws, ok := h.lookupWorkspace(w, requestBody.Workspace, http.StatusBadRequest)
+		if !ok {
+			return
+		}
+		if !h.authorizeWorkspace(w, r, authz.ActionCreate, ws) {
+			return
+		}
+	}
+
+	if !h.checkPolicy(requestBody.URL) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if requestBody.MaxClicks < 0 || !validRedirectType(requestBody.RedirectType) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if requestBody.Alias != "" && !aliasPattern.MatchString(requestBody.Alias) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	d, ok := h.createDomain(w, r, requestBody.Domain)
+	if !ok {
+		return
+	}
+	tags, err := normalizeTags(requestBody.Tags)
+	if err != nil {
+		errlog.Info("invalid tags", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	folder, err := normalizeFolder(requestBody.Folder)
+	if err != nil {
+		errlog.Info("invalid folder", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	title := strings.TrimSpace(requestBody.Title)
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	link := storage.Link{
+		ID:           requestBody.Alias,
+		Domain:       d.Name,
+		URL:          requestBody.URL,
+		Title:        title,
+		MaxClicks:    requestBody.MaxClicks,
+		CreatedBy:    requestActor(r),
+		Workspace:    requestBody.Workspace,
+		Tags:         tags,
+		Folder:       folder,
+		RedirectType: requestBody.RedirectType,
+		Targets:      toTargetRules(requestBody.Targets),
+		Variants:     toVariants(requestBody.Variants),
+	}
+	if err := targeting.Validate(link.Targets); err != nil {
+		errlog.Info("invalid targeting rules", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	link.Query, err = toQueryOptions(requestBody.Query)
 	if err != nil {
+		errlog.Info("invalid query options", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := validateVariants(link.Variants); err != nil {
+		errlog.Info("invalid variants", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	for _, rule := range link.Targets {
+		if !h.checkPolicy(rule.URL) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+	}
+	for _, v := range link.Variants {
+		if !h.checkPolicy(v.URL) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+	}
+	if requestBody.NotBefore != nil {
+		link.NotBefore = *requestBody.NotBefore
+	}
+	if requestBody.NotAfter != nil {
+		link.NotAfter = *requestBody.NotAfter
+	}
+	if !link.NotBefore.IsZero() && !link.NotAfter.IsZero() && !link.NotBefore.Before(link.NotAfter) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if requestBody.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(requestBody.Password), bcrypt.DefaultCost)
+		if err != nil {
+			errlog.Info("error in hashing password", zap.Error(err))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		link.PasswordHash = string(hash)
+	}
+
+	shortAddress, err := h.repo.AddLink(link) // shortAddress is: vN
+	if err != nil {
+		var taken *storage.AliasTakenError
+		if errors.As(err, &taken) {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
 		errlog.Error("error in adding address", zap.Error(err))
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	h.fetchMetadata(storage.Key(d.Name, shortAddress), link.URL)
+	h.publishCreated(r, storage.Key(d.Name, shortAddress))
+
+	shortenAddress := d.ShortURL(shortAddress) // http://localhost:8000/vN
 
-	shortenAddress := h.config.URLAddress + "/" + shortAddress // http://localhost:8000/vN
+	respDTO := shortAddrCreateResponseDTO{Result: shortenAddress}
+	if requestBody.QR {
+		respDTO.QR, err = qrDataURL(shortenAddress)
+		if err != nil {
+			errlog.Error("error in rendering qr code", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	respDTO := shortAddrCreateResponseDTO{Result: shortenAddress}
 	resp, err := json.Marshal(respDTO)
 	if err != nil {
 		errlog.Error("error in marshalling json", zap.Error(err))
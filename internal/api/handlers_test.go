@@ -2,13 +2,16 @@ package api
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
+	"github.com/adettelle/go-url-shortener/internal/analytics"
 	"github.com/adettelle/go-url-shortener/internal/config"
 	"github.com/adettelle/go-url-shortener/internal/mocks"
+	"github.com/adettelle/go-url-shortener/internal/storage"
 	"github.com/carlmjohnson/requests"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
@@ -33,7 +36,7 @@ func TestCreateShortAddressPlainText(t *testing.T) {
 	reqURL := "http://" + cfg.Address + "/"
 	id := "qqVjJVf"
 
-	mockStorage.EXPECT().AddAddress(strBody).Return(reqURL+id, nil)
+	mockStorage.EXPECT().AddLink(storage.Link{URL: strBody}).Return(id, nil)
 
 	request, err := http.NewRequest(http.MethodPost, reqURL, strings.NewReader(strBody))
 	require.NoError(t, err)
@@ -62,7 +65,7 @@ func TestGetFullAddress(t *testing.T) {
 	reqURL := "http://localhost:8080/"
 	header := "https://practicum.yandex.ru/"
 
-	mockStorage.EXPECT().GetAddress(id).Return(header, nil)
+	mockStorage.EXPECT().GetLink(id).Return(&storage.Link{ID: id, URL: header}, nil)
 
 	request, err := http.NewRequest(http.MethodGet, reqURL, nil)
 	require.NoError(t, err)
@@ -86,7 +89,7 @@ func TestCreateShortAddressJson(t *testing.T) {
 	mockStorage := mocks.NewMockStorager(ctrl)
 	// cfg, err := config.New()
 	// require.NoError(t, err)
-	cfg := &config.Config{Address: "localhost:8080", URLAddress: "http://localhost:8080"}
+	cfg := &config.Config{Address: "localhost:8080", Domains: []string{"http://localhost:8080"}}
 	handlers := &Handlers{
 		repo:   mockStorage,
 		config: cfg,
@@ -96,7 +99,7 @@ func TestCreateShortAddressJson(t *testing.T) {
 	reqURL := "http://" + cfg.Address + "/api/shorten"
 	id := "qqVjJVf"
 
-	mockStorage.EXPECT().AddAddress(reqBody.URL).Return(reqURL+id, nil)
+	mockStorage.EXPECT().AddLink(storage.Link{URL: reqBody.URL}).Return(id, nil)
 
 	request, err := requests.
 		URL(reqURL).
@@ -111,3 +114,90 @@ func TestCreateShortAddressJson(t *testing.T) {
 	handlers.CreateShortAddressJSON(response, request)
 	require.Equal(t, wantHTTPStatus, response.Code)
 }
+
+type denyPolicy struct {
+	host string
+}
+
+func (p denyPolicy) Check(rawURL string) error {
+	if strings.Contains(rawURL, p.host) {
+		return errors.New("blocked")
+	}
+	return nil
+}
+
+func TestCreateShortAddressBlockedByPolicy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mocks.NewMockStorager(ctrl)
+	cfg := &config.Config{Address: "localhost:8080", Domains: []string{"http://localhost:8080"}}
+	handlers := New(mockStorage, cfg, WithPolicy(denyPolicy{host: "evil.com"}))
+
+	// AddLink не должен вызываться
+	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://evil.com/login"))
+	response := httptest.NewRecorder()
+	handlers.CreateShortAddressPlainText(response, request)
+	require.Equal(t, http.StatusForbidden, response.Code)
+
+	request = httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(`{"url":"https://evil.com/login"}`))
+	response = httptest.NewRecorder()
+	handlers.CreateShortAddressJSON(response, request)
+	require.Equal(t, http.StatusForbidden, response.Code)
+}
+
+func TestGetFullAddressBlockedByPolicy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mocks.NewMockStorager(ctrl)
+	handlers := New(mockStorage, nil, WithPolicy(denyPolicy{host: "evil.com"}))
+
+	id := "qqVjJVf"
+	mockStorage.EXPECT().GetLink(id).Return(&storage.Link{ID: id, URL: "https://evil.com/login"}, nil)
+
+	request := httptest.NewRequest(http.MethodGet, "/"+id, nil)
+	request.SetPathValue("id", id)
+	response := httptest.NewRecorder()
+	handlers.GetFullAddress(response, request)
+
+	require.Equal(t, http.StatusForbidden, response.Code)
+	require.Empty(t, response.Header().Get("Location"))
+}
+
+type clickSink struct {
+	clicks []analytics.Click
+}
+
+func (s *clickSink) Record(c analytics.Click) bool {
+	s.clicks = append(s.clicks, c)
+	return true
+}
+
+func TestGetFullAddressRecordsClick(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mocks.NewMockStorager(ctrl)
+	sink := &clickSink{}
+	handlers := New(mockStorage, nil, WithClickRecorder(sink, "salt"))
+
+	id := "qqVjJVf"
+	mockStorage.EXPECT().GetLink(id).Return(&storage.Link{ID: id, URL: "https://practicum.yandex.ru/"}, nil)
+
+	request := httptest.NewRequest(http.MethodGet, "/"+id, nil)
+	request.SetPathValue("id", id)
+	request.Header.Set("Referer", "https://news.example.com/")
+	request.Header.Set("User-Agent", "curl/8.0")
+	response := httptest.NewRecorder()
+	handlers.GetFullAddress(response, request)
+
+	require.Equal(t, http.StatusTemporaryRedirect, response.Code)
+	require.Len(t, sink.clicks, 1)
+	click := sink.clicks[0]
+	require.Equal(t, id, click.ShortID)
+	require.Equal(t, "https://news.example.com/", click.Referrer)
+	require.Equal(t, "curl/8.0", click.UserAgent)
+	require.Equal(t, analytics.HashIP("salt", "192.0.2.1"), click.IPHash)
+	require.False(t, click.Time.IsZero())
+}
@@ -0,0 +1,439 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/auth"
+	"github.com/adettelle/go-url-shortener/internal/authz"
+	"github.com/adettelle/go-url-shortener/internal/events"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"go.uber.org/zap"
+)
+
+type linkResponseDTO struct {
+	ID                string     `json:"id"`
+	ShortURL          string     `json:"short_url"`
+	Domain            string     `json:"domain"`
+	URL               string     `json:"url"`
+	Title             string     `json:"title,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	Revision          int        `json:"revision"`
+	PasswordProtected bool       `json:"password_protected"`
+	MaxClicks         int        `json:"max_clicks,omitempty"`
+	RemainingClicks   int        `json:"remaining_clicks,omitempty"`
+	NotBefore         *time.Time `json:"not_before,omitempty"`
+	NotAfter          *time.Time `json:"not_after,omitempty"`
+	RedirectType      int        `json:"redirect_type"`
+	Owner             string     `json:"owner,omitempty"`
+	Workspace         string     `json:"workspace,omitempty"`
+	Tags              []string   `json:"tags,omitempty"`
+	Folder            string     `json:"folder,omitempty"`
+	Page              *pageDTO   `json:"page,omitempty"`
+	Health            *healthDTO `json:"health,omitempty"`
+
+	Targets  []targetRuleDTO `json:"targets,omitempty"`
+	Variants []variantDTO    `json:"variants,omitempty"`
+
+	Query *queryOptionsDTO `json:"query,omitempty"`
+}
+
+type checkDTO struct {
+	Time   time.Time `json:"time"`
+	Status int       `json:"status,omitempty"`
+	Error  string    `json:"error,omitempty"`
+	Broken bool      `json:"broken"`
+}
+
+type healthDTO struct {
+	Broken      bool       `json:"broken"`
+	BrokenSince *time.Time `json:"broken_since,omitempty"`
+	Checks      []checkDTO `json:"checks"`
+}
+
+// toHealthDTO returns nil for links that have not been checked yet.
+func toHealthDTO(link *storage.Link) *healthDTO {
+	if len(link.Checks) == 0 {
+		return nil
+	}
+	dto := &healthDTO{Broken: !link.BrokenSince.IsZero(), Checks: make([]checkDTO, 0, len(link.Checks))}
+	if dto.Broken {
+		dto.BrokenSince = &link.BrokenSince
+	}
+	for _, c := range link.Checks {
+		dto.Checks = append(dto.Checks, checkDTO{Time: c.Time, Status: c.Status, Error: c.Error, Broken: c.Broken})
+	}
+	return dto
+}
+
+type linkUpdateRequestDTO struct {
+	URL string `json:"url"`
+}
+
+type revisionDTO struct {
+	Revision   int       `json:"revision"`
+	URL        string    `json:"url"`
+	Time       time.Time `json:"time"`
+	Actor      string    `json:"actor,omitempty"`
+	RollbackOf int       `json:"rollback_of,omitempty"`
+}
+
+type linkHistoryResponseDTO struct {
+	ID       string        `json:"id"`
+	Revision int           `json:"revision"`
+	History  []revisionDTO `json:"history"`
+}
+
+// requestActor names the caller in the link history. Anonymous callers
+// are recorded with an empty actor.
+func requestActor(r *http.Request) string {
+	return auth.FromContext(r.Context()).Actor()
+}
+
+// requireActor returns the actor of the request. Anonymous callers get
+// 401 and requireActor returns false.
+func requireActor(w http.ResponseWriter, r *http.Request) (string, bool) {
+	actor := requestActor(r)
+	if actor == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		w.WriteHeader(http.StatusUnauthorized)
+		return "", false
+	}
+	return actor, true
+}
+
+func (h *Handlers) toLinkDTO(link *storage.Link) linkResponseDTO {
+	dto := linkResponseDTO{
+		ID:                link.ID,
+		ShortURL:          h.linkDomain(link).ShortURL(link.ID),
+		Domain:            h.linkDomain(link).Host,
+		URL:               link.URL,
+		Title:             link.Title,
+		CreatedAt:         link.CreatedAt,
+		Revision:          link.Revision,
+		PasswordProtected: link.PasswordHash != "",
+		MaxClicks:         link.MaxClicks,
+		RemainingClicks:   link.RemainingClicks,
+		RedirectType:      h.redirectStatus(link),
+		Owner:             link.CreatedBy,
+		Workspace:         link.Workspace,
+		Tags:              link.Tags,
+		Folder:            link.Folder,
+		Page:              toPageDTO(link.Page),
+		Health:            toHealthDTO(link),
+		Targets:           toTargetDTOs(link.Targets),
+		Variants:          toVariantDTOs(link.Variants),
+		Query:             toQueryOptionsDTO(link.Query),
+	}
+	if dto.Title == "" {
+		// своего названия нет, показываем заголовок страницы
+		dto.Title = link.Page.Title
+	}
+	if !link.NotBefore.IsZero() {
+		dto.NotBefore = &link.NotBefore
+	}
+	if !link.NotAfter.IsZero() {
+		dto.NotAfter = &link.NotAfter
+	}
+	return dto
+}
+
+// revisionETag is the entity tag of a link version, e.g. "3".
+func revisionETag(rev int) string {
+	return `"` + strconv.Itoa(rev) + `"`
+}
+
+// parseRevisionETag extracts the revision from an If-Match header value.
+func parseRevisionETag(v string) (int, bool) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+	if len(v) < 2 || v[0] != '"' || v[len(v)-1] != '"' {
+		return 0, false
+	}
+	rev, err := strconv.Atoi(v[1 : len(v)-1])
+	if err != nil || rev < 1 {
+		return 0, false
+	}
+	return rev, true
+}
+
+// GetLink handles GET /api/links/{id}. The ETag header carries the revision
+// to be sent back in If-Match when the link is edited.
+func (h *Handlers) GetLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	link, ok := h.lookupAPILink(w, r)
+	if !ok {
+		return
+	}
+	if !h.authorize(w, r, authz.ActionView, link) {
+		return
+	}
+	w.Header().Set("ETag", revisionETag(link.Revision))
+	writeJSON(w, http.StatusOK, h.toLinkDTO(link))
+}
+
+// UpdateLink handles PATCH /api/links/{id} and changes the link destination.
+// If-Match with the current ETag is required so that concurrent edits
+// do not silently overwrite each other.
+func (h *Handlers) UpdateLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		w.WriteHeader(http.StatusPreconditionRequired)
+		return
+	}
+	rev, ok := parseRevisionETag(ifMatch)
+	if !ok {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	current, ok := h.lookupAPILink(w, r)
+	if !ok {
+		return
+	}
+	if !h.authorize(w, r, authz.ActionEdit, current) {
+		return
+	}
+
+	var requestBody linkUpdateRequestDTO
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		errlog.Error("error in unmarshalling json", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if requestBody.URL == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if !h.checkPolicy(requestBody.URL) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	link, err := h.repo.UpdateAddress(current.Key(), requestBody.URL, requestActor(r), rev)
+	if err != nil {
+		var noEntry *storage.NoEntryError
+		var mismatch *storage.RevisionMismatchError
+		switch {
+		case errors.As(err, &noEntry):
+			w.WriteHeader(http.StatusNotFound)
+		case errors.As(err, &mismatch):
+			w.WriteHeader(http.StatusPreconditionFailed)
+		default:
+			errlog.Error("error in updating address", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	// при смене адреса метаданные прежней страницы сброшены
+	if link.Page.FetchedAt.IsZero() {
+		h.fetchMetadata(link.Key(), link.URL)
+	}
+	h.publishLink(r, events.LinkUpdated, link)
+
+	w.Header().Set("ETag", revisionETag(link.Revision))
+	writeJSON(w, http.StatusOK, h.toLinkDTO(link))
+}
+
+// DeleteLink handles DELETE /api/links/{id}.
+func (h *Handlers) DeleteLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	link, ok := h.lookupAPILink(w, r)
+	if !ok {
+		return
+	}
+	if !h.authorize(w, r, authz.ActionDelete, link) {
+		return
+	}
+
+	err := h.repo.DeleteAddress(link.Key())
+	if err != nil {
+		var noEntry *storage.NoEntryError
+		if errors.As(err, &noEntry) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		errlog.Error("error in deleting address", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	h.publishLink(r, events.LinkDeleted, link)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListLinks handles GET /api/links?workspace=&tag=&folder=. With workspace
+// it lists the links of that workspace, otherwise the personal links of the
+// caller. Several tags select links having all of them, a folder includes
+// its subfolders.
+func (h *Handlers) ListLinks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, ok := h.linkScope(w, r, r.URL.Query().Get("workspace"), false)
+	if !ok {
+		return
+	}
+	var err error
+	if filter.Tags, err = normalizeTags(r.URL.Query()["tag"]); err != nil {
+		errlog.Info("invalid tag filter", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if filter.Folder, err = normalizeFolder(r.URL.Query().Get("folder")); err != nil {
+		errlog.Info("invalid folder filter", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	links, err := h.repo.ListLinks(filter)
+	if err != nil {
+		errlog.Error("error in listing links", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	dtos := make([]linkResponseDTO, 0, len(links))
+	for _, link := range links {
+		dtos = append(dtos, h.toLinkDTO(link))
+	}
+	writeJSON(w, http.StatusOK, dtos)
+}
+
+// ListBrokenLinks handles GET /api/links/broken?workspace=: the links of
+// the caller or of the workspace whose destination no longer works.
+func (h *Handlers) ListBrokenLinks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, ok := h.linkScope(w, r, r.URL.Query().Get("workspace"), false)
+	if !ok {
+		return
+	}
+	filter.Broken = true
+
+	links, err := h.repo.ListLinks(filter)
+	if err != nil {
+		errlog.Error("error in listing broken links", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	dtos := make([]linkResponseDTO, 0, len(links))
+	for _, link := range links {
+		dtos = append(dtos, h.toLinkDTO(link))
+	}
+	writeJSON(w, http.StatusOK, dtos)
+}
+
+// GetLinkHistory handles GET /api/links/{id}/history and lists every
+// destination the link has had, oldest first.
+func (h *Handlers) GetLinkHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	link, ok := h.lookupAPILink(w, r)
+	if !ok {
+		return
+	}
+	if !h.authorize(w, r, authz.ActionView, link) {
+		return
+	}
+
+	respDTO := linkHistoryResponseDTO{
+		ID:       link.ID,
+		Revision: link.Revision,
+		History:  make([]revisionDTO, 0, len(link.History)),
+	}
+	for _, rev := range link.History {
+		respDTO.History = append(respDTO.History, revisionDTO{
+			Revision:   rev.Rev,
+			URL:        rev.URL,
+			Time:       rev.Time,
+			Actor:      rev.Actor,
+			RollbackOf: rev.RollbackOf,
+		})
+	}
+	w.Header().Set("ETag", revisionETag(link.Revision))
+	writeJSON(w, http.StatusOK, respDTO)
+}
+
+// RollbackLink handles POST /api/links/{id}/rollback/{rev}. The restored
+// destination is added to the history as a new revision. If-Match is
+// optional here, when present it must match the current revision.
+func (h *Handlers) RollbackLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	rev, err := strconv.Atoi(r.PathValue("rev"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	link, ok := h.lookupAPILink(w, r)
+	if !ok {
+		return
+	}
+	if !h.authorize(w, r, authz.ActionEdit, link) {
+		return
+	}
+	ifRevision := 0 // без If-Match откатываем любую текущую ревизию
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		ifRevision, ok = parseRevisionETag(ifMatch)
+		if !ok || ifRevision != link.Revision {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+	}
+	if rev >= 1 && rev <= len(link.History) && !h.checkPolicy(link.History[rev-1].URL) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	link, err = h.repo.RollbackAddress(link.Key(), rev, requestActor(r), ifRevision)
+	if err != nil {
+		var noEntry *storage.NoEntryError
+		var noRevision *storage.NoRevisionError
+		var mismatch *storage.RevisionMismatchError
+		switch {
+		case errors.As(err, &noEntry), errors.As(err, &noRevision):
+			w.WriteHeader(http.StatusNotFound)
+		case errors.As(err, &mismatch):
+			// ссылку изменили после проверки If-Match
+			w.WriteHeader(http.StatusPreconditionFailed)
+		default:
+			errlog.Error("error in rolling back address", zap.Error(err))
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		return
+	}
+	if link.Page.FetchedAt.IsZero() {
+		h.fetchMetadata(link.Key(), link.URL)
+	}
+	h.publishLink(r, events.LinkUpdated, link)
+
+	w.Header().Set("ETag", revisionETag(link.Revision))
+	writeJSON(w, http.StatusOK, h.toLinkDTO(link))
+}
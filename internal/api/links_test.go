@@ -0,0 +1,166 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/config"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func patchLink(handlers *Handlers, id, ifMatch, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPatch, "/api/links/"+id, strings.NewReader(body))
+	request = withPrincipal(request, alice)
+	request.SetPathValue("id", id)
+	if ifMatch != "" {
+		request.Header.Set("If-Match", ifMatch)
+	}
+	response := httptest.NewRecorder()
+	handlers.UpdateLink(response, request)
+	return response
+}
+
+func TestUpdateLink(t *testing.T) {
+	repo := storage.New()
+	handlers := New(repo, &config.Config{Domains: []string{"http://localhost:8080"}})
+
+	id, err := repo.AddLink(storage.Link{URL: "https://exmaple.com/typo", CreatedBy: "alice"})
+	require.NoError(t, err)
+
+	request := withPrincipal(httptest.NewRequest(http.MethodGet, "/api/links/"+id, nil), alice)
+	request.SetPathValue("id", id)
+	response := httptest.NewRecorder()
+	handlers.GetLink(response, request)
+	require.Equal(t, http.StatusOK, response.Code)
+	etag := response.Header().Get("ETag")
+	require.Equal(t, `"1"`, etag)
+
+	response = patchLink(handlers, id, "", `{"url":"https://example.com/fixed"}`)
+	require.Equal(t, http.StatusPreconditionRequired, response.Code)
+
+	response = patchLink(handlers, id, etag, `{"url":"https://example.com/fixed"}`)
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, `"2"`, response.Header().Get("ETag"))
+
+	var link linkResponseDTO
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &link))
+	require.Equal(t, "https://example.com/fixed", link.URL)
+	require.Equal(t, "http://localhost:8080/"+id, link.ShortURL)
+	require.Equal(t, 2, link.Revision)
+
+	// второй клиент с устаревшим ETag не должен перезаписать изменения
+	response = patchLink(handlers, id, etag, `{"url":"https://example.com/other"}`)
+	require.Equal(t, http.StatusPreconditionFailed, response.Code)
+
+	fullAddress, err := repo.GetAddress(id)
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/fixed", fullAddress)
+}
+
+func TestUpdateLinkErrors(t *testing.T) {
+	repo := storage.New()
+	handlers := New(repo, &config.Config{Domains: []string{"http://localhost:8080"}},
+		WithPolicy(denyPolicy{host: "evil.com"}))
+
+	id, err := repo.AddLink(storage.Link{URL: "https://example.com/", CreatedBy: "alice"})
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusNotFound, patchLink(handlers, "nope", `"1"`, `{"url":"https://example.com/"}`).Code)
+	require.Equal(t, http.StatusPreconditionFailed, patchLink(handlers, id, `*`, `{"url":"https://example.com/"}`).Code)
+	require.Equal(t, http.StatusBadRequest, patchLink(handlers, id, `"1"`, `{"url":""}`).Code)
+	require.Equal(t, http.StatusBadRequest, patchLink(handlers, id, `"1"`, `not json`).Code)
+	require.Equal(t, http.StatusForbidden, patchLink(handlers, id, `"1"`, `{"url":"https://evil.com/"}`).Code)
+	require.Equal(t, http.StatusOK, patchLink(handlers, id, `W/"1"`, `{"url":"https://example.com/new"}`).Code)
+}
+
+func TestLinkHistoryAndRollback(t *testing.T) {
+	repo := storage.New()
+	handlers := New(repo, &config.Config{Domains: []string{"http://localhost:8080"}})
+
+	id, err := repo.AddLink(storage.Link{URL: "https://example.com/v1", CreatedBy: "alice"})
+	require.NoError(t, err)
+
+	request := httptest.NewRequest(http.MethodPatch, "/api/links/"+id, strings.NewReader(`{"url":"https://example.com/v2"}`))
+	request = withPrincipal(request, alice)
+	request.SetPathValue("id", id)
+	request.Header.Set("If-Match", `"1"`)
+	response := httptest.NewRecorder()
+	handlers.UpdateLink(response, request)
+	require.Equal(t, http.StatusOK, response.Code)
+
+	rollback := func(rev, ifMatch string) *httptest.ResponseRecorder {
+		request := withPrincipal(httptest.NewRequest(http.MethodPost, "/api/links/"+id+"/rollback/"+rev, nil), alice)
+		request.SetPathValue("id", id)
+		request.SetPathValue("rev", rev)
+		if ifMatch != "" {
+			request.Header.Set("If-Match", ifMatch)
+		}
+		response := httptest.NewRecorder()
+		handlers.RollbackLink(response, request)
+		return response
+	}
+
+	require.Equal(t, http.StatusNotFound, rollback("7", "").Code)
+	require.Equal(t, http.StatusBadRequest, rollback("first", "").Code)
+	require.Equal(t, http.StatusPreconditionFailed, rollback("1", `"1"`).Code)
+
+	response = rollback("1", `"2"`)
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, `"3"`, response.Header().Get("ETag"))
+
+	request = withPrincipal(httptest.NewRequest(http.MethodGet, "/api/links/"+id+"/history", nil), alice)
+	request.SetPathValue("id", id)
+	response = httptest.NewRecorder()
+	handlers.GetLinkHistory(response, request)
+	require.Equal(t, http.StatusOK, response.Code)
+
+	var history linkHistoryResponseDTO
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &history))
+	require.Equal(t, 3, history.Revision)
+	require.Len(t, history.History, 3)
+	require.Equal(t, "https://example.com/v2", history.History[1].URL)
+	require.Equal(t, "alice", history.History[1].Actor)
+	require.Equal(t, "https://example.com/v1", history.History[2].URL)
+	require.Equal(t, 1, history.History[2].RollbackOf)
+
+	fullAddress, err := repo.GetAddress(id)
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/v1", fullAddress)
+}
+
+func TestListBrokenLinks(t *testing.T) {
+	repo := storage.New()
+	handlers := New(repo, &config.Config{Domains: []string{"http://localhost:8080"}})
+
+	deck, err := repo.AddLink(storage.Link{URL: "https://example.com/deck", CreatedBy: alice.Actor()})
+	require.NoError(t, err)
+	_, err = repo.AddLink(storage.Link{URL: "https://example.com/ok", CreatedBy: alice.Actor()})
+	require.NoError(t, err)
+	bobs, err := repo.AddLink(storage.Link{URL: "https://example.com/bob", CreatedBy: bob.Actor()})
+	require.NoError(t, err)
+	for name, url := range map[string]string{deck: "https://example.com/deck", bobs: "https://example.com/bob"} {
+		for i := 0; i < storage.BrokenAfter; i++ {
+			_, err = repo.AddCheck(name, url, storage.CheckResult{Time: time.Now(), Status: http.StatusNotFound, Broken: true})
+			require.NoError(t, err)
+		}
+	}
+
+	response := call(handlers.ListBrokenLinks, alice, http.MethodGet, "/api/links/broken", "")
+	require.Equal(t, http.StatusOK, response.Code)
+	var links []linkResponseDTO
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &links))
+	require.Len(t, links, 1)
+	require.Equal(t, deck, links[0].ID)
+	require.True(t, links[0].Health.Broken)
+	require.NotNil(t, links[0].Health.BrokenSince)
+	require.Len(t, links[0].Health.Checks, storage.BrokenAfter)
+	require.Equal(t, http.StatusNotFound, links[0].Health.Checks[0].Status)
+
+	response = call(handlers.ListBrokenLinks, nil, http.MethodGet, "/api/links/broken", "")
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+}
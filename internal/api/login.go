@@ -0,0 +1,187 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/adettelle/go-url-shortener/internal/auth"
+	"github.com/adettelle/go-url-shortener/internal/authz"
+	"github.com/adettelle/go-url-shortener/internal/oidc"
+	"github.com/adettelle/go-url-shortener/internal/session"
+	"go.uber.org/zap"
+)
+
+const (
+	loginStateCookie = "oidc_state"
+	loginStateMaxAge = 10 * 60 // секунды, как и срок жизни начатого входа
+)
+
+// LoginProvider runs the single sign-on flow.
+type LoginProvider interface {
+	StartLogin(returnTo string) (state, authURL string, err error)
+	FinishLogin(ctx context.Context, state, code string) (*oidc.Claims, string, error)
+}
+
+// SessionStore keeps browser sessions.
+type SessionStore interface {
+	New(p auth.Principal) (*session.Session, error)
+	Delete(id string)
+}
+
+// WithLogin enables the /auth endpoints.
+func WithLogin(provider LoginProvider, sessions SessionStore) Option {
+	return func(h *Handlers) {
+		h.login = provider
+		h.sessions = sessions
+	}
+}
+
+type meResponseDTO struct {
+	UserID   string   `json:"user_id,omitempty"`
+	APIKeyID string   `json:"api_key_id,omitempty"`
+	Email    string   `json:"email,omitempty"`
+	Name     string   `json:"name,omitempty"`
+	Scopes   []string `json:"scopes"`
+}
+
+// secureCookies tells whether the service is reached over HTTPS.
+func (h *Handlers) secureCookies() bool {
+	return strings.HasPrefix(h.domains.Default().Base, "https://")
+}
+
+// safeReturnTo accepts only local paths, so the login can't be used
+// as an open redirect.
+func safeReturnTo(v string) string {
+	if !strings.HasPrefix(v, "/") || strings.HasPrefix(v, "//") || strings.HasPrefix(v, "/\\") {
+		return "/"
+	}
+	return v
+}
+
+// Login handles GET /auth/login?return_to=/path and sends the browser
+// to the identity provider.
+func (h *Handlers) Login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorize(w, r, authz.ActionSignIn, nil) {
+		return
+	}
+	if h.login == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	state, authURL, err := h.login.StartLogin(safeReturnTo(r.URL.Query().Get("return_to")))
+	if err != nil {
+		errlog.Error("error in starting login", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	// cookie привязывает state к браузеру, начавшему вход
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginStateCookie,
+		Value:    state,
+		Path:     "/auth",
+		MaxAge:   loginStateMaxAge,
+		HttpOnly: true,
+		Secure:   h.secureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// LoginCallback handles GET /auth/callback, the redirect back from the
+// identity provider. On success it starts a session.
+func (h *Handlers) LoginCallback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorize(w, r, authz.ActionSignIn, nil) {
+		return
+	}
+	if h.login == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		errlog.Info("login refused by provider", zap.String("error", e),
+			zap.String("description", q.Get("error_description")))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	state := q.Get("state")
+	cookie, err := r.Cookie(loginStateCookie)
+	if err != nil || state == "" || cookie.Value != state {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: loginStateCookie, Path: "/auth", MaxAge: -1})
+
+	claims, returnTo, err := h.login.FinishLogin(r.Context(), state, q.Get("code"))
+	if err != nil {
+		errlog.Info("login failed", zap.Error(err))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	sess, err := h.sessions.New(*claims.Principal())
+	if err != nil {
+		errlog.Error("error in creating session", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	errlog.Info("user signed in", zap.String("user", claims.Subject), zap.String("email", claims.Email))
+
+	http.SetCookie(w, session.Cookie(sess, h.secureCookies()))
+	http.Redirect(w, r, returnTo, http.StatusSeeOther)
+}
+
+// Logout handles POST /auth/logout and ends the session.
+func (h *Handlers) Logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorize(w, r, authz.ActionSignIn, nil) {
+		return
+	}
+	if h.sessions == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	if cookie, err := r.Cookie(session.CookieName); err == nil {
+		h.sessions.Delete(cookie.Value)
+	}
+	http.SetCookie(w, &http.Cookie{Name: session.CookieName, Path: "/", MaxAge: -1})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Me handles GET /auth/me and describes the caller.
+func (h *Handlers) Me(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorize(w, r, authz.ActionSignIn, nil) {
+		return
+	}
+
+	p := auth.FromContext(r.Context())
+	if p == nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	writeJSON(w, http.StatusOK, meResponseDTO{
+		UserID:   p.UserID,
+		APIKeyID: p.APIKeyID,
+		Email:    p.Email,
+		Name:     p.Name,
+		Scopes:   p.Scopes,
+	})
+}
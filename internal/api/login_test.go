@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/auth"
+	"github.com/adettelle/go-url-shortener/internal/config"
+	"github.com/adettelle/go-url-shortener/internal/oidc"
+	"github.com/adettelle/go-url-shortener/internal/oidc/oidctest"
+	"github.com/adettelle/go-url-shortener/internal/session"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func newLoginHandlers(t *testing.T) (*Handlers, *session.Store) {
+	t.Helper()
+	idp := oidctest.NewProvider()
+	t.Cleanup(idp.Close)
+
+	client, err := oidc.New(context.Background(), oidc.Config{
+		Issuer:       idp.Issuer(),
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  "https://short.example/auth/callback",
+	})
+	require.NoError(t, err)
+	sessions := session.NewStore(time.Hour)
+	cfg := &config.Config{Domains: []string{"https://short.example"}}
+	return New(storage.New(), cfg, WithLogin(client, sessions)), sessions
+}
+
+func TestLoginFlow(t *testing.T) {
+	handlers, sessions := newLoginHandlers(t)
+
+	request := httptest.NewRequest(http.MethodGet, "/auth/login?return_to=/api/links/abc", nil)
+	response := httptest.NewRecorder()
+	handlers.Login(response, request)
+	require.Equal(t, http.StatusFound, response.Code)
+	stateCookie := response.Result().Cookies()[0]
+	require.Equal(t, loginStateCookie, stateCookie.Name)
+	require.True(t, stateCookie.HttpOnly)
+	require.True(t, stateCookie.Secure)
+
+	// браузер проходит вход у провайдера
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := noRedirect.Get(response.Header().Get("Location"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+
+	request = httptest.NewRequest(http.MethodGet, "/auth/callback?"+callback.RawQuery, nil)
+	request.AddCookie(stateCookie)
+	response = httptest.NewRecorder()
+	handlers.LoginCallback(response, request)
+	require.Equal(t, http.StatusSeeOther, response.Code)
+	require.Equal(t, "/api/links/abc", response.Header().Get("Location"))
+
+	var sessionCookie *http.Cookie
+	for _, c := range response.Result().Cookies() {
+		if c.Name == session.CookieName {
+			sessionCookie = c
+		}
+	}
+	require.NotNil(t, sessionCookie)
+	require.True(t, sessionCookie.HttpOnly)
+
+	request = httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+	request.AddCookie(sessionCookie)
+	p, ok := sessions.Principal(request)
+	require.True(t, ok)
+	require.Equal(t, "alice", p.UserID)
+
+	response = httptest.NewRecorder()
+	handlers.Me(response, request.WithContext(auth.NewContext(request.Context(), p)))
+	require.Equal(t, http.StatusOK, response.Code)
+	var me meResponseDTO
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &me))
+	require.Equal(t, "alice@example.com", me.Email)
+
+	request = httptest.NewRequest(http.MethodPost, "/auth/logout", nil)
+	request.AddCookie(sessionCookie)
+	response = httptest.NewRecorder()
+	handlers.Logout(response, request)
+	require.Equal(t, http.StatusNoContent, response.Code)
+	_, ok = sessions.Principal(request)
+	require.False(t, ok)
+}
+
+func TestLoginCallbackStateMismatch(t *testing.T) {
+	handlers, _ := newLoginHandlers(t)
+
+	request := httptest.NewRequest(http.MethodGet, "/auth/callback?state=abc&code=x", nil)
+	request.AddCookie(&http.Cookie{Name: loginStateCookie, Value: "other"})
+	response := httptest.NewRecorder()
+	handlers.LoginCallback(response, request)
+	require.Equal(t, http.StatusBadRequest, response.Code)
+
+	request = httptest.NewRequest(http.MethodGet, "/auth/callback?error=access_denied", nil)
+	response = httptest.NewRecorder()
+	handlers.LoginCallback(response, request)
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+}
+
+func TestSafeReturnTo(t *testing.T) {
+	require.Equal(t, "/api/links", safeReturnTo("/api/links"))
+	require.Equal(t, "/", safeReturnTo(""))
+	require.Equal(t, "/", safeReturnTo("https://evil.example/"))
+	require.Equal(t, "/", safeReturnTo("//evil.example/"))
+	require.Equal(t, "/", safeReturnTo("/\\evil.example/"))
+}
+
+func TestMeAnonymous(t *testing.T) {
+	handlers := New(storage.New(), nil)
+	response := httptest.NewRecorder()
+	handlers.Me(response, httptest.NewRequest(http.MethodGet, "/auth/me", nil))
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/adettelle/go-url-shortener/internal/config"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxClicksConcurrentRedirects(t *testing.T) {
+	const maxClicks = 5
+	handlers := New(storage.New(), &config.Config{Domains: []string{"http://localhost:8080"}})
+
+	request := httptest.NewRequest(http.MethodPost, "/api/shorten",
+		strings.NewReader(`{"url":"https://onboarding.example.com/welcome","max_clicks":5}`))
+	response := httptest.NewRecorder()
+	handlers.CreateShortAddressJSON(response, request)
+	require.Equal(t, http.StatusCreated, response.Code)
+
+	var resp shortAddrCreateResponseDTO
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &resp))
+	id := strings.TrimPrefix(resp.Result, "http://localhost:8080/")
+
+	var mu sync.Mutex
+	codes := make(map[int]int)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			request := httptest.NewRequest(http.MethodGet, "/"+id, nil)
+			request.SetPathValue("id", id)
+			response := httptest.NewRecorder()
+			handlers.GetFullAddress(response, request)
+
+			mu.Lock()
+			codes[response.Code]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, map[int]int{
+		http.StatusTemporaryRedirect: maxClicks,
+		http.StatusGone:              50 - maxClicks,
+	}, codes)
+}
+
+func TestCreateShortAddressJSONNegativeMaxClicks(t *testing.T) {
+	handlers := New(storage.New(), &config.Config{Domains: []string{"http://localhost:8080"}})
+
+	request := httptest.NewRequest(http.MethodPost, "/api/shorten",
+		strings.NewReader(`{"url":"https://example.com/","max_clicks":-1}`))
+	response := httptest.NewRecorder()
+	handlers.CreateShortAddressJSON(response, request)
+	require.Equal(t, http.StatusBadRequest, response.Code)
+}
+
+func TestBlockedRedirectKeepsClicks(t *testing.T) {
+	repo := storage.New()
+	handlers := New(repo, &config.Config{Domains: []string{"http://localhost:8080"}},
+		WithPolicy(denyPolicy{host: "evil.com"}))
+	// вариант попал в денайлист уже после создания ссылки
+	id, err := repo.AddLink(storage.Link{URL: "https://example.com/welcome", MaxClicks: 1, RemainingClicks: 1,
+		Variants: []storage.Variant{{Name: "b", URL: "https://evil.com/welcome", Weight: 1}}})
+	require.NoError(t, err)
+
+	response := call(handlers.GetFullAddress, nil, http.MethodGet, "/"+id, "", "id", id)
+	require.Equal(t, http.StatusForbidden, response.Code)
+
+	link, err := repo.GetLink(id)
+	require.NoError(t, err)
+	require.Equal(t, 1, link.RemainingClicks)
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"go.uber.org/zap"
+)
+
+// MetadataQueue fetches the metadata of destination pages in the background.
+type MetadataQueue interface {
+	Enqueue(name, rawURL string) bool
+}
+
+// WithMetadata makes handlers queue the destination of every new or edited
+// link to q, so that listings can show the page title and icon.
+func WithMetadata(q MetadataQueue) Option {
+	return func(h *Handlers) {
+		h.metadata = q
+	}
+}
+
+type pageDTO struct {
+	Title       string    `json:"title,omitempty"`
+	Description string    `json:"description,omitempty"`
+	Favicon     string    `json:"favicon,omitempty"`
+	Image       string    `json:"image,omitempty"`
+	FetchedAt   time.Time `json:"fetched_at"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// toPageDTO returns nil until the metadata of the page is fetched.
+func toPageDTO(meta storage.PageMeta) *pageDTO {
+	if meta.FetchedAt.IsZero() {
+		return nil
+	}
+	return &pageDTO{
+		Title:       meta.Title,
+		Description: meta.Description,
+		Favicon:     meta.Favicon,
+		Image:       meta.Image,
+		FetchedAt:   meta.FetchedAt,
+		Error:       meta.Error,
+	}
+}
+
+// fetchMetadata queues the destination of the link stored under name.
+func (h *Handlers) fetchMetadata(name, rawURL string) {
+	if h.metadata == nil {
+		return
+	}
+	if !h.metadata.Enqueue(name, rawURL) {
+		// страницу загрузит периодическое обновление
+		errlog.Info("metadata queue is full", zap.String("link", name))
+	}
+}
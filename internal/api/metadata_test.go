@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/config"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+type metadataQueueFunc func(name, rawURL string) bool
+
+func (f metadataQueueFunc) Enqueue(name, rawURL string) bool {
+	return f(name, rawURL)
+}
+
+func TestLinkMetadata(t *testing.T) {
+	repo := storage.New()
+	queued := map[string]string{}
+	handlers := New(repo, &config.Config{Domains: []string{"http://localhost:8080", "https://brand-b.link"}},
+		WithMetadata(metadataQueueFunc(func(name, rawURL string) bool {
+			queued[name] = rawURL
+			return true
+		})))
+
+	response := call(handlers.CreateShortAddressJSON, alice, http.MethodPost, "/api/shorten",
+		`{"url":"https://example.com/deck","alias":"deck","domain":"brand-b.link"}`)
+	require.Equal(t, http.StatusCreated, response.Code)
+	require.Equal(t, map[string]string{"brand-b.link/deck": "https://example.com/deck"}, queued)
+
+	response = call(handlers.GetLink, alice, http.MethodGet, "/?domain=brand-b.link", "", "id", "deck")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.NotContains(t, response.Body.String(), `"page"`)
+
+	err := repo.SetPageMeta("brand-b.link/deck", "https://example.com/deck", storage.PageMeta{
+		Title:     "Q3 deck",
+		Favicon:   "https://example.com/favicon.ico",
+		FetchedAt: time.Now(),
+	})
+	require.NoError(t, err)
+	response = call(handlers.GetLink, alice, http.MethodGet, "/?domain=brand-b.link", "", "id", "deck")
+	require.Equal(t, http.StatusOK, response.Code)
+	var link linkResponseDTO
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &link))
+	require.Equal(t, "Q3 deck", link.Title)
+	require.Equal(t, "https://example.com/favicon.ico", link.Page.Favicon)
+
+	// новый адрес — новая страница
+	delete(queued, "brand-b.link/deck")
+	request := httptest.NewRequest(http.MethodPatch, "/?domain=brand-b.link", strings.NewReader(`{"url":"https://example.com/deck-v2"}`))
+	request.SetPathValue("id", "deck")
+	request.Header.Set("If-Match", `"1"`)
+	response = httptest.NewRecorder()
+	handlers.UpdateLink(response, withPrincipal(request, alice))
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, map[string]string{"brand-b.link/deck": "https://example.com/deck-v2"}, queued)
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/authz"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/adettelle/go-url-shortener/internal/unlock"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultUnlockTTL      = 15 * time.Minute
+	defaultUnlockAttempts = 5
+	defaultUnlockWindow   = 15 * time.Minute
+	unlockCookiePrefix    = "unlock_"
+	maxPasswordFormSize   = 4096
+)
+
+var passwordFormTemplate = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Password required</title>
+</head>
+<body>
+<form method="post">
+<p>This link is protected. Enter the password to continue.</p>
+{{if .}}<p role="alert">{{.}}</p>{{end}}
+<input type="password" name="password" autofocus required>
+<button type="submit">Open link</button>
+</form>
+</body>
+</html>
+`))
+
+// WithUnlock sets the signer of unlock cookies and the password attempts throttle.
+func WithUnlock(signer *unlock.Signer, throttle *unlock.Throttle) Option {
+	return func(h *Handlers) {
+		h.unlockSigner = signer
+		h.unlockThrottle = throttle
+	}
+}
+
+// UnlockLink handles POST /{id} with the password form of a protected link.
+// On success it sets a short-lived unlock cookie and sends the visitor back
+// to GET /{id}.
+func (h *Handlers) UnlockLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, _ := parseLinkID(r)
+	key := storage.Key(h.requestDomain(r).Name, id)
+	link, ok := h.lookupLink(w, key)
+	if !ok {
+		return
+	}
+	if !h.authorize(w, r, authz.ActionFollow, link) {
+		return
+	}
+	if link.PasswordHash == "" {
+		http.Redirect(w, r, "/"+id, http.StatusSeeOther)
+		return
+	}
+	// попытки считаются только для существующих ссылок с паролем, иначе
+	// запросы к случайным id раздували бы счётчики
+	if ok, wait := h.unlockThrottle.Allow(key); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		h.renderPasswordForm(w, http.StatusTooManyRequests, "Too many attempts, try again later.")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPasswordFormSize)
+	password := r.PostFormValue("password")
+	err := bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password))
+	if err != nil {
+		errlog.Info("wrong link password", zap.String("id", id))
+		h.renderPasswordForm(w, http.StatusUnauthorized, "Wrong password.")
+		return
+	}
+
+	h.unlockThrottle.Reset(key)
+
+	token, expires := h.unlockSigner.Token(id, passwordFingerprint(link))
+	http.SetCookie(w, &http.Cookie{
+		Name:     unlockCookiePrefix + id,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		// TLS обычно завершается на прокси, поэтому смотрим на схему домена
+		Secure:   strings.HasPrefix(h.requestDomain(r).Base, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, "/"+id, http.StatusSeeOther)
+}
+
+func (h *Handlers) isUnlocked(r *http.Request, link *storage.Link) bool {
+	cookie, err := r.Cookie(unlockCookiePrefix + link.ID)
+	if err != nil {
+		return false
+	}
+	return h.unlockSigner.Valid(cookie.Value, link.ID, passwordFingerprint(link))
+}
+
+// passwordFingerprint binds unlock cookies to the current password.
+func passwordFingerprint(link *storage.Link) string {
+	sum := sha256.Sum256([]byte(link.PasswordHash))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (h *Handlers) renderPasswordForm(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := passwordFormTemplate.Execute(w, message); err != nil {
+		errlog.Error("error in rendering password form", zap.Error(err))
+	}
+}
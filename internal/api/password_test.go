@@ -0,0 +1,140 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/config"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/adettelle/go-url-shortener/internal/unlock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func createProtectedLink(t *testing.T, handlers *Handlers, password string) string {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodPost, "/api/shorten",
+		strings.NewReader(`{"url":"https://wiki.example.com/internal","password":"`+password+`"}`))
+	response := httptest.NewRecorder()
+	handlers.CreateShortAddressJSON(response, request)
+	require.Equal(t, http.StatusCreated, response.Code)
+
+	var resp shortAddrCreateResponseDTO
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &resp))
+	return strings.TrimPrefix(resp.Result, "http://localhost:8080/")
+}
+
+func submitPassword(handlers *Handlers, id, password string) *httptest.ResponseRecorder {
+	form := url.Values{"password": {password}}
+	request := httptest.NewRequest(http.MethodPost, "/"+id, strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.SetPathValue("id", id)
+	response := httptest.NewRecorder()
+	handlers.UnlockLink(response, request)
+	return response
+}
+
+func TestPasswordProtectedLink(t *testing.T) {
+	repo := storage.New()
+	handlers := New(repo, &config.Config{Domains: []string{"http://localhost:8080"}})
+	id := createProtectedLink(t, handlers, "s3cret")
+
+	link, err := repo.GetLink(id)
+	require.NoError(t, err)
+	require.NotEqual(t, "s3cret", link.PasswordHash)
+
+	// без пароля показывается форма, а не редирект
+	request := httptest.NewRequest(http.MethodGet, "/"+id, nil)
+	request.SetPathValue("id", id)
+	response := httptest.NewRecorder()
+	handlers.GetFullAddress(response, request)
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Empty(t, response.Header().Get("Location"))
+	require.Contains(t, response.Body.String(), `<form method="post">`)
+
+	response = submitPassword(handlers, id, "wrong")
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+	require.Empty(t, response.Result().Cookies())
+
+	response = submitPassword(handlers, id, "s3cret")
+	require.Equal(t, http.StatusSeeOther, response.Code)
+	require.Equal(t, "/"+id, response.Header().Get("Location"))
+	cookies := response.Result().Cookies()
+	require.Len(t, cookies, 1)
+	require.True(t, cookies[0].HttpOnly)
+	require.False(t, cookies[0].Secure)
+
+	request = httptest.NewRequest(http.MethodGet, "/"+id, nil)
+	request.SetPathValue("id", id)
+	request.AddCookie(cookies[0])
+	response = httptest.NewRecorder()
+	handlers.GetFullAddress(response, request)
+	require.Equal(t, http.StatusTemporaryRedirect, response.Code)
+	require.Equal(t, "https://wiki.example.com/internal", response.Header().Get("Location"))
+}
+
+func TestPasswordAttemptsThrottled(t *testing.T) {
+	repo := storage.New()
+	handlers := New(repo, &config.Config{Domains: []string{"http://localhost:8080"}},
+		WithUnlock(unlock.NewSigner([]byte("secret"), time.Minute), unlock.NewThrottle(2, time.Hour)))
+	id := createProtectedLink(t, handlers, "s3cret")
+
+	require.Equal(t, http.StatusUnauthorized, submitPassword(handlers, id, "a").Code)
+	require.Equal(t, http.StatusUnauthorized, submitPassword(handlers, id, "b").Code)
+
+	response := submitPassword(handlers, id, "s3cret")
+	require.Equal(t, http.StatusTooManyRequests, response.Code)
+	// проверка bcrypt может занять секунды, окно отсчитывается от первой попытки
+	retryAfter, err := strconv.Atoi(response.Header().Get("Retry-After"))
+	require.NoError(t, err)
+	require.InDelta(t, 3600, retryAfter, 30)
+}
+
+func TestPasswordSuccessResetsAttempts(t *testing.T) {
+	repo := storage.New()
+	handlers := New(repo, &config.Config{Domains: []string{"http://localhost:8080"}},
+		WithUnlock(unlock.NewSigner([]byte("secret"), time.Minute), unlock.NewThrottle(2, time.Hour)))
+	id := createProtectedLink(t, handlers, "s3cret")
+
+	require.Equal(t, http.StatusUnauthorized, submitPassword(handlers, id, "a").Code)
+	require.Equal(t, http.StatusSeeOther, submitPassword(handlers, id, "s3cret").Code)
+	require.Equal(t, http.StatusUnauthorized, submitPassword(handlers, id, "b").Code)
+	require.Equal(t, http.StatusUnauthorized, submitPassword(handlers, id, "c").Code)
+	require.Equal(t, http.StatusTooManyRequests, submitPassword(handlers, id, "s3cret").Code)
+}
+
+func TestPasswordAttemptsCountedForProtectedLinksOnly(t *testing.T) {
+	repo := storage.New()
+	handlers := New(repo, &config.Config{Domains: []string{"http://localhost:8080"}},
+		WithUnlock(unlock.NewSigner([]byte("secret"), time.Minute), unlock.NewThrottle(1, time.Hour)))
+	open, err := repo.AddLink(storage.Link{URL: "https://example.com/"})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.Equal(t, http.StatusNotFound, submitPassword(handlers, "nope", "a").Code)
+		require.Equal(t, http.StatusSeeOther, submitPassword(handlers, open, "a").Code)
+	}
+}
+
+func TestUnlockCookieSecureBehindProxy(t *testing.T) {
+	repo := storage.New()
+	handlers := New(repo, &config.Config{Domains: []string{"https://go.example.com"}})
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	require.NoError(t, err)
+	id, err := repo.AddLink(storage.Link{URL: "https://wiki.example.com/internal", PasswordHash: string(hash)})
+	require.NoError(t, err)
+
+	// прокси принимает HTTPS и передаёт запрос по HTTP, r.TLS пуст
+	response := submitPassword(handlers, id, "s3cret")
+	require.Equal(t, http.StatusSeeOther, response.Code)
+	cookies := response.Result().Cookies()
+	require.Len(t, cookies, 1)
+	require.True(t, cookies[0].Secure)
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/analytics"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"go.uber.org/zap"
+)
+
+var previewTemplate = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Link preview</title>
+</head>
+<body>
+<h1>This short link leads to</h1>
+<p><a href="{{.URL}}" rel="noopener noreferrer nofollow">{{.URL}}</a></p>
+<dl>
+<dt>Created</dt><dd>{{.CreatedAt.Format "2006-01-02 15:04 MST"}}</dd>
+{{if .HasClicks}}<dt>Clicks</dt><dd>{{.Clicks}}</dd>{{end}}
+</dl>
+</body>
+</html>
+`))
+
+type previewData struct {
+	URL       string
+	CreatedAt time.Time
+	HasClicks bool
+	Clicks    int
+}
+
+// renderPreview shows where link leads instead of redirecting.
+// Previews are not counted as clicks.
+func (h *Handlers) renderPreview(w http.ResponseWriter, r *http.Request, link *storage.Link) {
+	data := previewData{URL: link.URL, CreatedAt: link.CreatedAt.UTC()}
+
+	if h.stats != nil {
+		stats, err := h.stats.Stats(r.Context(), analytics.StatsQuery{
+			ShortID: link.Key(),
+			From:    link.CreatedAt,
+			To:      h.clock().Add(time.Second),
+		})
+		if err != nil {
+			// страница полезна и без счётчика
+			errlog.Error("error in getting stats", zap.Error(err))
+		} else {
+			data.HasClicks = true
+			data.Clicks = stats.TotalClicks
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.WriteHeader(http.StatusOK)
+	if r.Method == http.MethodHead {
+		return
+	}
+	if err := previewTemplate.Execute(w, data); err != nil {
+		errlog.Error("error in rendering preview", zap.Error(err))
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/analytics"
+	"github.com/adettelle/go-url-shortener/internal/mocks"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeadDoesNotCountClick(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mocks.NewMockStorager(ctrl)
+	sink := &clickSink{}
+	handlers := New(mockStorage, nil, WithClickRecorder(sink, "salt"))
+
+	// UseClick не ожидается: HEAD не тратит переходы
+	link := &storage.Link{ID: "abc", URL: "https://example.com/", MaxClicks: 1, RemainingClicks: 1}
+	mockStorage.EXPECT().GetLink("abc").Return(link, nil)
+
+	request := httptest.NewRequest(http.MethodHead, "/abc", nil)
+	request.SetPathValue("id", "abc")
+	response := httptest.NewRecorder()
+	handlers.GetFullAddress(response, request)
+
+	require.Equal(t, http.StatusTemporaryRedirect, response.Code)
+	require.Equal(t, "https://example.com/", response.Header().Get("Location"))
+	require.Empty(t, sink.clicks)
+
+	exhausted := &storage.Link{ID: "abc", URL: "https://example.com/", MaxClicks: 1}
+	mockStorage.EXPECT().GetLink("abc").Return(exhausted, nil)
+	response = httptest.NewRecorder()
+	handlers.GetFullAddress(response, request)
+	require.Equal(t, http.StatusGone, response.Code)
+}
+
+func TestPreviewPage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clickStore := analytics.NewMemoryStore()
+	require.NoError(t, clickStore.SaveClicks(context.Background(), []analytics.Click{
+		{ShortID: "abc", Time: created.Add(time.Hour)},
+		{ShortID: "abc", Time: created.Add(2 * time.Hour)},
+	}))
+
+	mockStorage := mocks.NewMockStorager(ctrl)
+	sink := &clickSink{}
+	handlers := New(mockStorage, nil,
+		WithStats(clickStore),
+		WithClickRecorder(sink, "salt"),
+		WithClock(func() time.Time { return created.Add(24 * time.Hour) }))
+
+	link := &storage.Link{ID: "abc", URL: `https://example.com/?q=<script>`, CreatedAt: created}
+	mockStorage.EXPECT().GetLink("abc").Return(link, nil).Times(2)
+
+	for _, target := range []string{"/abc+", "/abc?preview=1"} {
+		request := httptest.NewRequest(http.MethodGet, target, nil)
+		if target == "/abc+" {
+			request.SetPathValue("id", "abc+")
+		} else {
+			request.SetPathValue("id", "abc")
+		}
+		response := httptest.NewRecorder()
+		handlers.GetFullAddress(response, request)
+
+		require.Equal(t, http.StatusOK, response.Code, target)
+		require.Empty(t, response.Header().Get("Location"))
+		body := response.Body.String()
+		require.Contains(t, body, "https://example.com/?q=&lt;script&gt;")
+		require.NotContains(t, body, "<script>")
+		require.Contains(t, body, "2024-05-01 12:00 UTC")
+		require.Contains(t, body, "<dd>2</dd>")
+	}
+	require.Empty(t, sink.clicks)
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/adettelle/go-url-shortener/internal/authz"
+	"github.com/adettelle/go-url-shortener/internal/qr"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"go.uber.org/zap"
+)
+
+// QR codes of a link never change, so clients and proxies may cache them for a day.
+const qrCacheControl = "public, max-age=86400"
+
+// GetQRCode handles GET /{id}/qr?format=png|svg&size=&level=&margin=
+// and renders a QR code of the short URL.
+func (h *Handlers) GetQRCode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.authorize(w, r, authz.ActionFollow, nil) {
+		return
+	}
+
+	d := h.requestDomain(r)
+	id := r.PathValue("id")
+	_, err := h.repo.GetAddress(storage.Key(d.Name, id))
+	if err != nil {
+		var noEntry *storage.NoEntryError
+		if errors.As(err, &noEntry) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		errlog.Error("error in getting address", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "png"
+	}
+	if format != "png" && format != "svg" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	opts, err := parseQROptions(r)
+	if err != nil {
+		errlog.Info("invalid qr options", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	shortenAddress := d.ShortURL(id)
+	etag := qrETag(shortenAddress, format, opts)
+	w.Header().Set("Cache-Control", qrCacheControl)
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	var image []byte
+	contentType := "image/png"
+	if format == "svg" {
+		image, err = qr.SVG(shortenAddress, opts)
+		contentType = "image/svg+xml"
+	} else {
+		image, err = qr.PNG(shortenAddress, opts)
+	}
+	if err != nil {
+		errlog.Error("error in rendering qr code", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(image)))
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(image)
+	if err != nil {
+		errlog.Error("error in writing response", zap.Error(err))
+	}
+}
+
+func parseQROptions(r *http.Request) (qr.Options, error) {
+	opts := qr.DefaultOptions()
+	query := r.URL.Query()
+
+	var err error
+	if v := query.Get("size"); v != "" {
+		if opts.Size, err = strconv.Atoi(v); err != nil {
+			return opts, err
+		}
+	}
+	if v := query.Get("margin"); v != "" {
+		if opts.Margin, err = strconv.Atoi(v); err != nil {
+			return opts, err
+		}
+	}
+	if v := query.Get("level"); v != "" {
+		// уровень входит в ETag, m и M дают одну и ту же картинку
+		opts.Level = strings.ToUpper(v)
+	}
+	return opts, opts.Validate()
+}
+
+func qrETag(content, format string, opts qr.Options) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d|%s|%d", content, format, opts.Size, opts.Level, opts.Margin)))
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// qrDataURL renders a PNG QR code with default options as a data: URL,
+// ready to be used in an <img> tag.
+func qrDataURL(content string) (string, error) {
+	image, err := qr.PNG(content, qr.DefaultOptions())
+	if err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(image), nil
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adettelle/go-url-shortener/internal/config"
+	"github.com/adettelle/go-url-shortener/internal/mocks"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetQRCode(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mocks.NewMockStorager(ctrl)
+	cfg := &config.Config{Address: "localhost:8080", Domains: []string{"http://localhost:8080"}}
+	handlers := New(mockStorage, cfg)
+
+	id := "qqVjJVf"
+	mockStorage.EXPECT().GetAddress(id).Return("https://practicum.yandex.ru/", nil).Times(4)
+
+	request := httptest.NewRequest(http.MethodGet, "/"+id+"/qr", nil)
+	request.SetPathValue("id", id)
+	response := httptest.NewRecorder()
+	handlers.GetQRCode(response, request)
+
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "image/png", response.Header().Get("Content-Type"))
+	require.Equal(t, qrCacheControl, response.Header().Get("Cache-Control"))
+	etag := response.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	request = httptest.NewRequest(http.MethodGet, "/"+id+"/qr", nil)
+	request.SetPathValue("id", id)
+	request.Header.Set("If-None-Match", etag)
+	response = httptest.NewRecorder()
+	handlers.GetQRCode(response, request)
+	require.Equal(t, http.StatusNotModified, response.Code)
+
+	// M по умолчанию, регистр уровня не меняет картинку
+	request = httptest.NewRequest(http.MethodGet, "/"+id+"/qr?level=m", nil)
+	request.SetPathValue("id", id)
+	request.Header.Set("If-None-Match", etag)
+	response = httptest.NewRecorder()
+	handlers.GetQRCode(response, request)
+	require.Equal(t, http.StatusNotModified, response.Code)
+
+	request = httptest.NewRequest(http.MethodGet, "/"+id+"/qr?format=svg&size=512&level=H&margin=0", nil)
+	request.SetPathValue("id", id)
+	response = httptest.NewRecorder()
+	handlers.GetQRCode(response, request)
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "image/svg+xml", response.Header().Get("Content-Type"))
+	require.NotEqual(t, etag, response.Header().Get("ETag"))
+}
+
+func TestGetQRCodeInvalidOptions(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mocks.NewMockStorager(ctrl)
+	handlers := New(mockStorage, &config.Config{Domains: []string{"http://localhost:8080"}})
+
+	for _, query := range []string{"format=gif", "size=5", "level=Z", "margin=x"} {
+		mockStorage.EXPECT().GetAddress("abc").Return("https://practicum.yandex.ru/", nil)
+
+		request := httptest.NewRequest(http.MethodGet, "/abc/qr?"+query, nil)
+		request.SetPathValue("id", "abc")
+		response := httptest.NewRecorder()
+		handlers.GetQRCode(response, request)
+		require.Equal(t, http.StatusBadRequest, response.Code, query)
+	}
+}
+
+func TestCreateShortAddressJSONWithQR(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mocks.NewMockStorager(ctrl)
+	handlers := New(mockStorage, &config.Config{Domains: []string{"http://localhost:8080"}})
+
+	mockStorage.EXPECT().AddLink(storage.Link{URL: "https://practicum.yandex.ru/"}).Return("qqVjJVf", nil)
+
+	request := httptest.NewRequest(http.MethodPost, "/api/shorten",
+		strings.NewReader(`{"url":"https://practicum.yandex.ru/","qr":true}`))
+	response := httptest.NewRecorder()
+	handlers.CreateShortAddressJSON(response, request)
+
+	require.Equal(t, http.StatusCreated, response.Code)
+	var resp shortAddrCreateResponseDTO
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &resp))
+	require.Equal(t, "http://localhost:8080/qqVjJVf", resp.Result)
+	require.True(t, strings.HasPrefix(resp.QR, "data:image/png;base64,"))
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/adettelle/go-url-shortener/internal/storage"
+)
+
+// utmParams are the only keys allowed in per-link UTM defaults.
+var utmParams = map[string]bool{
+	"utm_source":   true,
+	"utm_medium":   true,
+	"utm_campaign": true,
+	"utm_term":     true,
+	"utm_content":  true,
+}
+
+const (
+	conflictLinkWins    = "link"
+	conflictVisitorWins = "visitor"
+)
+
+type queryOptionsDTO struct {
+	Passthrough bool              `json:"passthrough,omitempty"` // дописывать параметры запроса посетителя к адресу
+	UTM         map[string]string `json:"utm,omitempty"`         // utm_* по умолчанию
+	Conflict    string            `json:"conflict,omitempty"`    // кто побеждает при совпадении ключей: link (по умолчанию) или visitor
+}
+
+type InvalidQueryOptionsError struct {
+	reason string
+}
+
+func (e *InvalidQueryOptionsError) Error() string {
+	return fmt.Sprintf("invalid query options: %s", e.reason)
+}
+
+func toQueryOptions(dto *queryOptionsDTO) (storage.QueryOptions, error) {
+	if dto == nil {
+		return storage.QueryOptions{}, nil
+	}
+	opts := storage.QueryOptions{Passthrough: dto.Passthrough}
+	switch dto.Conflict {
+	case "", conflictLinkWins:
+	case conflictVisitorWins:
+		opts.VisitorWins = true
+	default:
+		return opts, &InvalidQueryOptionsError{reason: fmt.Sprintf("unknown conflict mode %q", dto.Conflict)}
+	}
+	for key, value := range dto.UTM {
+		if !utmParams[key] {
+			return opts, &InvalidQueryOptionsError{reason: fmt.Sprintf("%q is not a utm parameter", key)}
+		}
+		if value == "" {
+			continue
+		}
+		if opts.UTM == nil {
+			opts.UTM = make(map[string]string)
+		}
+		opts.UTM[key] = value
+	}
+	return opts, nil
+}
+
+func toQueryOptionsDTO(opts storage.QueryOptions) *queryOptionsDTO {
+	if !opts.Passthrough && len(opts.UTM) == 0 && !opts.VisitorWins {
+		return nil
+	}
+	dto := &queryOptionsDTO{Passthrough: opts.Passthrough, UTM: opts.UTM, Conflict: conflictLinkWins}
+	if opts.VisitorWins {
+		dto.Conflict = conflictVisitorWins
+	}
+	return dto
+}
+
+// mergeQuery adds the UTM defaults of opts and, with passthrough, the
+// visitor's parameters to the destination. Parameters of the destination
+// beat UTM defaults; visitor parameters lose to both unless VisitorWins.
+//
+// The destination query is kept byte for byte except for the parameters
+// the visitor overrides, so links with unusual but valid encoding keep
+// working. Added parameters are appended in a stable order.
+func mergeQuery(destination, rawVisitorQuery string, opts storage.QueryOptions) (string, error) {
+	var visitor url.Values
+	if opts.Passthrough && rawVisitorQuery != "" {
+		// ошибочные пары пропускаются, остальные передаются дальше
+		visitor, _ = url.ParseQuery(rawVisitorQuery)
+		delete(visitor, "preview")
+	}
+	if len(visitor) == 0 && len(opts.UTM) == 0 {
+		return destination, nil
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", err
+	}
+
+	var kept []string
+	linkKeys := make(map[string]bool)
+	if u.RawQuery != "" {
+		for _, pair := range strings.Split(u.RawQuery, "&") {
+			if pair == "" {
+				continue
+			}
+			rawKey, _, _ := strings.Cut(pair, "=")
+			key, err := url.QueryUnescape(rawKey)
+			if err != nil {
+				key = rawKey
+			}
+			if opts.VisitorWins && len(visitor[key]) > 0 {
+				continue
+			}
+			linkKeys[key] = true
+			kept = append(kept, pair)
+		}
+	}
+
+	added := url.Values{}
+	for key, value := range opts.UTM {
+		if linkKeys[key] || (opts.VisitorWins && len(visitor[key]) > 0) {
+			continue
+		}
+		added.Set(key, value)
+	}
+	for key, values := range visitor {
+		if !opts.VisitorWins && (linkKeys[key] || added.Has(key)) {
+			continue
+		}
+		added[key] = values
+	}
+
+	keys := make([]string, 0, len(added))
+	for key := range added {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range added[key] {
+			kept = append(kept, url.QueryEscape(key)+"="+url.QueryEscape(value))
+		}
+	}
+
+	u.RawQuery = strings.Join(kept, "&")
+	u.ForceQuery = false
+	return u.String(), nil
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adettelle/go-url-shortener/internal/config"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeQuery(t *testing.T) {
+	utm := map[string]string{"utm_source": "short", "utm_medium": "link"}
+
+	tests := []struct {
+		name        string
+		destination string
+		visitor     string
+		opts        storage.QueryOptions
+		want        string
+	}{
+		{
+			name:        "nothing to merge",
+			destination: "https://example.com/a?x=1",
+			visitor:     "utm_source=newsletter",
+			want:        "https://example.com/a?x=1",
+		},
+		{
+			name:        "passthrough",
+			destination: "https://example.com/a",
+			visitor:     "utm_source=newsletter&ref=tw",
+			opts:        storage.QueryOptions{Passthrough: true},
+			want:        "https://example.com/a?ref=tw&utm_source=newsletter",
+		},
+		{
+			name:        "utm defaults",
+			destination: "https://example.com/a?x=1",
+			opts:        storage.QueryOptions{UTM: utm},
+			want:        "https://example.com/a?x=1&utm_medium=link&utm_source=short",
+		},
+		{
+			name:        "destination beats utm defaults",
+			destination: "https://example.com/a?utm_source=site",
+			opts:        storage.QueryOptions{UTM: utm},
+			want:        "https://example.com/a?utm_source=site&utm_medium=link",
+		},
+		{
+			name:        "link wins",
+			destination: "https://example.com/a?ref=site",
+			visitor:     "ref=tw&utm_source=newsletter&q=go",
+			opts:        storage.QueryOptions{Passthrough: true, UTM: utm},
+			want:        "https://example.com/a?ref=site&q=go&utm_medium=link&utm_source=short",
+		},
+		{
+			name:        "visitor wins",
+			destination: "https://example.com/a?ref=site&keep=1",
+			visitor:     "ref=tw&utm_source=newsletter",
+			opts:        storage.QueryOptions{Passthrough: true, UTM: utm, VisitorWins: true},
+			want:        "https://example.com/a?keep=1&ref=tw&utm_medium=link&utm_source=newsletter",
+		},
+		{
+			name:        "visitor replaces all values of a key",
+			destination: "https://example.com/a?tag=a&tag=b",
+			visitor:     "tag=c",
+			opts:        storage.QueryOptions{Passthrough: true, VisitorWins: true},
+			want:        "https://example.com/a?tag=c",
+		},
+		{
+			name:        "repeated visitor values",
+			destination: "https://example.com/a",
+			visitor:     "tag=x&tag=y",
+			opts:        storage.QueryOptions{Passthrough: true},
+			want:        "https://example.com/a?tag=x&tag=y",
+		},
+		{
+			name:        "special characters are encoded",
+			destination: "https://example.com/a",
+			visitor:     "q=a%26b%3Dc&name=%D0%BF%D1%80%D0%B8%D0%B2%D0%B5%D1%82&sp=a+b&pct=100%25",
+			opts:        storage.QueryOptions{Passthrough: true},
+			want:        "https://example.com/a?name=%D0%BF%D1%80%D0%B8%D0%B2%D0%B5%D1%82&pct=100%25&q=a%26b%3Dc&sp=a+b",
+		},
+		{
+			name:        "utm values are encoded",
+			destination: "https://example.com/a",
+			opts:        storage.QueryOptions{UTM: map[string]string{"utm_campaign": "spring sale & more"}},
+			want:        "https://example.com/a?utm_campaign=spring+sale+%26+more",
+		},
+		{
+			name:        "destination encoding is preserved",
+			destination: "https://example.com/a?path=%2Fx%2Fy&flag&empty=",
+			visitor:     "v=1",
+			opts:        storage.QueryOptions{Passthrough: true},
+			want:        "https://example.com/a?path=%2Fx%2Fy&flag&empty=&v=1",
+		},
+		{
+			name:        "encoded destination key matches visitor key",
+			destination: "https://example.com/a?my%20key=1",
+			visitor:     "my+key=2",
+			opts:        storage.QueryOptions{Passthrough: true},
+			want:        "https://example.com/a?my%20key=1",
+		},
+		{
+			name:        "fragment stays at the end",
+			destination: "https://example.com/a?x=1#section",
+			visitor:     "y=2",
+			opts:        storage.QueryOptions{Passthrough: true},
+			want:        "https://example.com/a?x=1&y=2#section",
+		},
+		{
+			name:        "preview flag is not passed",
+			destination: "https://example.com/a",
+			visitor:     "preview=0&y=2",
+			opts:        storage.QueryOptions{Passthrough: true},
+			want:        "https://example.com/a?y=2",
+		},
+		{
+			name:        "malformed visitor pairs are skipped",
+			destination: "https://example.com/a",
+			visitor:     "bad=%zz&good=1",
+			opts:        storage.QueryOptions{Passthrough: true},
+			want:        "https://example.com/a?good=1",
+		},
+		{
+			name:        "empty destination query",
+			destination: "https://example.com/a?",
+			visitor:     "y=2",
+			opts:        storage.QueryOptions{Passthrough: true},
+			want:        "https://example.com/a?y=2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeQuery(tt.destination, tt.visitor, tt.opts)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGetFullAddressQueryPassthrough(t *testing.T) {
+	handlers := New(storage.New(), &config.Config{Domains: []string{"http://localhost:8080"}})
+	id := createTargetedLink(t, handlers, `{"url":"https://example.com/landing?lang=ru",
+		"query":{"passthrough":true,"utm":{"utm_medium":"short"},"conflict":"visitor"}}`)
+
+	request := httptest.NewRequest(http.MethodGet, "/"+id+"?utm_source=newsletter&lang=en", nil)
+	request.SetPathValue("id", id)
+	response := httptest.NewRecorder()
+	handlers.GetFullAddress(response, request)
+
+	require.Equal(t, http.StatusTemporaryRedirect, response.Code)
+	require.Equal(t, "https://example.com/landing?lang=en&utm_medium=short&utm_source=newsletter",
+		response.Header().Get("Location"))
+}
+
+func TestCreateShortAddressJSONInvalidQueryOptions(t *testing.T) {
+	handlers := New(storage.New(), &config.Config{Domains: []string{"http://localhost:8080"}})
+
+	for _, query := range []string{
+		`{"conflict":"both"}`,
+		`{"utm":{"ref":"x"}}`,
+	} {
+		request := httptest.NewRequest(http.MethodPost, "/api/shorten",
+			strings.NewReader(`{"url":"https://example.com/","query":`+query+`}`))
+		response := httptest.NewRecorder()
+		handlers.CreateShortAddressJSON(response, request)
+		require.Equal(t, http.StatusBadRequest, response.Code, query)
+	}
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/config"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+)
+
+// fallbackPermanentMaxAge is used when the handlers run without a config.
+const fallbackPermanentMaxAge = 5 * time.Minute
+
+// redirectStatus returns the status code to redirect link with:
+// the link's own type, then the server default, then 307.
+func (h *Handlers) redirectStatus(link *storage.Link) int {
+	if link.RedirectType != 0 {
+		return link.RedirectType
+	}
+	if h.config != nil && h.config.DefaultRedirectType != 0 {
+		return h.config.DefaultRedirectType
+	}
+	return http.StatusTemporaryRedirect
+}
+
+// redirectCacheControl lets browsers cache permanent redirects of static
+// links for a short time. Temporary redirects and links whose target depends
+// on state or on the visitor (click limits, expiry, passwords, targeting,
+// A/B tests) must reach the server on every visit.
+//
+// Even a static link can be edited, rolled back or blocked by the policy
+// later, and a cached redirect keeps pointing to the old destination until
+// it expires. So the redirect is private, shared caches and CDNs do not keep
+// it, and max-age trades fewer requests against how long a change takes to
+// reach visitors.
+func (h *Handlers) redirectCacheControl(link *storage.Link, status int) string {
+	permanent := status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect
+	dynamic := link.MaxClicks > 0 || !link.NotAfter.IsZero() || link.PasswordHash != "" || len(link.Targets) > 0 || len(link.Variants) > 0
+	if !permanent || dynamic {
+		return "private, no-store"
+	}
+
+	maxAge := fallbackPermanentMaxAge
+	if h.config != nil && h.config.PermanentCacheMaxAge > 0 {
+		maxAge = h.config.PermanentCacheMaxAge
+	}
+	return "private, max-age=" + strconv.Itoa(int(maxAge.Seconds()))
+}
+
+// validRedirectType checks the redirect_type of a request; 0 means default.
+func validRedirectType(code int) bool {
+	return code == 0 || config.IsRedirectType(code)
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/config"
+	"github.com/adettelle/go-url-shortener/internal/mocks"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetFullAddressRedirectType(t *testing.T) {
+	cfg := &config.Config{DefaultRedirectType: http.StatusFound, PermanentCacheMaxAge: time.Hour}
+
+	tests := []struct {
+		name         string
+		link         storage.Link
+		wantCode     int
+		wantCacheCtl string
+	}{
+		{"server default", storage.Link{}, http.StatusFound, "private, no-store"},
+		{"permanent 301", storage.Link{RedirectType: 301}, http.StatusMovedPermanently, "private, max-age=3600"},
+		{"permanent 308", storage.Link{RedirectType: 308}, http.StatusPermanentRedirect, "private, max-age=3600"},
+		{"temporary 307", storage.Link{RedirectType: 307}, http.StatusTemporaryRedirect, "private, no-store"},
+		{"permanent with click limit", storage.Link{RedirectType: 301, MaxClicks: 10}, http.StatusMovedPermanently, "private, no-store"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStorage := mocks.NewMockStorager(ctrl)
+			handlers := New(mockStorage, cfg)
+
+			link := tt.link
+			link.ID = "abc"
+			link.URL = "https://example.com/"
+			mockStorage.EXPECT().GetLink("abc").Return(&link, nil)
+			if link.MaxClicks > 0 {
+				mockStorage.EXPECT().UseClick("abc").Return(nil)
+			}
+
+			request := httptest.NewRequest(http.MethodGet, "/abc", nil)
+			request.SetPathValue("id", "abc")
+			response := httptest.NewRecorder()
+			handlers.GetFullAddress(response, request)
+
+			require.Equal(t, tt.wantCode, response.Code)
+			require.Equal(t, "https://example.com/", response.Header().Get("Location"))
+			require.Equal(t, tt.wantCacheCtl, response.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestCreateShortAddressJSONInvalidRedirectType(t *testing.T) {
+	handlers := New(storage.New(), &config.Config{Domains: []string{"http://localhost:8080"}})
+
+	request := httptest.NewRequest(http.MethodPost, "/api/shorten",
+		strings.NewReader(`{"url":"https://example.com/","redirect_type":303}`))
+	response := httptest.NewRecorder()
+	handlers.CreateShortAddressJSON(response, request)
+	require.Equal(t, http.StatusBadRequest, response.Code)
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	maxTitleLength     = 300
+	maxSearchQuery     = 200
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+type searchResultDTO struct {
+	linkResponseDTO
+	Score float64 `json:"score"`
+}
+
+// SearchLinks handles GET /api/links/search?q=&workspace=&limit=. It looks
+// for the words of q in the aliases, titles, tags and destinations of the
+// personal links of the caller or of the workspace links, best match first.
+// Words may be prefixes or contain a typo.
+func (h *Handlers) SearchLinks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" || len(query) > maxSearchQuery {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	limit := defaultSearchLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		limit = min(n, maxSearchLimit)
+	}
+
+	filter, ok := h.linkScope(w, r, r.URL.Query().Get("workspace"), false)
+	if !ok {
+		return
+	}
+
+	results, err := h.repo.SearchLinks(filter, query, limit)
+	if err != nil {
+		errlog.Error("error in searching links", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	dtos := make([]searchResultDTO, 0, len(results))
+	for _, res := range results {
+		dtos = append(dtos, searchResultDTO{linkResponseDTO: h.toLinkDTO(res.Link), Score: res.Score})
+	}
+	writeJSON(w, http.StatusOK, dtos)
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/adettelle/go-url-shortener/internal/config"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/adettelle/go-url-shortener/internal/workspace"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchLinks(t *testing.T) {
+	repo := storage.New()
+	workspaces := workspace.NewStore()
+	handlers := New(repo, &config.Config{Domains: []string{"http://localhost:8080"}}, WithWorkspaces(workspaces))
+	ws, err := workspaces.Create("Docs", bob.Actor())
+	require.NoError(t, err)
+
+	for _, body := range []string{
+		`{"url":"https://example.com/1","alias":"release-notes","title":"Changelog"}`,
+		`{"url":"https://example.com/2","title":"Release checklist"}`,
+		`{"url":"https://example.com/release"}`,
+	} {
+		response := call(handlers.CreateShortAddressJSON, alice, http.MethodPost, "/api/shorten", body)
+		require.Equal(t, http.StatusCreated, response.Code)
+	}
+	_, err = repo.AddLink(storage.Link{URL: "https://example.com/release", CreatedBy: bob.Actor(), Workspace: ws.ID})
+	require.NoError(t, err)
+
+	response := call(handlers.SearchLinks, alice, http.MethodGet, "/api/links/search?q=releas", "")
+	require.Equal(t, http.StatusOK, response.Code)
+	var results []searchResultDTO
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &results))
+	require.Len(t, results, 3)
+	require.Equal(t, "release-notes", results[0].ID)
+	require.Equal(t, "Release checklist", results[1].Title)
+	require.Equal(t, "https://example.com/release", results[2].URL)
+	require.Greater(t, results[0].Score, results[1].Score)
+	require.Greater(t, results[1].Score, results[2].Score)
+
+	response = call(handlers.SearchLinks, alice, http.MethodGet, "/api/links/search?q=release&limit=1", "")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, []string{"release-notes"}, listedIDs(t, response.Body.Bytes()))
+
+	// ссылки пространства видны только его участникам
+	response = call(handlers.SearchLinks, bob, http.MethodGet, "/api/links/search?q=release&workspace="+ws.ID, "")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Len(t, listedIDs(t, response.Body.Bytes()), 1)
+	response = call(handlers.SearchLinks, alice, http.MethodGet, "/api/links/search?q=release&workspace="+ws.ID, "")
+	require.Equal(t, http.StatusForbidden, response.Code)
+
+	for _, target := range []string{"/api/links/search", "/api/links/search?q=+", "/api/links/search?q=a&limit=0"} {
+		response = call(handlers.SearchLinks, alice, http.MethodGet, target, "")
+		require.Equal(t, http.StatusBadRequest, response.Code, target)
+	}
+	response = call(handlers.SearchLinks, nil, http.MethodGet, "/api/links/search?q=release", "")
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/analytics"
+	"github.com/adettelle/go-url-shortener/internal/authz"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultStatsRange = 30 * 24 * time.Hour
+	maxStatsRange     = 366 * 24 * time.Hour
+	defaultStatsTop   = 10
+	maxStatsTop       = 100
+)
+
+// StatsProvider computes click reports for a link.
+type StatsProvider interface {
+	Stats(ctx context.Context, q analytics.StatsQuery) (*analytics.Stats, error)
+}
+
+// WithStats enables the link statistics endpoint.
+func WithStats(s StatsProvider) Option {
+	return func(h *Handlers) {
+		h.stats = s
+	}
+}
+
+type bucketDTO struct {
+	Start  time.Time `json:"start"`
+	Clicks int       `json:"clicks"`
+}
+
+type countDTO struct {
+	Value  string `json:"value"`
+	Clicks int    `json:"clicks"`
+}
+
+type linkStatsResponseDTO struct {
+	ID             string      `json:"id"`
+	From           time.Time   `json:"from"`
+	To             time.Time   `json:"to"`
+	TotalClicks    int         `json:"total_clicks"`
+	UniqueVisitors int         `json:"unique_visitors"`
+	ClicksPerDay   []bucketDTO `json:"clicks_per_day"`
+	ClicksPerHour  []bucketDTO `json:"clicks_per_hour"`
+	TopReferrers   []countDTO  `json:"top_referrers"`
+	TopUserAgents  []countDTO  `json:"top_user_agents"`
+	Variants       []countDTO  `json:"variants,omitempty"` // только для ссылок с A/B вариантами
+}
+
+// GetLinkStats handles GET /api/links/{id}/stats?from=&to=&top=.
+// from and to are RFC 3339 timestamps or dates (2006-01-02), the range
+// defaults to the last 30 days.
+func (h *Handlers) GetLinkStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if h.stats == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	link, ok := h.lookupAPILink(w, r)
+	if !ok {
+		return
+	}
+	if !h.authorize(w, r, authz.ActionViewStats, link) {
+		return
+	}
+
+	q, err := parseStatsQuery(r, h.clock())
+	if err != nil {
+		errlog.Info("invalid stats query", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	q.ShortID = link.Key()
+
+	stats, err := h.stats.Stats(r.Context(), q)
+	if err != nil {
+		errlog.Error("error in getting stats", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toStatsDTO(link.ID, q, stats))
+}
+
+func toStatsDTO(id string, q analytics.StatsQuery, stats *analytics.Stats) linkStatsResponseDTO {
+	dto := linkStatsResponseDTO{
+		ID:             id,
+		From:           q.From,
+		To:             q.To,
+		TotalClicks:    stats.TotalClicks,
+		UniqueVisitors: stats.UniqueVisitors,
+		ClicksPerDay:   toBucketDTOs(stats.PerDay),
+		ClicksPerHour:  toBucketDTOs(stats.PerHour),
+		TopReferrers:   toCountDTOs(stats.TopReferrers),
+		TopUserAgents:  toCountDTOs(stats.TopUserAgents),
+	}
+	if len(stats.Variants) > 0 {
+		dto.Variants = toCountDTOs(stats.Variants)
+	}
+	return dto
+}
+
+type InvalidStatsQueryError struct {
+	reason string
+}
+
+func (e *InvalidStatsQueryError) Error() string {
+	return "invalid stats query: " + e.reason
+}
+
+func parseStatsQuery(r *http.Request, now time.Time) (analytics.StatsQuery, error) {
+	q := analytics.StatsQuery{
+		From: now.Add(-defaultStatsRange),
+		To:   now,
+		Top:  defaultStatsTop,
+	}
+
+	var err error
+	if v := r.URL.Query().Get("from"); v != "" {
+		if q.From, err = parseTime(v); err != nil {
+			return q, &InvalidStatsQueryError{reason: "from: " + err.Error()}
+		}
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		if q.To, err = parseTime(v); err != nil {
+			return q, &InvalidStatsQueryError{reason: "to: " + err.Error()}
+		}
+	}
+	if !q.From.Before(q.To) {
+		return q, &InvalidStatsQueryError{reason: "from must be before to"}
+	}
+	if q.To.Sub(q.From) > maxStatsRange {
+		return q, &InvalidStatsQueryError{reason: "range is too long"}
+	}
+	if v := r.URL.Query().Get("top"); v != "" {
+		q.Top, err = strconv.Atoi(v)
+		if err != nil || q.Top < 1 || q.Top > maxStatsTop {
+			return q, &InvalidStatsQueryError{reason: "top must be between 1 and 100"}
+		}
+	}
+	return q, nil
+}
+
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+func toBucketDTOs(buckets []analytics.Bucket) []bucketDTO {
+	dtos := make([]bucketDTO, 0, len(buckets))
+	for _, b := range buckets {
+		dtos = append(dtos, bucketDTO{Start: b.Start, Clicks: b.Clicks})
+	}
+	return dtos
+}
+
+func toCountDTOs(counts []analytics.Count) []countDTO {
+	dtos := make([]countDTO, 0, len(counts))
+	for _, c := range counts {
+		dtos = append(dtos, countDTO{Value: c.Value, Clicks: c.Clicks})
+	}
+	return dtos
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		errlog.Error("error in marshalling json", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(resp)
+	if err != nil {
+		errlog.Error("error in writing response", zap.Error(err))
+	}
+}
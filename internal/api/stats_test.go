@@ -0,0 +1,96 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/analytics"
+	"github.com/adettelle/go-url-shortener/internal/mocks"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetLinkStats(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mocks.NewMockStorager(ctrl)
+	clickStore := analytics.NewMemoryStore()
+	handlers := New(mockStorage, nil, WithStats(clickStore))
+
+	id := "qqVjJVf"
+	day := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	err := clickStore.SaveClicks(context.Background(), []analytics.Click{
+		{Time: day.Add(time.Hour), ShortID: id, IPHash: "a", UAFamily: "Chrome"},
+		{Time: day.Add(2 * time.Hour), ShortID: id, IPHash: "b", UAFamily: "Chrome", Referrer: "https://t.me/"},
+	})
+	require.NoError(t, err)
+
+	mockStorage.EXPECT().GetLink(id).Return(&storage.Link{ID: id, URL: "https://practicum.yandex.ru/", CreatedBy: "alice"}, nil)
+
+	request := httptest.NewRequest(http.MethodGet, "/api/links/"+id+"/stats?from=2024-03-10&to=2024-03-11", nil)
+	request = withPrincipal(request, alice)
+	request.SetPathValue("id", id)
+	response := httptest.NewRecorder()
+	handlers.GetLinkStats(response, request)
+
+	require.Equal(t, http.StatusOK, response.Code)
+	var stats linkStatsResponseDTO
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &stats))
+	require.Equal(t, 2, stats.TotalClicks)
+	require.Equal(t, 2, stats.UniqueVisitors)
+	require.Len(t, stats.ClicksPerDay, 1)
+	require.Len(t, stats.ClicksPerHour, 2)
+	require.Equal(t, []countDTO{{Value: "Chrome", Clicks: 2}}, stats.TopUserAgents)
+}
+
+func TestGetLinkStatsUnknownLink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mocks.NewMockStorager(ctrl)
+	handlers := New(mockStorage, nil, WithStats(analytics.NewMemoryStore()))
+
+	mockStorage.EXPECT().GetLink("nope").Return(nil, &storage.NoEntryError{})
+
+	request := httptest.NewRequest(http.MethodGet, "/api/links/nope/stats", nil)
+	request.SetPathValue("id", "nope")
+	response := httptest.NewRecorder()
+	handlers.GetLinkStats(response, request)
+
+	require.Equal(t, http.StatusNotFound, response.Code)
+}
+
+func TestParseStatsQuery(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+	}{
+		{"defaults", "", false},
+		{"dates", "?from=2024-03-01&to=2024-03-05", false},
+		{"rfc3339", "?from=2024-03-01T10:00:00Z&to=2024-03-01T12:00:00Z", false},
+		{"bad from", "?from=yesterday", true},
+		{"reversed", "?from=2024-03-05&to=2024-03-01", true},
+		{"too long", "?from=2020-01-01&to=2024-01-01", true},
+		{"bad top", "?top=0", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := parseStatsQuery(httptest.NewRequest(http.MethodGet, "/"+tt.query, nil), now)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, q.From.Before(q.To))
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/adettelle/go-url-shortener/internal/authz"
+	"github.com/adettelle/go-url-shortener/internal/events"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	maxTags         = 20
+	maxFolderLength = 200
+	maxFolderDepth  = 10
+)
+
+var (
+	tagPattern           = regexp.MustCompile(`^[\p{L}\p{N}][\p{L}\p{N} _.-]{0,49}$`)
+	folderSegmentPattern = regexp.MustCompile(`^[\p{L}\p{N} _.-]{1,50}$`)
+)
+
+type InvalidLabelsError struct {
+	reason string
+}
+
+func (e *InvalidLabelsError) Error() string {
+	return "invalid labels: " + e.reason
+}
+
+// normalizeTags lowercases tags and drops duplicates, keeping the order.
+func normalizeTags(tags []string) ([]string, error) {
+	if len(tags) > maxTags {
+		return nil, &InvalidLabelsError{reason: "too many tags"}
+	}
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !tagPattern.MatchString(tag) {
+			return nil, &InvalidLabelsError{reason: "bad tag " + tag}
+		}
+		if !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized, nil
+}
+
+// normalizeFolder trims the slashes around the folder path and the spaces
+// around its segments: " /Marketing/ 2024/" becomes "Marketing/2024".
+func normalizeFolder(folder string) (string, error) {
+	folder = strings.Trim(strings.TrimSpace(folder), "/")
+	if folder == "" {
+		return "", nil
+	}
+	segments := strings.Split(folder, "/")
+	if len(segments) > maxFolderDepth {
+		return "", &InvalidLabelsError{reason: "folder is too deep"}
+	}
+	for i, segment := range segments {
+		segments[i] = strings.TrimSpace(segment)
+		if !folderSegmentPattern.MatchString(segments[i]) || segments[i] == "." || segments[i] == ".." {
+			return "", &InvalidLabelsError{reason: "bad folder " + folder}
+		}
+	}
+	folder = strings.Join(segments, "/")
+	if len(folder) > maxFolderLength {
+		return "", &InvalidLabelsError{reason: "folder is too long"}
+	}
+	return folder, nil
+}
+
+type linkLabelsRequestDTO struct {
+	Tags   []string `json:"tags"`
+	Folder string   `json:"folder"`
+}
+
+type tagCountDTO struct {
+	Tag   string `json:"tag"`
+	Links int    `json:"links"`
+}
+
+type tagRenameRequestDTO struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Workspace string `json:"workspace,omitempty"`
+}
+
+type tagMergeRequestDTO struct {
+	From      []string `json:"from"`
+	To        string   `json:"to"`
+	Workspace string   `json:"workspace,omitempty"`
+}
+
+type tagRenameResponseDTO struct {
+	Tag   string `json:"tag"`
+	Links int    `json:"links"` // сколько ссылок изменилось
+}
+
+// linkScope returns the filter of the links a listing or a bulk change may
+// touch: the links of workspace, or the personal links of the caller when
+// workspace is empty. With write set the caller must be allowed to edit
+// those links. If the caller is not allowed, the error response is already
+// written and linkScope returns false.
+func (h *Handlers) linkScope(w http.ResponseWriter, r *http.Request, workspaceID string, write bool) (storage.LinkFilter, bool) {
+	filter := storage.LinkFilter{Workspace: workspaceID}
+	if workspaceID != "" {
+		ws, ok := h.lookupWorkspace(w, workspaceID, http.StatusNotFound)
+		if !ok {
+			return filter, false
+		}
+		action := authz.ActionViewWorkspace
+		if write {
+			action = authz.ActionEdit
+		}
+		return filter, h.authorizeWorkspace(w, r, action, ws)
+	}
+
+	action := authz.ActionList
+	if write {
+		action = authz.ActionCreate
+	}
+	if !h.authorize(w, r, action, nil) {
+		return filter, false
+	}
+	// у анонимных ссылок нет владельца, перечислять нечего
+	var ok bool
+	filter.CreatedBy, ok = requireActor(w, r)
+	return filter, ok
+}
+
+// SetLinkLabels handles PUT /api/links/{id}/labels, which replaces the tags
+// and the folder of the link.
+func (h *Handlers) SetLinkLabels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	current, ok := h.lookupAPILink(w, r)
+	if !ok {
+		return
+	}
+	if !h.authorize(w, r, authz.ActionEdit, current) {
+		return
+	}
+
+	var requestBody linkLabelsRequestDTO
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		errlog.Error("error in unmarshalling json", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	tags, err := normalizeTags(requestBody.Tags)
+	if err != nil {
+		errlog.Info("invalid tags", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	folder, err := normalizeFolder(requestBody.Folder)
+	if err != nil {
+		errlog.Info("invalid folder", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	link, err := h.repo.SetLabels(current.Key(), tags, folder)
+	if err != nil {
+		var noEntry *storage.NoEntryError
+		if errors.As(err, &noEntry) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		errlog.Error("error in setting labels", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	h.publishLink(r, events.LinkUpdated, link)
+	writeJSON(w, http.StatusOK, h.toLinkDTO(link))
+}
+
+// ListTags handles GET /api/tags?workspace=: the tags of the personal
+// links of the caller or of the workspace links, most used first.
+func (h *Handlers) ListTags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	filter, ok := h.linkScope(w, r, r.URL.Query().Get("workspace"), false)
+	if !ok {
+		return
+	}
+
+	counts, err := h.repo.ListTags(filter)
+	if err != nil {
+		errlog.Error("error in listing tags", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	dtos := make([]tagCountDTO, 0, len(counts))
+	for _, c := range counts {
+		dtos = append(dtos, tagCountDTO{Tag: c.Tag, Links: c.Links})
+	}
+	writeJSON(w, http.StatusOK, dtos)
+}
+
+// RenameTag handles POST /api/tags/rename. If the new name is already in
+// use, the two tags are merged.
+func (h *Handlers) RenameTag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var requestBody tagRenameRequestDTO
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		errlog.Error("error in unmarshalling json", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	h.renameTags(w, r, []string{requestBody.From}, requestBody.To, requestBody.Workspace)
+}
+
+// MergeTags handles POST /api/tags/merge, which replaces all tags from
+// with the tag to.
+func (h *Handlers) MergeTags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var requestBody tagMergeRequestDTO
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		errlog.Error("error in unmarshalling json", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if len(requestBody.From) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	h.renameTags(w, r, requestBody.From, requestBody.To, requestBody.Workspace)
+}
+
+func (h *Handlers) renameTags(w http.ResponseWriter, r *http.Request, from []string, to, workspaceID string) {
+	filter, ok := h.linkScope(w, r, workspaceID, true)
+	if !ok {
+		return
+	}
+	from, err := normalizeTags(from)
+	if err != nil {
+		errlog.Info("invalid tags", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	target, err := normalizeTags([]string{to})
+	if err != nil {
+		errlog.Info("invalid tags", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	n, err := h.repo.RenameTags(filter, from, target[0])
+	if err != nil {
+		errlog.Error("error in renaming tags", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	errlog.Info("tags renamed", zap.Strings("from", from), zap.String("to", target[0]),
+		zap.String("workspace", workspaceID), zap.String("by", requestActor(r)), zap.Int("links", n))
+	writeJSON(w, http.StatusOK, tagRenameResponseDTO{Tag: target[0], Links: n})
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/adettelle/go-url-shortener/internal/config"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/adettelle/go-url-shortener/internal/workspace"
+	"github.com/stretchr/testify/require"
+)
+
+func listedIDs(t *testing.T, body []byte) []string {
+	var links []linkResponseDTO
+	require.NoError(t, json.Unmarshal(body, &links))
+	ids := []string{}
+	for _, link := range links {
+		ids = append(ids, link.ID)
+	}
+	return ids
+}
+
+func TestTagsAndFolders(t *testing.T) {
+	repo := storage.New()
+	handlers := New(repo, &config.Config{Domains: []string{"http://localhost:8080"}})
+
+	response := call(handlers.CreateShortAddressJSON, alice, http.MethodPost, "/api/shorten",
+		`{"url":"https://example.com/1","alias":"spring","tags":["Promo"," spring ","promo"],"folder":"/marketing/ 2024/"}`)
+	require.Equal(t, http.StatusCreated, response.Code)
+	response = call(handlers.CreateShortAddressJSON, alice, http.MethodPost, "/api/shorten",
+		`{"url":"https://example.com/2","alias":"sale","tags":["sale"],"folder":"marketing"}`)
+	require.Equal(t, http.StatusCreated, response.Code)
+	response = call(handlers.CreateShortAddressJSON, alice, http.MethodPost, "/api/shorten",
+		`{"url":"https://example.com/3","tags":["bad/tag"]}`)
+	require.Equal(t, http.StatusBadRequest, response.Code)
+
+	response = call(handlers.GetLink, alice, http.MethodGet, "/", "", "id", "spring")
+	require.Equal(t, http.StatusOK, response.Code)
+	var link linkResponseDTO
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &link))
+	require.Equal(t, []string{"promo", "spring"}, link.Tags)
+	require.Equal(t, "marketing/2024", link.Folder)
+
+	for target, want := range map[string][]string{
+		"/api/links?tag=promo":                    {"spring"},
+		"/api/links?tag=promo&tag=sale":           {},
+		"/api/links?folder=marketing":             {"spring", "sale"},
+		"/api/links?folder=marketing/2024":        {"spring"},
+		"/api/links?folder=marketing&tag=SALE":    {"sale"},
+		"/api/links?folder=marketing/2024/spring": {},
+	} {
+		response = call(handlers.ListLinks, alice, http.MethodGet, target, "")
+		require.Equal(t, http.StatusOK, response.Code, target)
+		require.ElementsMatch(t, want, listedIDs(t, response.Body.Bytes()), target)
+	}
+	response = call(handlers.ListLinks, alice, http.MethodGet, "/api/links?folder=a/../b", "")
+	require.Equal(t, http.StatusBadRequest, response.Code)
+
+	response = call(handlers.SetLinkLabels, alice, http.MethodPut, "/", `{"tags":["sale","q2"],"folder":"archive"}`, "id", "spring")
+	require.Equal(t, http.StatusOK, response.Code)
+	response = call(handlers.SetLinkLabels, bob, http.MethodPut, "/", `{"tags":["mine"]}`, "id", "spring")
+	require.Equal(t, http.StatusForbidden, response.Code)
+
+	response = call(handlers.ListTags, alice, http.MethodGet, "/api/tags", "")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.JSONEq(t, `[{"tag":"sale","links":2},{"tag":"q2","links":1}]`, response.Body.String())
+
+	response = call(handlers.RenameTag, alice, http.MethodPost, "/api/tags/rename", `{"from":"q2","to":"Spring"}`)
+	require.Equal(t, http.StatusOK, response.Code)
+	require.JSONEq(t, `{"tag":"spring","links":1}`, response.Body.String())
+
+	response = call(handlers.MergeTags, alice, http.MethodPost, "/api/tags/merge", `{"from":["sale","spring"],"to":"campaign"}`)
+	require.Equal(t, http.StatusOK, response.Code)
+	require.JSONEq(t, `{"tag":"campaign","links":2}`, response.Body.String())
+	response = call(handlers.ListTags, alice, http.MethodGet, "/api/tags", "")
+	require.JSONEq(t, `[{"tag":"campaign","links":2}]`, response.Body.String())
+
+	// чужие ссылки переименование не трогает
+	response = call(handlers.ListTags, bob, http.MethodGet, "/api/tags", "")
+	require.JSONEq(t, `[]`, response.Body.String())
+	response = call(handlers.MergeTags, nil, http.MethodPost, "/api/tags/merge", `{"from":["campaign"],"to":"x"}`)
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+}
+
+func TestWorkspaceTags(t *testing.T) {
+	repo := storage.New()
+	store := workspace.NewStore()
+	handlers := New(repo, &config.Config{Domains: []string{"http://localhost:8080"}}, WithWorkspaces(store))
+
+	ws, err := store.Create("Team", "alice")
+	require.NoError(t, err)
+	_, err = store.SetMember(ws.ID, "bob", workspace.RoleMember)
+	require.NoError(t, err)
+	_, err = repo.AddLink(storage.Link{URL: "https://example.com/", CreatedBy: "alice", Workspace: ws.ID, Tags: []string{"old"}})
+	require.NoError(t, err)
+
+	response := call(handlers.RenameTag, bob, http.MethodPost, "/api/tags/rename",
+		`{"from":"old","to":"new","workspace":"`+ws.ID+`"}`)
+	require.Equal(t, http.StatusOK, response.Code)
+	require.JSONEq(t, `{"tag":"new","links":1}`, response.Body.String())
+
+	response = call(handlers.RenameTag, carol, http.MethodPost, "/api/tags/rename",
+		`{"from":"new","to":"other","workspace":"`+ws.ID+`"}`)
+	require.Equal(t, http.StatusForbidden, response.Code)
+
+	response = call(handlers.ListLinks, bob, http.MethodGet, "/api/links?workspace="+ws.ID+"&tag=new", "")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Len(t, listedIDs(t, response.Body.Bytes()), 1)
+}
+
+func TestNormalizeFolder(t *testing.T) {
+	tests := map[string]string{
+		"":                    "",
+		"/":                   "",
+		" /Marketing/ 2024/ ": "Marketing/2024",
+		"a/b/c":               "a/b/c",
+	}
+	for in, want := range tests {
+		got, err := normalizeFolder(in)
+		require.NoError(t, err, in)
+		require.Equal(t, want, got, in)
+	}
+	for _, in := range []string{"a//b", "a/../b", "a/b?c", "1/2/3/4/5/6/7/8/9/10/11"} {
+		_, err := normalizeFolder(in)
+		require.ErrorAs(t, err, new(*InvalidLabelsError), in)
+	}
+}
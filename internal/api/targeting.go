@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/adettelle/go-url-shortener/internal/targeting"
+)
+
+type targetRuleDTO struct {
+	OS        []string `json:"os,omitempty"`        // ios, android, windows, macos, linux
+	Devices   []string `json:"devices,omitempty"`   // mobile, tablet, desktop, bot
+	Languages []string `json:"languages,omitempty"` // "de" совпадает и с "de-AT"
+	Countries []string `json:"countries,omitempty"` // ISO 3166-1 alpha-2
+	URL       string   `json:"url"`
+}
+
+// WithGeoIP enables country conditions in targeting rules. Without it
+// rules with countries never match.
+func WithGeoIP(geo targeting.CountryResolver) Option {
+	return func(h *Handlers) {
+		h.geo = geo
+	}
+}
+
+func toTargetRules(dtos []targetRuleDTO) []targeting.Rule {
+	if len(dtos) == 0 {
+		return nil
+	}
+	rules := make([]targeting.Rule, len(dtos))
+	for i, d := range dtos {
+		rules[i] = targeting.Rule{
+			OS:        d.OS,
+			Devices:   d.Devices,
+			Languages: d.Languages,
+			Countries: d.Countries,
+			URL:       d.URL,
+		}
+	}
+	return rules
+}
+
+func toTargetDTOs(rules []targeting.Rule) []targetRuleDTO {
+	if len(rules) == 0 {
+		return nil
+	}
+	dtos := make([]targetRuleDTO, len(rules))
+	for i, rule := range rules {
+		dtos[i] = targetRuleDTO{
+			OS:        rule.OS,
+			Devices:   rule.Devices,
+			Languages: rule.Languages,
+			Countries: rule.Countries,
+			URL:       rule.URL,
+		}
+	}
+	return dtos
+}
+
+// destination picks the URL r is redirected to: the first matching
+// targeting rule, then the visitor's A/B variant, otherwise the link's own
+// URL. The name of the served variant is empty unless a variant was chosen.
+func (h *Handlers) destination(w http.ResponseWriter, r *http.Request, link *storage.Link) (url, variant string) {
+	if len(link.Targets) > 0 {
+		visitor := targeting.NewVisitor(r, h.ips.ClientIP(r), h.geo)
+		if url, ok := targeting.Resolve(link.Targets, visitor); ok {
+			return url, ""
+		}
+	}
+	if len(link.Variants) > 0 {
+		v := h.chooseVariant(w, r, link)
+		return v.URL, v.Name
+	}
+	return link.URL, ""
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adettelle/go-url-shortener/internal/config"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeGeo map[string]string
+
+func (g fakeGeo) Country(ip net.IP) (string, error) {
+	if country, ok := g[ip.String()]; ok {
+		return country, nil
+	}
+	return "", errors.New("unknown address")
+}
+
+func createTargetedLink(t *testing.T, handlers *Handlers, body string) string {
+	t.Helper()
+	request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body))
+	response := httptest.NewRecorder()
+	handlers.CreateShortAddressJSON(response, request)
+	require.Equal(t, http.StatusCreated, response.Code)
+
+	var resp shortAddrCreateResponseDTO
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &resp))
+	return strings.TrimPrefix(resp.Result, "http://localhost:8080/")
+}
+
+func TestGetFullAddressTargeting(t *testing.T) {
+	handlers := New(storage.New(), &config.Config{Domains: []string{"http://localhost:8080"}},
+		WithGeoIP(fakeGeo{"203.0.113.7": "FR"}))
+	id := createTargetedLink(t, handlers, `{"url":"https://example.com/","targets":[
+		{"os":["ios"],"url":"https://apps.apple.com/app/id1"},
+		{"os":["android"],"devices":["mobile"],"url":"https://play.google.com/store/apps/details?id=app"},
+		{"countries":["fr"],"url":"https://example.com/fr"}
+	]}`)
+
+	tests := []struct {
+		name   string
+		ua     string
+		remote string
+		want   string
+	}{
+		{"iphone", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) Mobile/15E148", "198.51.100.1:1234", "https://apps.apple.com/app/id1"},
+		{"android phone", "Mozilla/5.0 (Linux; Android 14; Pixel 8) Chrome/120.0 Mobile Safari/537.36", "198.51.100.1:1234", "https://play.google.com/store/apps/details?id=app"},
+		{"desktop in france", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0", "203.0.113.7:1234", "https://example.com/fr"},
+		{"desktop elsewhere", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0", "198.51.100.1:1234", "https://example.com/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/"+id, nil)
+			request.SetPathValue("id", id)
+			request.Header.Set("User-Agent", tt.ua)
+			request.RemoteAddr = tt.remote
+			response := httptest.NewRecorder()
+			handlers.GetFullAddress(response, request)
+
+			require.Equal(t, http.StatusTemporaryRedirect, response.Code)
+			require.Equal(t, tt.want, response.Header().Get("Location"))
+			require.Equal(t, "private, no-store", response.Header().Get("Cache-Control"))
+			require.Contains(t, response.Header().Get("Vary"), "User-Agent")
+		})
+	}
+}
+
+func TestCreateShortAddressJSONInvalidTargets(t *testing.T) {
+	handlers := New(storage.New(), &config.Config{Domains: []string{"http://localhost:8080"}},
+		WithPolicy(denyPolicy{host: "evil.com"}))
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{"unknown os", `{"url":"https://example.com/","targets":[{"os":["symbian"],"url":"https://example.com/s"}]}`, http.StatusBadRequest},
+		{"rule without conditions", `{"url":"https://example.com/","targets":[{"url":"https://example.com/s"}]}`, http.StatusBadRequest},
+		{"rule without url", `{"url":"https://example.com/","targets":[{"os":["ios"]}]}`, http.StatusBadRequest},
+		{"blocked rule url", `{"url":"https://example.com/","targets":[{"os":["ios"],"url":"https://evil.com/"}]}`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(tt.body))
+			response := httptest.NewRecorder()
+			handlers.CreateShortAddressJSON(response, request)
+			require.Equal(t, tt.wantCode, response.Code)
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
+
+	"github.com/adettelle/go-url-shortener/internal/storage"
+)
+
+const (
+	maxVariants         = 10
+	variantCookiePrefix = "ab_"
+	variantCookieMaxAge = 30 * 24 * 60 * 60 // секунды
+)
+
+var variantNameRe = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+type variantDTO struct {
+	Name   string `json:"name,omitempty"` // если не задано, варианты называются a, b, c...
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+type InvalidVariantsError struct {
+	reason string
+}
+
+func (e *InvalidVariantsError) Error() string {
+	return fmt.Sprintf("invalid variants: %s", e.reason)
+}
+
+// toVariants converts request variants and names the unnamed ones.
+func toVariants(dtos []variantDTO) []storage.Variant {
+	if len(dtos) == 0 {
+		return nil
+	}
+	variants := make([]storage.Variant, len(dtos))
+	for i, d := range dtos {
+		variants[i] = storage.Variant{Name: d.Name, URL: d.URL, Weight: d.Weight}
+		if variants[i].Name == "" && i < 26 {
+			variants[i].Name = string(rune('a' + i))
+		}
+	}
+	return variants
+}
+
+func toVariantDTOs(variants []storage.Variant) []variantDTO {
+	if len(variants) == 0 {
+		return nil
+	}
+	dtos := make([]variantDTO, len(variants))
+	for i, v := range variants {
+		dtos[i] = variantDTO{Name: v.Name, URL: v.URL, Weight: v.Weight}
+	}
+	return dtos
+}
+
+// validateVariants checks an A/B split: two to maxVariants destinations
+// with unique names and positive weights.
+func validateVariants(variants []storage.Variant) error {
+	if len(variants) == 0 {
+		return nil
+	}
+	if len(variants) < 2 || len(variants) > maxVariants {
+		return &InvalidVariantsError{reason: fmt.Sprintf("need 2 to %d variants", maxVariants)}
+	}
+	names := make(map[string]struct{}, len(variants))
+	for _, v := range variants {
+		if !variantNameRe.MatchString(v.Name) {
+			return &InvalidVariantsError{reason: fmt.Sprintf("bad name %q", v.Name)}
+		}
+		if _, ok := names[v.Name]; ok {
+			return &InvalidVariantsError{reason: fmt.Sprintf("duplicate name %q", v.Name)}
+		}
+		names[v.Name] = struct{}{}
+		if v.URL == "" {
+			return &InvalidVariantsError{reason: fmt.Sprintf("variant %q has no url", v.Name)}
+		}
+		if v.Weight <= 0 {
+			return &InvalidVariantsError{reason: fmt.Sprintf("variant %q needs a positive weight", v.Name)}
+		}
+	}
+	return nil
+}
+
+// pickVariant returns the variant that owns point n of the cumulative
+// weights, n must be in [0, total weight).
+func pickVariant(variants []storage.Variant, n int) storage.Variant {
+	for _, v := range variants {
+		if n < v.Weight {
+			return v
+		}
+		n -= v.Weight
+	}
+	return variants[len(variants)-1]
+}
+
+// chooseVariant returns the variant of the visitor. A returning visitor
+// keeps the variant remembered in the cookie, a new one gets a weighted
+// random variant and the cookie.
+func (h *Handlers) chooseVariant(w http.ResponseWriter, r *http.Request, link *storage.Link) storage.Variant {
+	cookieName := variantCookiePrefix + link.ID
+	if cookie, err := r.Cookie(cookieName); err == nil {
+		for _, v := range link.Variants {
+			if v.Name == cookie.Value {
+				return v
+			}
+		}
+	}
+
+	total := 0
+	for _, v := range link.Variants {
+		total += v.Weight
+	}
+	intn := h.intn
+	if intn == nil {
+		intn = rand.IntN
+	}
+	variant := pickVariant(link.Variants, intn(total))
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    variant.Name,
+		Path:     "/",
+		MaxAge:   variantCookieMaxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return variant
+}
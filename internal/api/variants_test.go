@@ -0,0 +1,129 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adettelle/go-url-shortener/internal/analytics"
+	"github.com/adettelle/go-url-shortener/internal/config"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPickVariant(t *testing.T) {
+	variants := []storage.Variant{
+		{Name: "a", Weight: 3},
+		{Name: "b", Weight: 1},
+	}
+	counts := make(map[string]int)
+	for n := 0; n < 4; n++ {
+		counts[pickVariant(variants, n).Name]++
+	}
+	require.Equal(t, map[string]int{"a": 3, "b": 1}, counts)
+}
+
+func TestGetFullAddressVariants(t *testing.T) {
+	sink := &clickSink{}
+	handlers := New(storage.New(), &config.Config{Domains: []string{"http://localhost:8080"}},
+		WithClickRecorder(sink, "salt"))
+	id := createTargetedLink(t, handlers, `{"url":"https://example.com/","variants":[
+		{"url":"https://example.com/a","weight":1},
+		{"name":"new","url":"https://example.com/new","weight":1}
+	]}`)
+
+	// первый визит: вариант выбирается случайно и запоминается в cookie
+	handlers.intn = func(n int) int { return n - 1 }
+	request := httptest.NewRequest(http.MethodGet, "/"+id, nil)
+	request.SetPathValue("id", id)
+	response := httptest.NewRecorder()
+	handlers.GetFullAddress(response, request)
+
+	require.Equal(t, http.StatusTemporaryRedirect, response.Code)
+	require.Equal(t, "https://example.com/new", response.Header().Get("Location"))
+	require.Equal(t, "private, no-store", response.Header().Get("Cache-Control"))
+	cookies := response.Result().Cookies()
+	require.Len(t, cookies, 1)
+	require.Equal(t, "ab_"+id, cookies[0].Name)
+	require.Equal(t, "new", cookies[0].Value)
+
+	// повторный визит получает тот же вариант, как бы ни выпал жребий
+	handlers.intn = func(int) int { return 0 }
+	request = httptest.NewRequest(http.MethodGet, "/"+id, nil)
+	request.SetPathValue("id", id)
+	request.AddCookie(cookies[0])
+	response = httptest.NewRecorder()
+	handlers.GetFullAddress(response, request)
+
+	require.Equal(t, "https://example.com/new", response.Header().Get("Location"))
+	require.Empty(t, response.Result().Cookies())
+
+	// cookie с неизвестным вариантом заменяется
+	request = httptest.NewRequest(http.MethodGet, "/"+id, nil)
+	request.SetPathValue("id", id)
+	request.AddCookie(&http.Cookie{Name: "ab_" + id, Value: "removed"})
+	response = httptest.NewRecorder()
+	handlers.GetFullAddress(response, request)
+
+	require.Equal(t, "https://example.com/a", response.Header().Get("Location"))
+	require.Equal(t, "a", response.Result().Cookies()[0].Value)
+
+	require.Len(t, sink.clicks, 3)
+	require.Equal(t, []string{"new", "new", "a"},
+		[]string{sink.clicks[0].Variant, sink.clicks[1].Variant, sink.clicks[2].Variant})
+}
+
+func TestCreateShortAddressJSONInvalidVariants(t *testing.T) {
+	handlers := New(storage.New(), &config.Config{Domains: []string{"http://localhost:8080"}},
+		WithPolicy(denyPolicy{host: "evil.com"}))
+
+	tests := []struct {
+		name     string
+		variants string
+		wantCode int
+	}{
+		{"single variant", `[{"url":"https://example.com/a","weight":1}]`, http.StatusBadRequest},
+		{"zero weight", `[{"url":"https://example.com/a","weight":1},{"url":"https://example.com/b"}]`, http.StatusBadRequest},
+		{"duplicate names", `[{"name":"x","url":"https://example.com/a","weight":1},{"name":"x","url":"https://example.com/b","weight":1}]`, http.StatusBadRequest},
+		{"bad name", `[{"name":"a b","url":"https://example.com/a","weight":1},{"url":"https://example.com/b","weight":1}]`, http.StatusBadRequest},
+		{"missing url", `[{"weight":1},{"url":"https://example.com/b","weight":1}]`, http.StatusBadRequest},
+		{"blocked url", `[{"url":"https://evil.com/","weight":1},{"url":"https://example.com/b","weight":1}]`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"url":"https://example.com/","variants":` + tt.variants + `}`
+			request := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body))
+			response := httptest.NewRecorder()
+			handlers.CreateShortAddressJSON(response, request)
+			require.Equal(t, tt.wantCode, response.Code)
+		})
+	}
+}
+
+func TestGetLinkStatsVariants(t *testing.T) {
+	store := analytics.NewMemoryStore()
+	handlers := New(storage.New(), &config.Config{Domains: []string{"http://localhost:8080"}}, WithStats(store))
+	id := createTargetedLink(t, handlers, `{"url":"https://example.com/","variants":[
+		{"url":"https://example.com/a","weight":1},
+		{"url":"https://example.com/b","weight":1}
+	]}`)
+	now := handlers.clock()
+	require.NoError(t, store.SaveClicks(context.Background(), []analytics.Click{
+		{Time: now, ShortID: id, Variant: "a"},
+		{Time: now, ShortID: id, Variant: "b"},
+		{Time: now, ShortID: id, Variant: "b"},
+	}))
+
+	req := withPrincipal(httptest.NewRequest(http.MethodGet, "/api/links/"+id+"/stats", nil), adminKey)
+	req.SetPathValue("id", id)
+	response := httptest.NewRecorder()
+	handlers.GetLinkStats(response, req)
+
+	require.Equal(t, http.StatusOK, response.Code)
+	var resp linkStatsResponseDTO
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &resp))
+	require.Equal(t, []countDTO{{Value: "b", Clicks: 2}, {Value: "a", Clicks: 1}}, resp.Variants)
+}
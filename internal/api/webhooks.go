@@ -0,0 +1,285 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/webhook"
+	"go.uber.org/zap"
+)
+
+// WebhookManager keeps webhook subscriptions and their deliveries, see
+// webhook.Dispatcher.
+type WebhookManager interface {
+	Create(url string, eventTypes []string, owner, workspace string) (*webhook.Subscription, error)
+	Get(id string) (*webhook.Subscription, error)
+	List(owner, workspace string) []webhook.Subscription
+	Delete(id string) error
+	Deliveries(id string) ([]webhook.Delivery, error)
+	DeadLetters(id string) ([]webhook.Delivery, error)
+	Redeliver(subID, id string) error
+}
+
+// WithWebhooks enables the /api/webhooks endpoints.
+func WithWebhooks(m WebhookManager) Option {
+	return func(h *Handlers) {
+		h.webhooks = m
+	}
+}
+
+type webhookCreateRequestDTO struct {
+	URL       string   `json:"url"`
+	Events    []string `json:"events,omitempty"`    // по умолчанию все события
+	Workspace string   `json:"workspace,omitempty"` // события ссылок пространства вместо личных
+}
+
+type webhookDTO struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Owner     string    `json:"owner"`
+	Workspace string    `json:"workspace,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type webhookCreateResponseDTO struct {
+	webhookDTO
+	Secret string `json:"secret"` // показывается только один раз
+}
+
+type attemptDTO struct {
+	Time       time.Time `json:"time"`
+	Status     int       `json:"status,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+type deliveryDTO struct {
+	ID        string          `json:"id"`
+	EventID   string          `json:"event_id"`
+	EventType string          `json:"event_type"`
+	State     string          `json:"state"` // pending, delivered или failed
+	CreatedAt time.Time       `json:"created_at"`
+	Attempts  []attemptDTO    `json:"attempts"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+func toWebhookDTO(sub *webhook.Subscription) webhookDTO {
+	dto := webhookDTO{
+		ID:        sub.ID,
+		URL:       sub.URL,
+		Events:    sub.Events,
+		Owner:     sub.Owner,
+		Workspace: sub.Workspace,
+		CreatedAt: sub.CreatedAt,
+	}
+	if dto.Events == nil {
+		dto.Events = []string{}
+	}
+	return dto
+}
+
+func toDeliveryDTOs(deliveries []webhook.Delivery) []deliveryDTO {
+	dtos := make([]deliveryDTO, 0, len(deliveries))
+	for _, d := range deliveries {
+		dto := deliveryDTO{
+			ID:        d.ID,
+			EventID:   d.EventID,
+			EventType: d.EventType,
+			State:     d.State,
+			CreatedAt: d.CreatedAt,
+			Attempts:  make([]attemptDTO, 0, len(d.Attempts)),
+			Payload:   d.Payload,
+		}
+		for _, a := range d.Attempts {
+			dto.Attempts = append(dto.Attempts, attemptDTO{
+				Time:       a.Time,
+				Status:     a.Status,
+				Error:      a.Error,
+				DurationMS: a.Duration.Milliseconds(),
+			})
+		}
+		dtos = append(dtos, dto)
+	}
+	return dtos
+}
+
+// lookupWebhook loads the subscription of the request. Personal
+// subscriptions are visible to their owner only, workspace ones to the
+// members of the workspace; others get 404 as if the subscription did not
+// exist. With write set the caller must be allowed to edit the links the
+// subscription is about. If it fails, the error response is already written
+// and lookupWebhook returns false.
+func (h *Handlers) lookupWebhook(w http.ResponseWriter, r *http.Request, write bool) (*webhook.Subscription, bool) {
+	if h.webhooks == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return nil, false
+	}
+	sub, err := h.webhooks.Get(r.PathValue("webhookID"))
+	if err != nil {
+		h.writeWebhookError(w, err)
+		return nil, false
+	}
+	filter, ok := h.linkScope(w, r, sub.Workspace, write)
+	if !ok {
+		return nil, false
+	}
+	if sub.Workspace == "" && filter.CreatedBy != sub.Owner {
+		w.WriteHeader(http.StatusNotFound)
+		return nil, false
+	}
+	return sub, true
+}
+
+func (h *Handlers) writeWebhookError(w http.ResponseWriter, err error) {
+	var noSubscription *webhook.NoSubscriptionError
+	var noDelivery *webhook.NoDeliveryError
+	var invalid *webhook.InvalidSubscriptionError
+	var unavailable *webhook.RedeliveryUnavailableError
+	switch {
+	case errors.As(err, &noSubscription), errors.As(err, &noDelivery):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.As(err, &invalid):
+		errlog.Info("invalid webhook subscription", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+	case errors.As(err, &unavailable):
+		errlog.Info("webhook redelivery refused", zap.Error(err))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	default:
+		errlog.Error("error in managing webhooks", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// CreateWebhook handles POST /api/webhooks. The subscription receives the
+// events of the personal links of the caller or, with workspace, of the
+// workspace links. The response is the only place the signing secret ever
+// appears.
+func (h *Handlers) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	var requestBody webhookCreateRequestDTO
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		errlog.Error("error in unmarshalling json", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if _, ok := h.linkScope(w, r, requestBody.Workspace, true); !ok {
+		return
+	}
+	actor, ok := requireActor(w, r)
+	if !ok {
+		return
+	}
+	if h.webhooks == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	sub, err := h.webhooks.Create(requestBody.URL, requestBody.Events, actor, requestBody.Workspace)
+	if err != nil {
+		h.writeWebhookError(w, err)
+		return
+	}
+	errlog.Info("webhook created", zap.String("id", sub.ID), zap.String("by", actor))
+	writeJSON(w, http.StatusCreated, webhookCreateResponseDTO{webhookDTO: toWebhookDTO(sub), Secret: sub.Secret})
+}
+
+// ListWebhooks handles GET /api/webhooks?workspace=.
+func (h *Handlers) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	filter, ok := h.linkScope(w, r, r.URL.Query().Get("workspace"), false)
+	if !ok {
+		return
+	}
+	if h.webhooks == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	subs := h.webhooks.List(filter.CreatedBy, filter.Workspace)
+	dtos := make([]webhookDTO, 0, len(subs))
+	for i := range subs {
+		dtos = append(dtos, toWebhookDTO(&subs[i]))
+	}
+	writeJSON(w, http.StatusOK, dtos)
+}
+
+// DeleteWebhook handles DELETE /api/webhooks/{webhookID}.
+func (h *Handlers) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	sub, ok := h.lookupWebhook(w, r, true)
+	if !ok {
+		return
+	}
+	if err := h.webhooks.Delete(sub.ID); err != nil {
+		h.writeWebhookError(w, err)
+		return
+	}
+	errlog.Info("webhook deleted", zap.String("id", sub.ID), zap.String("by", requestActor(r)))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries handles GET /api/webhooks/{webhookID}/deliveries:
+// the last deliveries of the subscription with all their attempts, newest
+// first.
+func (h *Handlers) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	h.listDeliveries(w, r, func(id string) ([]webhook.Delivery, error) {
+		return h.webhooks.Deliveries(id)
+	})
+}
+
+// ListWebhookDeadLetters handles GET /api/webhooks/{webhookID}/dead-letters:
+// the deliveries that failed for good, newest first.
+func (h *Handlers) ListWebhookDeadLetters(w http.ResponseWriter, r *http.Request) {
+	h.listDeliveries(w, r, func(id string) ([]webhook.Delivery, error) {
+		return h.webhooks.DeadLetters(id)
+	})
+}
+
+func (h *Handlers) listDeliveries(w http.ResponseWriter, r *http.Request, list func(id string) ([]webhook.Delivery, error)) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	sub, ok := h.lookupWebhook(w, r, false)
+	if !ok {
+		return
+	}
+	deliveries, err := list(sub.ID)
+	if err != nil {
+		h.writeWebhookError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toDeliveryDTOs(deliveries))
+}
+
+// RetryWebhookDelivery handles
+// POST /api/webhooks/{webhookID}/dead-letters/{deliveryID}/retry, which
+// takes a delivery off the dead-letter list and sends it again.
+func (h *Handlers) RetryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	sub, ok := h.lookupWebhook(w, r, true)
+	if !ok {
+		return
+	}
+	if err := h.webhooks.Redeliver(sub.ID, r.PathValue("deliveryID")); err != nil {
+		h.writeWebhookError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
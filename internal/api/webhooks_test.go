@@ -0,0 +1,130 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/adettelle/go-url-shortener/internal/config"
+	"github.com/adettelle/go-url-shortener/internal/events"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/adettelle/go-url-shortener/internal/webhook"
+	"github.com/adettelle/go-url-shortener/internal/workspace"
+	"github.com/stretchr/testify/require"
+)
+
+type publisherFunc func(e events.Event)
+
+func (f publisherFunc) Publish(e events.Event) {
+	f(e)
+}
+
+func TestLinkEvents(t *testing.T) {
+	var published []events.Event
+	handlers := New(storage.New(), &config.Config{Domains: []string{"http://localhost:8080"}},
+		WithEvents(publisherFunc(func(e events.Event) {
+			published = append(published, e)
+		})))
+
+	response := call(handlers.CreateShortAddressJSON, alice, http.MethodPost, "/api/shorten",
+		`{"url":"https://example.com/deck","alias":"deck"}`)
+	require.Equal(t, http.StatusCreated, response.Code)
+
+	request := httptest.NewRequest(http.MethodPatch, "/api/links/deck", strings.NewReader(`{"url":"https://example.com/deck-v2"}`))
+	request.SetPathValue("id", "deck")
+	request.Header.Set("If-Match", `"1"`)
+	response = httptest.NewRecorder()
+	handlers.UpdateLink(response, withPrincipal(request, alice))
+	require.Equal(t, http.StatusOK, response.Code)
+
+	// HEAD не считается переходом
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		response = call(handlers.GetFullAddress, nil, method, "/deck", "", "id", "deck")
+		require.Equal(t, http.StatusTemporaryRedirect, response.Code)
+	}
+
+	response = call(handlers.DeleteLink, alice, http.MethodDelete, "/api/links/deck", "", "id", "deck")
+	require.Equal(t, http.StatusNoContent, response.Code)
+
+	var types []string
+	for _, e := range published {
+		types = append(types, e.Type)
+		require.Equal(t, "alice", e.Owner, e.Type)
+	}
+	require.Equal(t, []string{events.LinkCreated, events.LinkUpdated, events.LinkClicked, events.LinkDeleted}, types)
+
+	created := published[0].Data.(linkResponseDTO)
+	require.Equal(t, "http://localhost:8080/deck", created.ShortURL)
+	require.Equal(t, 1, created.Revision)
+	require.Equal(t, "alice", published[0].Actor)
+	require.Equal(t, "https://example.com/deck-v2", published[1].Data.(linkResponseDTO).URL)
+
+	click := published[2].Data.(clickEventDTO)
+	require.Empty(t, published[2].Actor)
+	require.Equal(t, "https://example.com/deck-v2", click.Destination)
+}
+
+func TestWebhookEndpoints(t *testing.T) {
+	workspaces := workspace.NewStore()
+	ws, err := workspaces.Create("Marketing", "alice")
+	require.NoError(t, err)
+	handlers := New(storage.New(), &config.Config{Domains: []string{"http://localhost:8080"}},
+		WithWorkspaces(workspaces), WithWebhooks(webhook.NewDispatcher(webhook.NewStore(), http.DefaultClient, 3, 0, 0, 1)))
+
+	response := call(handlers.CreateWebhook, alice, http.MethodPost, "/api/webhooks", `{"url":"ftp://example.com/hook"}`)
+	require.Equal(t, http.StatusBadRequest, response.Code)
+	response = call(handlers.CreateWebhook, alice, http.MethodPost, "/api/webhooks",
+		`{"url":"https://example.com/hook","events":["link.teleported"]}`)
+	require.Equal(t, http.StatusBadRequest, response.Code)
+	response = call(handlers.CreateWebhook, nil, http.MethodPost, "/api/webhooks", `{"url":"https://example.com/hook"}`)
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+
+	response = call(handlers.CreateWebhook, alice, http.MethodPost, "/api/webhooks",
+		`{"url":"https://example.com/hook","events":["link.created","link.clicked"]}`)
+	require.Equal(t, http.StatusCreated, response.Code)
+	var created webhookCreateResponseDTO
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &created))
+	require.True(t, strings.HasPrefix(created.Secret, "whsec_"))
+	require.Equal(t, []string{events.LinkCreated, events.LinkClicked}, created.Events)
+	require.Equal(t, "alice", created.Owner)
+
+	// посторонним в пространство не подписаться
+	body := `{"url":"https://example.com/team","workspace":"` + ws.ID + `"}`
+	response = call(handlers.CreateWebhook, bob, http.MethodPost, "/api/webhooks", body)
+	require.Equal(t, http.StatusForbidden, response.Code)
+	response = call(handlers.CreateWebhook, alice, http.MethodPost, "/api/webhooks", body)
+	require.Equal(t, http.StatusCreated, response.Code)
+
+	response = call(handlers.ListWebhooks, alice, http.MethodGet, "/api/webhooks", "")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.NotContains(t, response.Body.String(), "secret")
+	var list []webhookDTO
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &list))
+	require.Equal(t, []webhookDTO{created.webhookDTO}, list)
+	response = call(handlers.ListWebhooks, bob, http.MethodGet, "/api/webhooks", "")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.JSONEq(t, `[]`, response.Body.String())
+
+	// чужая подписка выглядит несуществующей
+	response = call(handlers.ListWebhookDeliveries, bob, http.MethodGet, "/", "", "webhookID", created.ID)
+	require.Equal(t, http.StatusNotFound, response.Code)
+	response = call(handlers.DeleteWebhook, bob, http.MethodDelete, "/", "", "webhookID", created.ID)
+	require.Equal(t, http.StatusNotFound, response.Code)
+
+	response = call(handlers.ListWebhookDeliveries, alice, http.MethodGet, "/", "", "webhookID", created.ID)
+	require.Equal(t, http.StatusOK, response.Code)
+	require.JSONEq(t, `[]`, response.Body.String())
+	response = call(handlers.ListWebhookDeadLetters, alice, http.MethodGet, "/", "", "webhookID", created.ID)
+	require.Equal(t, http.StatusOK, response.Code)
+	require.JSONEq(t, `[]`, response.Body.String())
+	response = call(handlers.RetryWebhookDelivery, alice, http.MethodPost, "/", "",
+		"webhookID", created.ID, "deliveryID", "dl_nope")
+	require.Equal(t, http.StatusNotFound, response.Code)
+
+	response = call(handlers.DeleteWebhook, alice, http.MethodDelete, "/", "", "webhookID", created.ID)
+	require.Equal(t, http.StatusNoContent, response.Code)
+	response = call(handlers.DeleteWebhook, alice, http.MethodDelete, "/", "", "webhookID", created.ID)
+	require.Equal(t, http.StatusNotFound, response.Code)
+}
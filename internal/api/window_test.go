@@ -0,0 +1,73 @@
+package api
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/mocks"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetFullAddressActivationWindow(t *testing.T) {
+	start := time.Date(2024, 9, 1, 10, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 9, 30, 0, 0, 0, 0, time.UTC)
+	link := &storage.Link{ID: "camp", URL: "https://example.com/campaign", NotBefore: start, NotAfter: end}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		wantCode int
+	}{
+		{"before start", start.Add(-time.Second), http.StatusNotFound},
+		{"at start", start, http.StatusTemporaryRedirect},
+		{"inside window", start.Add(48 * time.Hour), http.StatusTemporaryRedirect},
+		{"at end", end, http.StatusGone},
+		{"after end", end.Add(time.Hour), http.StatusGone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStorage := mocks.NewMockStorager(ctrl)
+			handlers := New(mockStorage, nil, WithClock(func() time.Time { return tt.now }))
+			mockStorage.EXPECT().GetLink(link.ID).Return(link, nil)
+
+			request := httptest.NewRequest(http.MethodGet, "/"+link.ID, nil)
+			request.SetPathValue("id", link.ID)
+			response := httptest.NewRecorder()
+			handlers.GetFullAddress(response, request)
+
+			require.Equal(t, tt.wantCode, response.Code)
+		})
+	}
+}
+
+func TestGetFullAddressComingSoonPage(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	start := time.Date(2024, 9, 1, 10, 0, 0, 0, time.UTC)
+	page := template.Must(template.New("soon").Parse(`Coming {{.NotBefore.Format "2006-01-02"}}`))
+	mockStorage := mocks.NewMockStorager(ctrl)
+	handlers := New(mockStorage, nil,
+		WithClock(func() time.Time { return start.Add(-time.Hour) }),
+		WithComingSoonPage(page))
+
+	mockStorage.EXPECT().GetLink("camp").Return(&storage.Link{ID: "camp", URL: "https://example.com/", NotBefore: start}, nil)
+
+	request := httptest.NewRequest(http.MethodGet, "/camp", nil)
+	request.SetPathValue("id", "camp")
+	response := httptest.NewRecorder()
+	handlers.GetFullAddress(response, request)
+
+	require.Equal(t, http.StatusOK, response.Code)
+	require.Equal(t, "Coming 2024-09-01", response.Body.String())
+	require.Empty(t, response.Header().Get("Location"))
+	require.Equal(t, "Sun, 01 Sep 2024 10:00:00 GMT", response.Header().Get("Retry-After"))
+}
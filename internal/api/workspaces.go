@@ -0,0 +1,312 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/authz"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/adettelle/go-url-shortener/internal/workspace"
+	"go.uber.org/zap"
+)
+
+// WorkspaceStore keeps workspaces and their members.
+type WorkspaceStore interface {
+	Create(name, creator string) (*workspace.Workspace, error)
+	Get(id string) (*workspace.Workspace, error)
+	ListFor(actor string) []*workspace.Workspace
+	SetMember(id, actor string, role workspace.Role) (*workspace.Workspace, error)
+	RemoveMember(id, actor string) (*workspace.Workspace, error)
+}
+
+// WithWorkspaces enables the /api/workspaces endpoints and links shared
+// by a team.
+func WithWorkspaces(s WorkspaceStore) Option {
+	return func(h *Handlers) {
+		h.workspaces = s
+	}
+}
+
+type workspaceCreateRequestDTO struct {
+	Name string `json:"name"`
+}
+
+type memberDTO struct {
+	Actor   string    `json:"actor"`
+	Role    string    `json:"role"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+type workspaceDTO struct {
+	ID        string      `json:"id"`
+	Name      string      `json:"name"`
+	CreatedAt time.Time   `json:"created_at"`
+	CreatedBy string      `json:"created_by"`
+	Members   []memberDTO `json:"members"`
+}
+
+type memberUpdateRequestDTO struct {
+	Role string `json:"role"` // member или owner; по умолчанию member
+}
+
+type workspaceStatsResponseDTO struct {
+	linkStatsResponseDTO
+	Links int `json:"links"` // сколько ссылок вошло в отчёт
+}
+
+func toWorkspaceDTO(ws *workspace.Workspace) workspaceDTO {
+	dto := workspaceDTO{
+		ID:        ws.ID,
+		Name:      ws.Name,
+		CreatedAt: ws.CreatedAt,
+		CreatedBy: ws.CreatedBy,
+		Members:   make([]memberDTO, 0, len(ws.Members)),
+	}
+	for _, m := range ws.Members {
+		dto.Members = append(dto.Members, memberDTO{Actor: m.Actor, Role: string(m.Role), AddedAt: m.AddedAt})
+	}
+	return dto
+}
+
+// workspaceOf returns the workspace sharing link, nil for personal links
+// and when workspaces are not configured.
+func (h *Handlers) workspaceOf(link *storage.Link) *workspace.Workspace {
+	if link.Workspace == "" || h.workspaces == nil {
+		return nil
+	}
+	ws, err := h.workspaces.Get(link.Workspace)
+	if err != nil {
+		errlog.Info("error in getting workspace of link", zap.String("id", link.ID), zap.Error(err))
+		return nil
+	}
+	return ws
+}
+
+// lookupWorkspace loads the workspace with the given id, unknown ids are
+// answered with status. If it fails, the error response is already written
+// and lookupWorkspace returns false.
+func (h *Handlers) lookupWorkspace(w http.ResponseWriter, id string, status int) (*workspace.Workspace, bool) {
+	if h.workspaces == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return nil, false
+	}
+	ws, err := h.workspaces.Get(id)
+	if err != nil {
+		var noWorkspace *workspace.NoWorkspaceError
+		if errors.As(err, &noWorkspace) {
+			w.WriteHeader(status)
+			return nil, false
+		}
+		errlog.Error("error in getting workspace", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return nil, false
+	}
+	return ws, true
+}
+
+// CreateWorkspace handles POST /api/workspaces. The caller becomes the
+// first owner of the workspace.
+func (h *Handlers) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorize(w, r, authz.ActionCreateWorkspace, nil) {
+		return
+	}
+	if h.workspaces == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	var requestBody workspaceCreateRequestDTO
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		errlog.Error("error in unmarshalling json", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	ws, err := h.workspaces.Create(requestBody.Name, requestActor(r))
+	if err != nil {
+		var invalidName *workspace.InvalidNameError
+		if errors.As(err, &invalidName) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		errlog.Error("error in creating workspace", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, toWorkspaceDTO(ws))
+}
+
+// ListWorkspaces handles GET /api/workspaces: the workspaces of the caller.
+func (h *Handlers) ListWorkspaces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authorize(w, r, authz.ActionList, nil) {
+		return
+	}
+	actor, ok := requireActor(w, r)
+	if !ok {
+		return
+	}
+	if h.workspaces == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	list := h.workspaces.ListFor(actor)
+	dtos := make([]workspaceDTO, 0, len(list))
+	for _, ws := range list {
+		dtos = append(dtos, toWorkspaceDTO(ws))
+	}
+	writeJSON(w, http.StatusOK, dtos)
+}
+
+// GetWorkspace handles GET /api/workspaces/{workspaceID}.
+func (h *Handlers) GetWorkspace(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	ws, ok := h.lookupWorkspace(w, r.PathValue("workspaceID"), http.StatusNotFound)
+	if !ok {
+		return
+	}
+	if !h.authorizeWorkspace(w, r, authz.ActionViewWorkspace, ws) {
+		return
+	}
+	writeJSON(w, http.StatusOK, toWorkspaceDTO(ws))
+}
+
+// SetWorkspaceMember handles PUT /api/workspaces/{workspaceID}/members/{member}.
+// It adds the member or changes their role; only owners may do it.
+func (h *Handlers) SetWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	ws, ok := h.lookupWorkspace(w, r.PathValue("workspaceID"), http.StatusNotFound)
+	if !ok {
+		return
+	}
+	if !h.authorizeWorkspace(w, r, authz.ActionManageMembers, ws) {
+		return
+	}
+
+	requestBody := memberUpdateRequestDTO{Role: string(workspace.RoleMember)}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			errlog.Error("error in unmarshalling json", zap.Error(err))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	ws, err := h.workspaces.SetMember(ws.ID, r.PathValue("member"), workspace.Role(requestBody.Role))
+	if err != nil {
+		h.writeMembershipError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toWorkspaceDTO(ws))
+}
+
+// RemoveWorkspaceMember handles DELETE /api/workspaces/{workspaceID}/members/{member}.
+// Owners may remove anyone, members may leave on their own.
+func (h *Handlers) RemoveWorkspaceMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	ws, ok := h.lookupWorkspace(w, r.PathValue("workspaceID"), http.StatusNotFound)
+	if !ok {
+		return
+	}
+	member := r.PathValue("member")
+	action := authz.ActionManageMembers
+	if member == requestActor(r) {
+		action = authz.ActionViewWorkspace
+	}
+	if !h.authorizeWorkspace(w, r, action, ws) {
+		return
+	}
+
+	if _, err := h.workspaces.RemoveMember(ws.ID, member); err != nil {
+		h.writeMembershipError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handlers) writeMembershipError(w http.ResponseWriter, err error) {
+	var (
+		noMember  *workspace.NoMemberError
+		lastOwner *workspace.LastOwnerError
+		invalid   *workspace.InvalidMemberError
+	)
+	switch {
+	case errors.As(err, &noMember):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.As(err, &lastOwner):
+		w.WriteHeader(http.StatusConflict)
+	case errors.As(err, &invalid):
+		w.WriteHeader(http.StatusBadRequest)
+	default:
+		errlog.Error("error in changing members", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// GetWorkspaceStats handles GET /api/workspaces/{workspaceID}/stats: the
+// clicks of all links of the workspace together. It takes the same
+// parameters as GetLinkStats.
+func (h *Handlers) GetWorkspaceStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if h.stats == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+	ws, ok := h.lookupWorkspace(w, r.PathValue("workspaceID"), http.StatusNotFound)
+	if !ok {
+		return
+	}
+	if !h.authorizeWorkspace(w, r, authz.ActionViewWorkspace, ws) {
+		return
+	}
+
+	q, err := parseStatsQuery(r, h.clock())
+	if err != nil {
+		errlog.Info("invalid stats query", zap.Error(err))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	links, err := h.repo.ListLinks(storage.LinkFilter{Workspace: ws.ID})
+	if err != nil {
+		errlog.Error("error in listing links", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	q.ShortIDs = make([]string, 0, len(links))
+	for _, link := range links {
+		q.ShortIDs = append(q.ShortIDs, link.Key())
+	}
+
+	stats, err := h.stats.Stats(r.Context(), q)
+	if err != nil {
+		errlog.Error("error in getting stats", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, workspaceStatsResponseDTO{
+		linkStatsResponseDTO: toStatsDTO(ws.ID, q, stats),
+		Links:                len(links),
+	})
+}
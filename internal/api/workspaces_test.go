@@ -0,0 +1,167 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/analytics"
+	"github.com/adettelle/go-url-shortener/internal/auth"
+	"github.com/adettelle/go-url-shortener/internal/config"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/adettelle/go-url-shortener/internal/workspace"
+	"github.com/stretchr/testify/require"
+)
+
+// call runs handler as principal with the given path values.
+func call(handler http.HandlerFunc, p *auth.Principal, method, target, body string, pathValues ...string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(pathValues); i += 2 {
+		request.SetPathValue(pathValues[i], pathValues[i+1])
+	}
+	if p != nil {
+		request = withPrincipal(request, p)
+	}
+	response := httptest.NewRecorder()
+	handler(response, request)
+	return response
+}
+
+func TestWorkspaceSharedLinks(t *testing.T) {
+	repo := storage.New()
+	handlers := New(repo, &config.Config{Domains: []string{"http://localhost:8080"}},
+		WithWorkspaces(workspace.NewStore()))
+
+	response := call(handlers.CreateWorkspace, alice, http.MethodPost, "/api/workspaces", `{"name":"Marketing"}`)
+	require.Equal(t, http.StatusCreated, response.Code)
+	var ws workspaceDTO
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &ws))
+	require.Equal(t, []string{"alice"}, []string{ws.Members[0].Actor})
+
+	response = call(handlers.SetWorkspaceMember, alice, http.MethodPut, "/", "",
+		"workspaceID", ws.ID, "member", "bob")
+	require.Equal(t, http.StatusOK, response.Code)
+
+	// ссылку в пространстве может создать только участник
+	body := `{"url":"https://example.com/spring","workspace":"` + ws.ID + `"}`
+	response = call(handlers.CreateShortAddressJSON, carol, http.MethodPost, "/api/shorten", body)
+	require.Equal(t, http.StatusForbidden, response.Code)
+	response = call(handlers.CreateShortAddressJSON, alice, http.MethodPost, "/api/shorten", body)
+	require.Equal(t, http.StatusCreated, response.Code)
+	response = call(handlers.CreateShortAddressJSON, alice, http.MethodPost, "/api/shorten",
+		`{"url":"https://example.com/","workspace":"ws_nope"}`)
+	require.Equal(t, http.StatusBadRequest, response.Code)
+
+	links, err := repo.ListLinks(storage.LinkFilter{Workspace: ws.ID})
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	id := links[0].ID
+
+	// bob меняет ссылку, созданную alice, посторонний не может
+	for _, tt := range []struct {
+		principal *auth.Principal
+		want      int
+	}{{carol, http.StatusForbidden}, {bob, http.StatusOK}} {
+		request := httptest.NewRequest(http.MethodPatch, "/api/links/"+id, strings.NewReader(`{"url":"https://example.com/summer"}`))
+		request.SetPathValue("id", id)
+		request.Header.Set("If-Match", `"1"`)
+		response = httptest.NewRecorder()
+		handlers.UpdateLink(response, withPrincipal(request, tt.principal))
+		require.Equal(t, tt.want, response.Code)
+	}
+
+	response = call(handlers.ListLinks, bob, http.MethodGet, "/api/links?workspace="+ws.ID, "")
+	require.Equal(t, http.StatusOK, response.Code)
+	var list []linkResponseDTO
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &list))
+	require.Len(t, list, 1)
+	require.Equal(t, "https://example.com/summer", list[0].URL)
+	require.Equal(t, ws.ID, list[0].Workspace)
+	require.Equal(t, "alice", list[0].Owner)
+
+	response = call(handlers.ListLinks, carol, http.MethodGet, "/api/links?workspace="+ws.ID, "")
+	require.Equal(t, http.StatusForbidden, response.Code)
+
+	// в личном списке alice ссылок пространства нет
+	response = call(handlers.ListLinks, alice, http.MethodGet, "/api/links", "")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.JSONEq(t, `[]`, response.Body.String())
+	response = call(handlers.ListLinks, nil, http.MethodGet, "/api/links", "")
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+}
+
+func TestWorkspaceMembership(t *testing.T) {
+	store := workspace.NewStore()
+	handlers := New(storage.New(), &config.Config{Domains: []string{"http://localhost:8080"}}, WithWorkspaces(store))
+
+	response := call(handlers.CreateWorkspace, nil, http.MethodPost, "/api/workspaces", `{"name":"Team"}`)
+	require.Equal(t, http.StatusUnauthorized, response.Code)
+
+	ws, err := store.Create("Team", "alice")
+	require.NoError(t, err)
+	_, err = store.SetMember(ws.ID, "bob", workspace.RoleMember)
+	require.NoError(t, err)
+
+	// участник не управляет составом, но может выйти сам
+	response = call(handlers.SetWorkspaceMember, bob, http.MethodPut, "/", "", "workspaceID", ws.ID, "member", "carol")
+	require.Equal(t, http.StatusForbidden, response.Code)
+	response = call(handlers.GetWorkspace, carol, http.MethodGet, "/", "", "workspaceID", ws.ID)
+	require.Equal(t, http.StatusForbidden, response.Code)
+	response = call(handlers.GetWorkspace, bob, http.MethodGet, "/", "", "workspaceID", ws.ID)
+	require.Equal(t, http.StatusOK, response.Code)
+
+	response = call(handlers.SetWorkspaceMember, alice, http.MethodPut, "/", `{"role":"guest"}`,
+		"workspaceID", ws.ID, "member", "carol")
+	require.Equal(t, http.StatusBadRequest, response.Code)
+	response = call(handlers.RemoveWorkspaceMember, alice, http.MethodDelete, "/", "",
+		"workspaceID", ws.ID, "member", "alice")
+	require.Equal(t, http.StatusConflict, response.Code)
+	response = call(handlers.RemoveWorkspaceMember, bob, http.MethodDelete, "/", "",
+		"workspaceID", ws.ID, "member", "bob")
+	require.Equal(t, http.StatusNoContent, response.Code)
+
+	response = call(handlers.ListWorkspaces, bob, http.MethodGet, "/api/workspaces", "")
+	require.Equal(t, http.StatusOK, response.Code)
+	require.JSONEq(t, `[]`, response.Body.String())
+	response = call(handlers.GetWorkspace, alice, http.MethodGet, "/", "", "workspaceID", "ws_nope")
+	require.Equal(t, http.StatusNotFound, response.Code)
+}
+
+func TestGetWorkspaceStats(t *testing.T) {
+	repo := storage.New()
+	store := workspace.NewStore()
+	clicks := analytics.NewMemoryStore()
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	handlers := New(repo, &config.Config{Domains: []string{"http://localhost:8080"}},
+		WithWorkspaces(store), WithStats(clicks), WithClock(func() time.Time { return now }))
+
+	ws, err := store.Create("Team", "alice")
+	require.NoError(t, err)
+	first, err := repo.AddLink(storage.Link{URL: "https://example.com/1", CreatedBy: "alice", Workspace: ws.ID})
+	require.NoError(t, err)
+	second, err := repo.AddLink(storage.Link{URL: "https://example.com/2", CreatedBy: "bob", Workspace: ws.ID})
+	require.NoError(t, err)
+	personal, err := repo.AddLink(storage.Link{URL: "https://example.com/3", CreatedBy: "alice"})
+	require.NoError(t, err)
+
+	require.NoError(t, clicks.SaveClicks(context.Background(), []analytics.Click{
+		{Time: now.Add(-time.Hour), ShortID: first, IPHash: "a"},
+		{Time: now.Add(-time.Hour), ShortID: second, IPHash: "b"},
+		{Time: now.Add(-time.Hour), ShortID: personal, IPHash: "c"},
+	}))
+
+	response := call(handlers.GetWorkspaceStats, alice, http.MethodGet, "/", "", "workspaceID", ws.ID)
+	require.Equal(t, http.StatusOK, response.Code)
+	var stats workspaceStatsResponseDTO
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &stats))
+	require.Equal(t, ws.ID, stats.ID)
+	require.Equal(t, 2, stats.Links)
+	require.Equal(t, 2, stats.TotalClicks)
+
+	response = call(handlers.GetWorkspaceStats, bob, http.MethodGet, "/", "", "workspaceID", ws.ID)
+	require.Equal(t, http.StatusForbidden, response.Code)
+}
@@ -0,0 +1,239 @@
+// Package apikey issues and checks API keys of programmatic clients.
+//
+// A key looks like "sk_<id>_<secret>". Only the SHA-256 of the whole key is
+// kept, the plaintext is shown once when the key is issued. Keys are random
+// and long, so a fast hash is enough and makes every request cheap to check.
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/auth"
+)
+
+// TokenPrefix starts every API key.
+const TokenPrefix = "sk_"
+
+const (
+	idBytes     = 6
+	secretBytes = 24
+	bootstrapID = "bootstrap"
+)
+
+// Key is an issued API key without its secret.
+type Key struct {
+	ID         string
+	Name       string
+	Scopes     []string
+	Hash       string // hex SHA-256 of the full key
+	CreatedAt  time.Time
+	LastUsedAt time.Time // zero until the key is used
+}
+
+type NoKeyError struct {
+	id string
+}
+
+func (e *NoKeyError) Error() string {
+	return fmt.Sprintf("no api key %s", e.id)
+}
+
+// InvalidKeyError is returned for malformed, unknown and revoked keys alike,
+// so callers can't tell which keys exist.
+type InvalidKeyError struct{}
+
+func (e *InvalidKeyError) Error() string {
+	return "invalid api key"
+}
+
+type InvalidScopeError struct {
+	scope string
+}
+
+func (e *InvalidScopeError) Error() string {
+	return fmt.Sprintf("unknown scope %q", e.scope)
+}
+
+// Store keeps API keys in memory.
+type Store struct {
+	mu   sync.Mutex
+	keys map[string]*Key
+	now  func() time.Time
+}
+
+func NewStore() *Store {
+	return &Store{keys: make(map[string]*Key), now: time.Now}
+}
+
+// Issue creates a key with the given scopes and returns it together with
+// its plaintext, which can't be recovered later.
+func (s *Store) Issue(name string, scopes []string) (string, *Key, error) {
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	id, err := randomHex(idBytes)
+	if err != nil {
+		return "", nil, err
+	}
+	secret, err := randomHex(secretBytes)
+	if err != nil {
+		return "", nil, err
+	}
+	token := TokenPrefix + id + "_" + secret
+
+	key, err := s.add(id, name, token, scopes)
+	if err != nil {
+		return "", nil, err
+	}
+	return token, key, nil
+}
+
+// Bootstrap registers a key chosen by the operator, for example the first
+// admin key from the configuration. The id of the key is "bootstrap".
+// Such a key can be revoked but comes back with the next start.
+func (s *Store) Bootstrap(token string, scopes []string) error {
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return err
+	}
+	_, err = s.add(bootstrapID, bootstrapID, token, scopes)
+	return err
+}
+
+func (s *Store) add(id, name, token string, scopes []string) (*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[id]; ok {
+		return nil, fmt.Errorf("api key %s already exists", id)
+	}
+	key := &Key{
+		ID:        id,
+		Name:      name,
+		Scopes:    scopes,
+		Hash:      hashToken(token),
+		CreatedAt: s.now(),
+	}
+	s.keys[id] = key
+	return key.clone(), nil
+}
+
+// List returns all keys ordered by creation time.
+func (s *Store) List() []Key {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]Key, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, *key.clone())
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+		}
+		return keys[i].ID < keys[j].ID
+	})
+	return keys
+}
+
+// Revoke deletes the key, it stops working immediately.
+func (s *Store) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[id]; !ok {
+		return &NoKeyError{id: id}
+	}
+	delete(s.keys, id)
+	return nil
+}
+
+// Authenticate checks token and records its use. It returns the principal
+// the key acts as.
+func (s *Store) Authenticate(token string) (*auth.Principal, error) {
+	id := keyID(token)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		key, ok = s.keys[bootstrapID]
+	}
+	if !ok {
+		return nil, &InvalidKeyError{}
+	}
+	hash := hashToken(token)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(key.Hash)) != 1 {
+		return nil, &InvalidKeyError{}
+	}
+	key.LastUsedAt = s.now()
+
+	return &auth.Principal{
+		APIKeyID: key.ID,
+		Scopes:   append([]string(nil), key.Scopes...),
+	}, nil
+}
+
+func (k *Key) clone() *Key {
+	keyCopy := *k
+	keyCopy.Scopes = append([]string(nil), k.Scopes...)
+	return &keyCopy
+}
+
+// keyID extracts the id part of "sk_<id>_<secret>". Keys registered with
+// Bootstrap may have any form, tokens without an id are checked against it.
+func keyID(token string) string {
+	rest, ok := strings.CutPrefix(token, TokenPrefix)
+	if !ok {
+		return ""
+	}
+	id, _, ok := strings.Cut(rest, "_")
+	if !ok || len(id) != 2*idBytes {
+		return ""
+	}
+	return id
+}
+
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, &InvalidScopeError{scope: ""}
+	}
+	seen := make(map[string]bool, len(scopes))
+	var result []string
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !auth.IsScope(scope) {
+			return nil, &InvalidScopeError{scope: scope}
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
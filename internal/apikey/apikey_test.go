@@ -0,0 +1,65 @@
+package apikey
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/auth"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssueAndAuthenticate(t *testing.T) {
+	store := NewStore()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	token, key, err := store.Issue("ci", []string{"read", "create", "read"})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(token, TokenPrefix+key.ID+"_"))
+	require.Equal(t, []string{auth.ScopeCreate, auth.ScopeRead}, key.Scopes)
+	require.NotContains(t, key.Hash, token)
+	require.True(t, key.LastUsedAt.IsZero())
+
+	now = now.Add(time.Hour)
+	p, err := store.Authenticate(token)
+	require.NoError(t, err)
+	require.Equal(t, key.ID, p.APIKeyID)
+	require.True(t, p.HasScope(auth.ScopeCreate))
+	require.False(t, p.HasScope(auth.ScopeDelete))
+
+	keys := store.List()
+	require.Len(t, keys, 1)
+	require.Equal(t, now, keys[0].LastUsedAt)
+
+	_, err = store.Authenticate(token + "x")
+	require.ErrorAs(t, err, new(*InvalidKeyError))
+	_, err = store.Authenticate("garbage")
+	require.ErrorAs(t, err, new(*InvalidKeyError))
+
+	require.NoError(t, store.Revoke(key.ID))
+	_, err = store.Authenticate(token)
+	require.ErrorAs(t, err, new(*InvalidKeyError))
+	require.ErrorAs(t, store.Revoke(key.ID), new(*NoKeyError))
+}
+
+func TestIssueInvalidScope(t *testing.T) {
+	store := NewStore()
+	_, _, err := store.Issue("ci", []string{"write"})
+	require.ErrorAs(t, err, new(*InvalidScopeError))
+	_, _, err = store.Issue("ci", nil)
+	require.ErrorAs(t, err, new(*InvalidScopeError))
+}
+
+func TestBootstrap(t *testing.T) {
+	store := NewStore()
+	require.NoError(t, store.Bootstrap("operator-secret", []string{auth.ScopeAdmin}))
+
+	p, err := store.Authenticate("operator-secret")
+	require.NoError(t, err)
+	require.Equal(t, "bootstrap", p.APIKeyID)
+	require.True(t, p.HasScope(auth.ScopeDelete))
+
+	_, err = store.Authenticate("operator-secreT")
+	require.Error(t, err)
+}
@@ -0,0 +1,77 @@
+// Package auth describes the authenticated caller of a request.
+// Authentication middlewares put a Principal into the request context,
+// everything downstream reads it from there.
+package auth
+
+import "context"
+
+// Scopes an API key can be issued with.
+const (
+	ScopeCreate = "create" // create and edit links
+	ScopeRead   = "read"   // read links and their statistics
+	ScopeDelete = "delete" // delete links
+	ScopeAdmin  = "admin"  // manage API keys; implies every other scope
+)
+
+// Scopes lists every known scope.
+var Scopes = []string{ScopeCreate, ScopeRead, ScopeDelete, ScopeAdmin}
+
+// UserScopes are granted to users signed in with single sign-on.
+var UserScopes = []string{ScopeCreate, ScopeRead, ScopeDelete}
+
+// IsScope reports whether s is a known scope.
+func IsScope(s string) bool {
+	for _, scope := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Principal is the identity behind a request.
+type Principal struct {
+	UserID        string   // stable identifier of the user, empty for anonymous callers
+	APIKeyID      string   // identifier of the API key used, empty for other methods
+	Email         string   // user's e-mail from the identity provider, if known
+	EmailVerified bool     // the provider confirmed Email belongs to the user; only then it may grant a role
+	Name          string   // user's display name from the identity provider, if known
+	Scopes        []string // what the caller may do
+}
+
+// HasScope reports whether the principal was granted scope.
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Actor names the principal in link ownership and history: the user id,
+// "apikey:<id>" for API keys and "" for anonymous callers.
+func (p *Principal) Actor() string {
+	switch {
+	case p == nil:
+		return ""
+	case p.UserID != "":
+		return p.UserID
+	case p.APIKeyID != "":
+		return "apikey:" + p.APIKeyID
+	}
+	return ""
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying p.
+func NewContext(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the principal stored in ctx or nil.
+func FromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(contextKey{}).(*Principal)
+	return p
+}
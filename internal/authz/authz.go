@@ -0,0 +1,228 @@
+// Package authz decides what a caller may do. Every caller has a role:
+// viewers may look at their links, creators may also create links and
+// manage their own ones, admins may do anything. Reading, editing and
+// deleting a link and its statistics additionally require owning it,
+// unless the caller is an admin. Links of a workspace are owned by all of
+// its members.
+package authz
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/adettelle/go-url-shortener/internal/auth"
+	"github.com/adettelle/go-url-shortener/internal/logger"
+	"github.com/adettelle/go-url-shortener/internal/workspace"
+	"go.uber.org/zap"
+)
+
+// Role is a set of permissions. Roles are ordered, each one includes
+// the permissions of the previous.
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleViewer
+	RoleCreator
+	RoleAdmin
+)
+
+var roleNames = []string{"none", "viewer", "creator", "admin"}
+
+func (r Role) String() string {
+	if r < RoleNone || int(r) >= len(roleNames) {
+		return fmt.Sprintf("Role(%d)", int(r))
+	}
+	return roleNames[r]
+}
+
+type InvalidRoleError struct {
+	name string
+}
+
+func (e *InvalidRoleError) Error() string {
+	return fmt.Sprintf("unknown role %q", e.name)
+}
+
+// ParseRole converts a role name to a Role.
+func ParseRole(name string) (Role, error) {
+	for i, n := range roleNames {
+		if strings.EqualFold(name, n) {
+			return Role(i), nil
+		}
+	}
+	return RoleNone, &InvalidRoleError{name: name}
+}
+
+// Action is an operation to be authorized.
+type Action string
+
+const (
+	ActionFollow     Action = "follow"      // redirect, QR code, unlock: public
+	ActionSignIn     Action = "sign-in"     // login, logout, who am I: public
+	ActionCreate     Action = "create"      // shorten a URL
+	ActionList       Action = "list"        // list own links, tags and workspaces
+	ActionView       Action = "view"        // read a link and its history
+	ActionViewStats  Action = "view-stats"  // read click statistics of a link
+	ActionEdit       Action = "edit"        // change or roll back a link
+	ActionDelete     Action = "delete"      // delete a link
+	ActionManageKeys Action = "manage-keys" // issue, list and revoke API keys
+
+	ActionCreateWorkspace Action = "create-workspace" // start a team
+	ActionViewWorkspace   Action = "view-workspace"   // read a workspace, its links and statistics
+	ActionManageMembers   Action = "manage-members"   // add, remove and promote members
+)
+
+// Request is one authorization question.
+type Request struct {
+	Principal *auth.Principal // nil for anonymous callers
+	Action    Action
+	Resource  string // link id, for logs
+	Owner     string // actor that owns the resource, see auth.Principal.Actor
+
+	// Workspace owns the resource or is the resource itself; nil for
+	// personal links and actions outside workspaces.
+	Workspace *workspace.Workspace
+}
+
+// Decision is the answer to a Request.
+type Decision struct {
+	Allowed bool
+	Role    Role
+	Reason  string
+}
+
+// Policy maps callers to roles and decides requests.
+type Policy struct {
+	anonymous Role
+	users     Role
+	admins    map[string]bool
+}
+
+// New creates a policy. anonymous is the role of callers without
+// credentials, users the role of signed in users; admins lists user ids
+// or e-mails that are admins; an e-mail counts only if the identity
+// provider has verified it. API keys get their role from their scopes.
+func New(anonymous, users Role, admins []string) *Policy {
+	p := &Policy{anonymous: anonymous, users: users, admins: make(map[string]bool, len(admins))}
+	for _, a := range admins {
+		p.admins[strings.ToLower(a)] = true
+	}
+	return p
+}
+
+// RoleOf returns the role of the caller.
+func (p *Policy) RoleOf(principal *auth.Principal) Role {
+	switch {
+	case principal == nil || principal.Actor() == "":
+		return p.anonymous
+	case principal.APIKeyID != "":
+		switch {
+		case principal.HasScope(auth.ScopeAdmin):
+			return RoleAdmin
+		case principal.HasScope(auth.ScopeCreate):
+			return RoleCreator
+		}
+		return RoleViewer
+	case p.admins[strings.ToLower(principal.UserID)] ||
+		(principal.Email != "" && principal.EmailVerified && p.admins[strings.ToLower(principal.Email)]):
+		return RoleAdmin
+	}
+	return p.users
+}
+
+// Decide answers req without side effects.
+func (p *Policy) Decide(req Request) Decision {
+	role := p.RoleOf(req.Principal)
+	actor := req.Principal.Actor()
+	member := req.Workspace.IsMember(actor)
+	owner := actor != "" && (actor == req.Owner || member)
+
+	allow := func(reason string) Decision { return Decision{Allowed: true, Role: role, Reason: reason} }
+	deny := func(reason string) Decision { return Decision{Allowed: false, Role: role, Reason: reason} }
+
+	switch req.Action {
+	case ActionFollow, ActionSignIn:
+		return allow("public")
+	}
+	if role == RoleAdmin {
+		return allow("admin")
+	}
+
+	switch req.Action {
+	case ActionCreate:
+		if role < RoleCreator {
+			return deny("creator role required")
+		}
+		if req.Workspace != nil && !member {
+			return deny("only members may add links to the workspace")
+		}
+		return allow("creator")
+	case ActionList:
+		if role >= RoleViewer {
+			return allow("viewer")
+		}
+		return deny("viewer role required")
+	case ActionView:
+		// ссылка раскрывает адрес назначения, в том числе защищённой паролем
+		if role >= RoleViewer && owner {
+			return allow("owner")
+		}
+		return deny("only the owner or an admin may view the link")
+	case ActionViewStats:
+		if role >= RoleViewer && owner {
+			return allow("owner")
+		}
+		return deny("only the owner or an admin may view statistics")
+	case ActionEdit, ActionDelete:
+		if role >= RoleCreator && owner {
+			return allow("owner")
+		}
+		return deny("only the owner or an admin may change the link")
+	case ActionManageKeys:
+		return deny("admin role required")
+	case ActionCreateWorkspace:
+		if role >= RoleCreator && actor != "" {
+			return allow("creator")
+		}
+		return deny("signed in creator required")
+	case ActionViewWorkspace:
+		if role >= RoleViewer && member {
+			return allow("member")
+		}
+		return deny("only members may view the workspace")
+	case ActionManageMembers:
+		if role >= RoleCreator && req.Workspace.IsOwner(actor) {
+			return allow("workspace owner")
+		}
+		return deny("only workspace owners may manage members")
+	}
+	return deny("unknown action")
+}
+
+// Authorize decides req and logs the decision. Denials and everything
+// except public actions are logged at info level.
+func (p *Policy) Authorize(req Request) Decision {
+	d := p.Decide(req)
+	log := logger.Logger.Info
+	if d.Allowed && (req.Action == ActionFollow || req.Action == ActionSignIn) {
+		log = logger.Logger.Debug
+	}
+	log("authorization decision",
+		zap.String("actor", req.Principal.Actor()),
+		zap.String("role", d.Role.String()),
+		zap.String("action", string(req.Action)),
+		zap.String("resource", req.Resource),
+		zap.String("owner", req.Owner),
+		zap.String("workspace", workspaceID(req.Workspace)),
+		zap.Bool("allowed", d.Allowed),
+		zap.String("reason", d.Reason))
+	return d
+}
+
+func workspaceID(ws *workspace.Workspace) string {
+	if ws == nil {
+		return ""
+	}
+	return ws.ID
+}
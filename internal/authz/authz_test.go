@@ -0,0 +1,107 @@
+package authz
+
+import (
+	"testing"
+
+	"github.com/adettelle/go-url-shortener/internal/auth"
+	"github.com/adettelle/go-url-shortener/internal/workspace"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleOf(t *testing.T) {
+	p := New(RoleViewer, RoleCreator, []string{"Root@Example.com", "ops"})
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		want      Role
+	}{
+		{"anonymous", nil, RoleViewer},
+		{"empty principal", &auth.Principal{}, RoleViewer},
+		{"user", &auth.Principal{UserID: "alice"}, RoleCreator},
+		{"admin by email", &auth.Principal{UserID: "x1", Email: "root@example.com", EmailVerified: true}, RoleAdmin},
+		{"unverified admin email", &auth.Principal{UserID: "x2", Email: "root@example.com"}, RoleCreator},
+		{"admin by id", &auth.Principal{UserID: "ops"}, RoleAdmin},
+		{"read key", &auth.Principal{APIKeyID: "k", Scopes: []string{auth.ScopeRead}}, RoleViewer},
+		{"create key", &auth.Principal{APIKeyID: "k", Scopes: []string{auth.ScopeRead, auth.ScopeCreate}}, RoleCreator},
+		{"admin key", &auth.Principal{APIKeyID: "k", Scopes: []string{auth.ScopeAdmin}}, RoleAdmin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, p.RoleOf(tt.principal))
+		})
+	}
+}
+
+func TestDecide(t *testing.T) {
+	p := New(RoleNone, RoleCreator, []string{"root"})
+	alice := &auth.Principal{UserID: "alice"}
+	root := &auth.Principal{UserID: "root"}
+	key := &auth.Principal{APIKeyID: "k1", Scopes: []string{auth.ScopeCreate}}
+
+	require.True(t, p.Decide(Request{Action: ActionFollow}).Allowed)
+	require.False(t, p.Decide(Request{Action: ActionCreate}).Allowed)
+	require.True(t, p.Decide(Request{Principal: alice, Action: ActionCreate}).Allowed)
+
+	require.True(t, p.Decide(Request{Principal: alice, Action: ActionEdit, Owner: "alice"}).Allowed)
+	require.False(t, p.Decide(Request{Principal: alice, Action: ActionEdit, Owner: "bob"}).Allowed)
+	require.False(t, p.Decide(Request{Principal: alice, Action: ActionDelete, Owner: ""}).Allowed)
+	require.True(t, p.Decide(Request{Principal: root, Action: ActionDelete, Owner: "bob"}).Allowed)
+
+	require.True(t, p.Decide(Request{Principal: alice, Action: ActionView, Owner: "alice"}).Allowed)
+	require.False(t, p.Decide(Request{Principal: alice, Action: ActionView, Owner: "bob"}).Allowed)
+	require.False(t, p.Decide(Request{Action: ActionView, Owner: ""}).Allowed)
+	require.True(t, p.Decide(Request{Principal: alice, Action: ActionList}).Allowed)
+
+	// ключ владеет ссылками, созданными с ним
+	require.True(t, p.Decide(Request{Principal: key, Action: ActionViewStats, Owner: "apikey:k1"}).Allowed)
+	require.False(t, p.Decide(Request{Principal: key, Action: ActionManageKeys}).Allowed)
+
+	// scope delete не делает ключ владельцем чужих ссылок
+	deleter := &auth.Principal{APIKeyID: "k2", Scopes: []string{auth.ScopeCreate, auth.ScopeDelete}}
+	require.False(t, p.Decide(Request{Principal: deleter, Action: ActionDelete, Owner: "bob"}).Allowed)
+	require.True(t, p.Decide(Request{Principal: deleter, Action: ActionDelete, Owner: "apikey:k2"}).Allowed)
+
+	d := p.Decide(Request{Principal: alice, Action: ActionManageKeys})
+	require.False(t, d.Allowed)
+	require.Equal(t, RoleCreator, d.Role)
+	require.NotEmpty(t, d.Reason)
+}
+
+func TestDecideWorkspace(t *testing.T) {
+	p := New(RoleCreator, RoleCreator, nil)
+	ws := &workspace.Workspace{ID: "ws_1", Members: []workspace.Member{
+		{Actor: "alice", Role: workspace.RoleOwner},
+		{Actor: "bob", Role: workspace.RoleMember},
+	}}
+	alice := &auth.Principal{UserID: "alice"}
+	bob := &auth.Principal{UserID: "bob"}
+	carol := &auth.Principal{UserID: "carol"}
+
+	// ссылку alice в общем пространстве может менять bob, но не carol
+	link := Request{Action: ActionEdit, Owner: "alice", Workspace: ws}
+	link.Principal = bob
+	require.True(t, p.Decide(link).Allowed)
+	link.Principal = carol
+	require.False(t, p.Decide(link).Allowed)
+
+	require.True(t, p.Decide(Request{Principal: bob, Action: ActionCreate, Workspace: ws}).Allowed)
+	require.False(t, p.Decide(Request{Principal: carol, Action: ActionCreate, Workspace: ws}).Allowed)
+	require.True(t, p.Decide(Request{Principal: bob, Action: ActionViewWorkspace, Workspace: ws}).Allowed)
+	require.False(t, p.Decide(Request{Principal: carol, Action: ActionViewWorkspace, Workspace: ws}).Allowed)
+	require.True(t, p.Decide(Request{Principal: alice, Action: ActionManageMembers, Workspace: ws}).Allowed)
+	require.False(t, p.Decide(Request{Principal: bob, Action: ActionManageMembers, Workspace: ws}).Allowed)
+
+	require.True(t, p.Decide(Request{Principal: carol, Action: ActionCreateWorkspace}).Allowed)
+	require.False(t, p.Decide(Request{Action: ActionCreateWorkspace}).Allowed)
+}
+
+func TestParseRole(t *testing.T) {
+	r, err := ParseRole("Creator")
+	require.NoError(t, err)
+	require.Equal(t, RoleCreator, r)
+	require.Equal(t, "creator", r.String())
+
+	_, err = ParseRole("owner")
+	require.ErrorAs(t, err, new(*InvalidRoleError))
+}
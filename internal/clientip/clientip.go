@@ -0,0 +1,86 @@
+// Package clientip determines the address of the client behind a request.
+// X-Forwarded-For is only honoured when the request comes from a trusted proxy,
+// otherwise any client could spoof its address.
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type Resolver struct {
+	trusted []*net.IPNet
+}
+
+// New creates a Resolver trusting the given proxies. Each entry is either
+// a CIDR (10.0.0.0/8) or a single IP address.
+func New(trustedProxies []string) (*Resolver, error) {
+	r := &Resolver{}
+	for _, proxy := range trustedProxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy: '%s'", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			r.trusted = append(r.trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy: '%s'", proxy)
+		}
+		r.trusted = append(r.trusted, ipNet)
+	}
+	return r, nil
+}
+
+// ClientIP returns the client address of req. When the direct peer is a
+// trusted proxy, X-Forwarded-For is walked from right to left and the first
+// address that is not a trusted proxy is returned.
+func (r *Resolver) ClientIP(req *http.Request) string {
+	remote := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+
+	if r == nil || !r.isTrusted(remote) {
+		return remote
+	}
+
+	var hops []string
+	for _, header := range req.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			// мусор в заголовке - дальше ему доверять нельзя
+			return remote
+		}
+		if !r.isTrusted(hop) {
+			return hop
+		}
+		remote = hop
+	}
+	return remote
+}
+
+func (r *Resolver) isTrusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range r.trusted {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package clientip
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIP(t *testing.T) {
+	resolver, err := New([]string{"10.0.0.0/8", "192.168.1.1"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    []string
+		want   string
+	}{
+		{"direct client", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"untrusted peer spoofing header", "203.0.113.7:5000", []string{"1.2.3.4"}, "203.0.113.7"},
+		{"trusted proxy", "10.1.2.3:5000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"proxy chain", "10.1.2.3:5000", []string{"198.51.100.1, 192.168.1.1"}, "198.51.100.1"},
+		{"spoofed left part ignored", "10.1.2.3:5000", []string{"1.1.1.1, 198.51.100.1"}, "198.51.100.1"},
+		{"several headers", "192.168.1.1:5000", []string{"198.51.100.1", "10.0.0.1"}, "198.51.100.1"},
+		{"only proxies", "10.1.2.3:5000", []string{"10.0.0.2"}, "10.0.0.2"},
+		{"garbage", "10.1.2.3:5000", []string{"not-an-ip"}, "10.1.2.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remote
+			for _, v := range tt.xff {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			require.Equal(t, tt.want, resolver.ClientIP(req))
+		})
+	}
+}
+
+func TestNewInvalidProxy(t *testing.T) {
+	_, err := New([]string{"10.0.0.0/33"})
+	require.Error(t, err)
+	_, err = New([]string{"nope"})
+	require.Error(t, err)
+}
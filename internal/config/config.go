@@ -5,22 +5,136 @@ import (
 	"fmt"
 	"log"
 	"net"
-	"net/url"
+	"net/http"
+	"os"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/adettelle/go-url-shortener/internal/authz"
+	"github.com/adettelle/go-url-shortener/internal/domain"
 	"github.com/kelseyhightower/envconfig"
 )
 
 const (
-	defaultAddress    = "localhost:8080"
-	defaultURLAddress = "http://localhost:8080"
+	defaultAddress              = "localhost:8080"
+	defaultURLAddress           = "http://localhost:8080"
+	defaultPolicyReloadInterval = 30 * time.Second
+	defaultCreateRateLimit      = 5
+	defaultCreateRateBurst      = 20
+	defaultRedirectRateLimit    = 50
+	defaultRedirectRateBurst    = 100
+	defaultAnalyticsBufferSize  = 10000
+	defaultAnalyticsBatchSize   = 500
+	defaultAnalyticsFlushPeriod = 2 * time.Second
+	defaultUnlockTTL            = 15 * time.Minute
+	defaultRedirectType         = 307
+	defaultPermanentCacheMaxAge = 5 * time.Minute
+	defaultSessionTTL           = 12 * time.Hour
+	defaultRole                 = "creator"
+	defaultMetadataTimeout      = 10 * time.Second
+	defaultMetadataMaxBytes     = 1 << 20
+	defaultMetadataWorkers      = 4
+	defaultMetadataMaxAge       = 7 * 24 * time.Hour
+	defaultLinkCheckInterval    = 24 * time.Hour
+	defaultLinkCheckConcurrency = 8
+	defaultLinkCheckHostDelay   = 2 * time.Second
+	defaultLinkCheckTimeout     = 10 * time.Second
+	defaultEventsBufferSize     = 10000
+	defaultWebhookTimeout       = 10 * time.Second
+	defaultWebhookMaxAttempts   = 8
+	defaultWebhookRetryDelay    = 30 * time.Second
+	defaultWebhookMaxRetryDelay = time.Hour
+	defaultWebhookConcurrency   = 16
 )
 
 type Config struct {
-	Address    string `envconfig:"SERVER_ADDRESS"` // отвечает за адрес запуска HTTP-сервера, например, localhost:8080
-	URLAddress string `envconfig:"BASE_URL"`       // базовый адрес результирующего сокращённого URL
+	Address string `envconfig:"SERVER_ADDRESS"` // отвечает за адрес запуска HTTP-сервера, например, localhost:8080
+
+	// базовые адреса коротких ссылок на всех доменах, например https://go.brand-a.com,https://brand-b.link;
+	// первый используется по умолчанию. Если не заданы, берётся BASE_URL или флаг -b
 	// (значение: адрес сервера перед коротким URL, например http://localhost:8000/qsd54gFg)
+	Domains []string `envconfig:"DOMAINS"`
+
+	AllowlistFiles       []string      `envconfig:"ALLOWLIST_FILES"`        // файлы с доменами-исключениями из denylist
+	DenylistFiles        []string      `envconfig:"DENYLIST_FILES"`         // файлы с запрещёнными доменами (hosts-файл или список)
+	PolicyReloadInterval time.Duration `envconfig:"POLICY_RELOAD_INTERVAL"` // как часто проверять изменения файлов политики
+
+	// лимиты запросов на клиента в секунду; отрицательное значение отключает ограничение
+	CreateRateLimit   float64  `envconfig:"CREATE_RATE_LIMIT"`
+	CreateRateBurst   int      `envconfig:"CREATE_RATE_BURST"`
+	RedirectRateLimit float64  `envconfig:"REDIRECT_RATE_LIMIT"`
+	RedirectRateBurst int      `envconfig:"REDIRECT_RATE_BURST"`
+	TrustedProxies    []string `envconfig:"TRUSTED_PROXIES"` // адреса и подсети прокси, которым доверяем X-Forwarded-For
+
+	DatabaseDSN string `envconfig:"DATABASE_DSN"` // строка подключения к PostgreSQL; если пусто, аналитика хранится в памяти
+
+	AnalyticsSalt          string        `envconfig:"ANALYTICS_SALT"` // соль для хеширования IP-адресов посетителей
+	AnalyticsBufferSize    int           `envconfig:"ANALYTICS_BUFFER_SIZE"`
+	AnalyticsBatchSize     int           `envconfig:"ANALYTICS_BATCH_SIZE"`
+	AnalyticsFlushInterval time.Duration `envconfig:"ANALYTICS_FLUSH_INTERVAL"`
+
+	CookieSecret string        `envconfig:"COOKIE_SECRET"` // ключ подписи cookie; если пусто, генерируется при старте
+	UnlockTTL    time.Duration `envconfig:"UNLOCK_TTL"`    // сколько действует доступ к ссылке после ввода пароля
+
+	ComingSoonPage string `envconfig:"COMING_SOON_PAGE"` // html-шаблон для ссылок, которые ещё не активны; если пусто, отвечаем 404
+
+	DefaultRedirectType  int           `envconfig:"REDIRECT_TYPE"`           // код ответа для ссылок без своего redirect_type: 301, 302, 307 или 308
+	PermanentCacheMaxAge time.Duration `envconfig:"PERMANENT_CACHE_MAX_AGE"` // сколько браузеры могут кешировать постоянные редиректы; изменения ссылки дойдут до посетителей не сразу
+
+	GeoIPDatabase string `envconfig:"GEOIP_DB"` // путь к базе MaxMind GeoLite2-Country; если пусто, правила по странам не срабатывают
+
+	AdminAPIKey   string `envconfig:"ADMIN_API_KEY"`   // ключ с правами admin для выпуска остальных ключей
+	RequireAPIKey bool   `envconfig:"REQUIRE_API_KEY"` // запретить анонимный доступ к /api и созданию ссылок
 
+	// вход через OpenID Connect; выключен, если OIDCIssuer пуст
+	OIDCIssuer       string        `envconfig:"OIDC_ISSUER"`
+	OIDCClientID     string        `envconfig:"OIDC_CLIENT_ID"`
+	OIDCClientSecret string        `envconfig:"OIDC_CLIENT_SECRET"`
+	OIDCRedirectURL  string        `envconfig:"OIDC_REDIRECT_URL"` // по умолчанию домен по умолчанию + /auth/callback
+	OIDCAudience     string        `envconfig:"OIDC_AUDIENCE"`     // aud bearer-токенов API; по умолчанию OIDCClientID
+	OIDCScopes       []string      `envconfig:"OIDC_SCOPES"`
+	SessionTTL       time.Duration `envconfig:"SESSION_TTL"`
+
+	// роли доступа: none, viewer, creator или admin
+	AnonymousRole string   `envconfig:"ANONYMOUS_ROLE"` // роль посетителей без ключа и сессии
+	UserRole      string   `envconfig:"USER_ROLE"`      // роль вошедших пользователей, не перечисленных в AdminUsers
+	AdminUsers    []string `envconfig:"ADMIN_USERS"`    // id или подтверждённый email пользователей с ролью admin
+
+	// загрузка заголовка, описания и иконки страниц, на которые ведут ссылки
+	MetadataDisabled bool          `envconfig:"METADATA_DISABLED"`  // не обращаться к страницам назначения
+	MetadataTimeout  time.Duration `envconfig:"METADATA_TIMEOUT"`   // сколько ждать одну страницу
+	MetadataMaxBytes int64         `envconfig:"METADATA_MAX_BYTES"` // сколько байт страницы читать
+	MetadataWorkers  int           `envconfig:"METADATA_WORKERS"`   // сколько страниц загружать одновременно
+	MetadataMaxAge   time.Duration `envconfig:"METADATA_MAX_AGE"`   // через сколько загружать метаданные заново
+
+	// проверка, что страницы назначения ещё существуют
+	LinkCheckDisabled    bool          `envconfig:"LINKCHECK_DISABLED"`
+	LinkCheckInterval    time.Duration `envconfig:"LINKCHECK_INTERVAL"`    // как часто проверять каждую ссылку
+	LinkCheckConcurrency int           `envconfig:"LINKCHECK_CONCURRENCY"` // сколько сайтов проверять одновременно
+	LinkCheckHostDelay   time.Duration `envconfig:"LINKCHECK_HOST_DELAY"`  // пауза между запросами к одному сайту
+	LinkCheckTimeout     time.Duration `envconfig:"LINKCHECK_TIMEOUT"`
+	LinkCheckWebhook     string        `envconfig:"LINKCHECK_WEBHOOK_URL"` // куда отправлять POST, когда ссылка сломалась
+
+	// исходящие вебхуки о создании, изменении, удалении ссылок и переходах по ним
+	EventsBufferSize     int           `envconfig:"EVENTS_BUFFER_SIZE"` // сколько событий ждут отправки, прежде чем теряться
+	WebhookTimeout       time.Duration `envconfig:"WEBHOOK_TIMEOUT"`
+	WebhookMaxAttempts   int           `envconfig:"WEBHOOK_MAX_ATTEMPTS"`    // после стольких неудач событие попадает в dead-letter
+	WebhookRetryDelay    time.Duration `envconfig:"WEBHOOK_RETRY_DELAY"`     // пауза после первой неудачи, дальше вдвое больше
+	WebhookMaxRetryDelay time.Duration `envconfig:"WEBHOOK_MAX_RETRY_DELAY"` // но не больше этой
+	WebhookConcurrency   int           `envconfig:"WEBHOOK_CONCURRENCY"`     // сколько запросов отправлять одновременно
+}
+
+// String prints the configuration with secrets masked, so it can be logged.
+func (c *Config) String() string {
+	type plain Config // без метода String, иначе рекурсия
+	masked := plain(*c)
+	for _, secret := range []*string{&masked.CookieSecret, &masked.AdminAPIKey, &masked.OIDCClientSecret, &masked.AnalyticsSalt} {
+		if *secret != "" {
+			*secret = "***"
+		}
+	}
+	return fmt.Sprintf("%+v", masked)
 }
 
 // приоритет:
@@ -37,6 +151,10 @@ func New() (*Config, error) {
 
 	flagAddr := flag.String("a", "", "Net address localhost:port")
 	flagURLAddr := flag.String("b", "", "Result url address http://localhost:port/qsd54gFg")
+	flagAllowlist := flag.String("allowlist", "", "Comma-separated allowlist files")
+	flagDenylist := flag.String("denylist", "", "Comma-separated denylist files")
+	flagTrustedProxies := flag.String("trusted-proxies", "", "Comma-separated trusted proxy CIDRs")
+	flagDatabaseDSN := flag.String("d", "", "Database connection string")
 
 	flag.Parse()
 
@@ -46,15 +164,130 @@ func New() (*Config, error) {
 			cfg.Address = defaultAddress
 		}
 	}
-	if cfg.URLAddress == "" {
-		cfg.URLAddress = *flagURLAddr
-		if cfg.URLAddress == "" {
-			cfg.URLAddress = defaultURLAddress
+	if len(cfg.Domains) == 0 {
+		baseURL := os.Getenv("BASE_URL")
+		if baseURL == "" {
+			baseURL = *flagURLAddr
 		}
+		if baseURL == "" {
+			baseURL = defaultURLAddress
+		}
+		cfg.Domains = []string{baseURL}
+	}
+
+	if len(cfg.AllowlistFiles) == 0 {
+		cfg.AllowlistFiles = splitList(*flagAllowlist)
+	}
+	if len(cfg.DenylistFiles) == 0 {
+		cfg.DenylistFiles = splitList(*flagDenylist)
+	}
+	if cfg.PolicyReloadInterval <= 0 {
+		cfg.PolicyReloadInterval = defaultPolicyReloadInterval
+	}
+	if len(cfg.TrustedProxies) == 0 {
+		cfg.TrustedProxies = splitList(*flagTrustedProxies)
+	}
+	if cfg.CreateRateLimit == 0 {
+		cfg.CreateRateLimit = defaultCreateRateLimit
+	}
+	if cfg.CreateRateBurst == 0 {
+		cfg.CreateRateBurst = defaultCreateRateBurst
+	}
+	if cfg.RedirectRateLimit == 0 {
+		cfg.RedirectRateLimit = defaultRedirectRateLimit
+	}
+	if cfg.RedirectRateBurst == 0 {
+		cfg.RedirectRateBurst = defaultRedirectRateBurst
+	}
+
+	if cfg.DatabaseDSN == "" {
+		cfg.DatabaseDSN = *flagDatabaseDSN
+	}
+	if cfg.AnalyticsBufferSize <= 0 {
+		cfg.AnalyticsBufferSize = defaultAnalyticsBufferSize
+	}
+	if cfg.AnalyticsBatchSize <= 0 {
+		cfg.AnalyticsBatchSize = defaultAnalyticsBatchSize
+	}
+	if cfg.AnalyticsFlushInterval <= 0 {
+		cfg.AnalyticsFlushInterval = defaultAnalyticsFlushPeriod
+	}
+
+	if cfg.UnlockTTL <= 0 {
+		cfg.UnlockTTL = defaultUnlockTTL
+	}
+	if cfg.DefaultRedirectType == 0 {
+		cfg.DefaultRedirectType = defaultRedirectType
+	}
+	if cfg.PermanentCacheMaxAge <= 0 {
+		cfg.PermanentCacheMaxAge = defaultPermanentCacheMaxAge
+	}
+
+	if cfg.OIDCIssuer != "" && cfg.OIDCRedirectURL == "" {
+		cfg.OIDCRedirectURL = strings.TrimSuffix(cfg.BaseURL(), "/") + "/auth/callback"
+	}
+	if cfg.SessionTTL <= 0 {
+		cfg.SessionTTL = defaultSessionTTL
+	}
+
+	if cfg.AnonymousRole == "" {
+		cfg.AnonymousRole = defaultRole
+	}
+	if cfg.UserRole == "" {
+		cfg.UserRole = defaultRole
+	}
+
+	if cfg.MetadataTimeout <= 0 {
+		cfg.MetadataTimeout = defaultMetadataTimeout
+	}
+	if cfg.MetadataMaxBytes <= 0 {
+		cfg.MetadataMaxBytes = defaultMetadataMaxBytes
+	}
+	if cfg.MetadataWorkers <= 0 {
+		cfg.MetadataWorkers = defaultMetadataWorkers
+	}
+	if cfg.MetadataMaxAge <= 0 {
+		cfg.MetadataMaxAge = defaultMetadataMaxAge
+	}
+
+	if cfg.LinkCheckInterval <= 0 {
+		cfg.LinkCheckInterval = defaultLinkCheckInterval
+	}
+	if cfg.LinkCheckConcurrency <= 0 {
+		cfg.LinkCheckConcurrency = defaultLinkCheckConcurrency
+	}
+	if cfg.LinkCheckHostDelay <= 0 {
+		cfg.LinkCheckHostDelay = defaultLinkCheckHostDelay
+	}
+	if cfg.LinkCheckTimeout <= 0 {
+		cfg.LinkCheckTimeout = defaultLinkCheckTimeout
+	}
+
+	if cfg.EventsBufferSize <= 0 {
+		cfg.EventsBufferSize = defaultEventsBufferSize
+	}
+	if cfg.WebhookTimeout <= 0 {
+		cfg.WebhookTimeout = defaultWebhookTimeout
+	}
+	if cfg.WebhookMaxAttempts <= 0 {
+		cfg.WebhookMaxAttempts = defaultWebhookMaxAttempts
+	}
+	if cfg.WebhookRetryDelay <= 0 {
+		cfg.WebhookRetryDelay = defaultWebhookRetryDelay
+	}
+	if cfg.WebhookMaxRetryDelay <= 0 {
+		cfg.WebhookMaxRetryDelay = defaultWebhookMaxRetryDelay
+	}
+	if cfg.WebhookConcurrency <= 0 {
+		cfg.WebhookConcurrency = defaultWebhookConcurrency
 	}
 
 	mustBeCorrectAddressFlag(cfg.Address)
-	mustBeCorrectURL(cfg.URLAddress)
+	mustBeCorrectDomains(cfg.Domains)
+	mustBeCorrectRedirectType(cfg.DefaultRedirectType)
+	mustBeCorrectRole(cfg.AnonymousRole)
+	mustBeCorrectRole(cfg.UserRole)
+	mustBeCorrectRetryDelays(cfg.WebhookRetryDelay, cfg.WebhookMaxRetryDelay)
 
 	return &cfg, nil
 }
@@ -71,9 +304,55 @@ func mustBeCorrectAddressFlag(addr string) {
 	}
 }
 
-func mustBeCorrectURL(addr string) {
-	_, err := url.ParseRequestURI(addr)
-	if err != nil {
-		log.Fatal(fmt.Errorf("invalid url: '%s'", addr))
+func mustBeCorrectDomains(bases []string) {
+	if _, err := domain.NewSet(bases); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// BaseURL returns the base URL of the default domain.
+func (c *Config) BaseURL() string {
+	if len(c.Domains) == 0 {
+		return ""
+	}
+	return c.Domains[0]
+}
+
+// IsRedirectType reports whether code may be used to redirect short links.
+func IsRedirectType(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+func mustBeCorrectRedirectType(code int) {
+	if !IsRedirectType(code) {
+		log.Fatal(fmt.Errorf("invalid redirect type: %d", code))
+	}
+}
+
+func mustBeCorrectRole(role string) {
+	if _, err := authz.ParseRole(role); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func mustBeCorrectRetryDelays(delay, maxDelay time.Duration) {
+	if delay > maxDelay {
+		log.Fatal(fmt.Errorf("webhook retry delay %s is longer than the maximum %s", delay, maxDelay))
+	}
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
 }
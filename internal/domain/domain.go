@@ -0,0 +1,131 @@
+// Package domain knows the domains short links are served on.
+//
+// Every domain is a namespace of its own, so the same id may lead to
+// different destinations on two domains. Links on the default domain,
+// the first configured one, live in the unnamed namespace; that keeps links
+// and statistics created before several domains were configured valid.
+package domain
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Domain is one configured domain.
+type Domain struct {
+	Base string // base URL of short links without a trailing slash, e.g. https://go.brand-a.com
+	Host string // host with a port, if any, lowercased
+	Name string // namespace of links, empty for the default domain
+}
+
+// ShortURL returns the short link with the given id on the domain.
+func (d Domain) ShortURL(id string) string {
+	return d.Base + "/" + id
+}
+
+type InvalidDomainError struct {
+	base   string
+	reason string
+}
+
+func (e *InvalidDomainError) Error() string {
+	return fmt.Sprintf("invalid domain %q: %s", e.base, e.reason)
+}
+
+// Set is a list of domains, the first one is the default.
+type Set struct {
+	domains []Domain
+	byHost  map[string]int
+}
+
+// NewSet parses base URLs like https://go.brand-a.com.
+func NewSet(bases []string) (*Set, error) {
+	s := &Set{byHost: make(map[string]int, len(bases))}
+	for _, base := range bases {
+		u, err := url.ParseRequestURI(base)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, &InvalidDomainError{base: base, reason: "not an absolute http(s) URL"}
+		}
+		host := normalizeHost(u.Host)
+		if _, dup := s.byHost[host]; dup {
+			return nil, &InvalidDomainError{base: base, reason: "configured twice"}
+		}
+		d := Domain{Base: strings.TrimSuffix(base, "/"), Host: host}
+		if len(s.domains) > 0 {
+			d.Name = host
+		}
+		s.byHost[host] = len(s.domains)
+		s.domains = append(s.domains, d)
+	}
+	return s, nil
+}
+
+// Default returns the first domain; zero Domain for an empty set.
+func (s *Set) Default() Domain {
+	if s == nil || len(s.domains) == 0 {
+		return Domain{}
+	}
+	return s.domains[0]
+}
+
+// All returns the domains, the default one first.
+func (s *Set) All() []Domain {
+	if s == nil {
+		return nil
+	}
+	return append([]Domain(nil), s.domains...)
+}
+
+// Lookup finds a domain by its namespace, host or base URL. An empty name
+// is the default domain.
+func (s *Set) Lookup(name string) (Domain, bool) {
+	if name == "" {
+		return s.Default(), true
+	}
+	if u, err := url.Parse(name); err == nil && u.Host != "" {
+		name = u.Host
+	}
+	return s.byHostName(normalizeHost(name))
+}
+
+// ForHost returns the domain a request with the given Host header was
+// sent to. Unknown hosts, e.g. internal health checks, get the default domain.
+func (s *Set) ForHost(host string) Domain {
+	host = normalizeHost(host)
+	if d, ok := s.byHostName(host); ok {
+		return d
+	}
+	// за прокси порт может отличаться от настроенного
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		for _, d := range s.All() {
+			if hostname(d.Host) == h {
+				return d
+			}
+		}
+	}
+	return s.Default()
+}
+
+func (s *Set) byHostName(host string) (Domain, bool) {
+	if s == nil {
+		return Domain{}, false
+	}
+	i, ok := s.byHost[host]
+	if !ok {
+		return Domain{}, false
+	}
+	return s.domains[i], true
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return host
+}
@@ -0,0 +1,49 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSet(t *testing.T) {
+	s, err := NewSet([]string{"https://go.brand-a.com/", "https://Brand-B.link", "http://localhost:8080"})
+	require.NoError(t, err)
+
+	require.Equal(t, Domain{Base: "https://go.brand-a.com", Host: "go.brand-a.com"}, s.Default())
+	require.Equal(t, "https://go.brand-a.com/abc", s.Default().ShortURL("abc"))
+
+	d := s.ForHost("BRAND-B.LINK.")
+	require.Equal(t, "brand-b.link", d.Name)
+	require.Equal(t, "https://Brand-B.link", d.Base)
+
+	// другой порт за прокси и незнакомые хосты
+	require.Equal(t, "localhost:8080", s.ForHost("localhost:9000").Name)
+	require.Equal(t, "", s.ForHost("10.0.0.1:8080").Name)
+
+	d, ok := s.Lookup("https://brand-b.link")
+	require.True(t, ok)
+	require.Equal(t, "brand-b.link", d.Name)
+	d, ok = s.Lookup("")
+	require.True(t, ok)
+	require.Equal(t, "go.brand-a.com", d.Host)
+	_, ok = s.Lookup("brand-c.link")
+	require.False(t, ok)
+}
+
+func TestNewSetInvalid(t *testing.T) {
+	for _, bases := range [][]string{
+		{"go.brand-a.com"},
+		{"ftp://go.brand-a.com"},
+		{"https://a.com", "http://A.com"},
+	} {
+		_, err := NewSet(bases)
+		require.ErrorAs(t, err, new(*InvalidDomainError), bases)
+	}
+}
+
+func TestEmptySet(t *testing.T) {
+	var s *Set
+	require.Equal(t, Domain{}, s.Default())
+	require.Equal(t, Domain{}, s.ForHost("example.com"))
+}
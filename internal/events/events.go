@@ -0,0 +1,100 @@
+// Package events is an in-process bus for what happens to links. The API
+// handlers publish events, other parts of the service, such as outgoing
+// webhooks, subscribe to them.
+//
+// Publishing never blocks: a subscriber that does not keep up loses events,
+// the redirect of a visitor must not wait for a slow webhook.
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/logger"
+	"go.uber.org/zap"
+)
+
+// Event types.
+const (
+	LinkCreated = "link.created"
+	LinkUpdated = "link.updated"
+	LinkDeleted = "link.deleted"
+	LinkClicked = "link.clicked"
+	LinkBroken  = "link.broken"
+)
+
+// Types lists all event types.
+var Types = []string{LinkCreated, LinkUpdated, LinkDeleted, LinkClicked, LinkBroken}
+
+// Event is something that happened to a link.
+type Event struct {
+	ID   string // filled in by Publish
+	Type string
+	Time time.Time // filled in by Publish if zero
+
+	// владелец и пространство ссылки определяют, кто получит событие
+	Owner     string
+	Workspace string
+
+	Actor string // who caused the event; empty for visitors and the service itself
+	Data  any    // serialized to JSON as is
+}
+
+// Bus delivers published events to every subscriber.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []chan Event
+	dropped     atomic.Uint64
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe returns a channel receiving every event published from now on.
+// Up to buffer events wait in the channel; when it is full, new events are
+// dropped for this subscriber.
+func (b *Bus) Subscribe(buffer int) <-chan Event {
+	ch := make(chan Event, buffer)
+	b.mu.Lock()
+	b.subscribers = append(b.subscribers, ch)
+	b.mu.Unlock()
+	return ch
+}
+
+// Publish sends e to the subscribers without blocking.
+func (b *Bus) Publish(e Event) {
+	if e.ID == "" {
+		e.ID = newID()
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			b.dropped.Add(1)
+			logger.Logger.Warn("event dropped, subscriber is too slow",
+				zap.String("type", e.Type), zap.String("event", e.ID))
+		}
+	}
+}
+
+// Dropped returns the number of events lost because a subscriber was full.
+func (b *Bus) Dropped() uint64 {
+	return b.dropped.Load()
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	// crypto/rand не возвращает ошибок на поддерживаемых платформах
+	_, _ = rand.Read(b)
+	return "evt_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,29 @@
+package events
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+	first := bus.Subscribe(1)
+	second := bus.Subscribe(2)
+
+	bus.Publish(Event{Type: LinkCreated, Owner: "alice"})
+	e := <-first
+	require.Equal(t, LinkCreated, e.Type)
+	require.True(t, strings.HasPrefix(e.ID, "evt_"))
+	require.False(t, e.Time.IsZero())
+	require.Equal(t, e, <-second)
+
+	// медленный подписчик теряет события, но не задерживает остальных
+	bus.Publish(Event{Type: LinkClicked})
+	bus.Publish(Event{Type: LinkDeleted})
+	require.Equal(t, uint64(1), bus.Dropped())
+	require.Equal(t, LinkClicked, (<-first).Type)
+	require.Equal(t, LinkClicked, (<-second).Type)
+	require.Equal(t, LinkDeleted, (<-second).Type)
+}
@@ -0,0 +1,189 @@
+// Package linkcheck finds short links whose destination no longer works.
+//
+// The Checker requests every destination once per interval. Requests to one
+// host are made one after another with a pause between them, so a site with
+// many links is not hammered; different hosts are checked in parallel.
+package linkcheck
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/logger"
+	"github.com/adettelle/go-url-shortener/internal/safehttp"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	userAgent = "go-url-shortener link checker"
+	// batchSize is how many links are checked in one round at most.
+	batchSize = 1000
+)
+
+// Store is the part of the link storage the Checker works with.
+type Store interface {
+	DueChecks(before time.Time, limit int) ([]*storage.Link, error)
+	AddCheck(name, rawURL string, check storage.CheckResult) (*storage.Link, error)
+}
+
+// Checker checks link destinations in the background, see Run.
+type Checker struct {
+	client      *http.Client
+	store       Store
+	interval    time.Duration
+	concurrency int
+	hostDelay   time.Duration
+	onBroken    func(link *storage.Link)
+}
+
+// NewChecker creates a Checker that checks every destination once per
+// interval, talks to at most concurrency hosts at once and waits hostDelay
+// between two requests to the same host. onBroken, if not nil, is called
+// when a link becomes broken.
+func NewChecker(client *http.Client, store Store, interval time.Duration, concurrency int,
+	hostDelay time.Duration, onBroken func(link *storage.Link)) *Checker {
+	return &Checker{
+		client:      client,
+		store:       store,
+		interval:    interval,
+		concurrency: concurrency,
+		hostDelay:   hostDelay,
+		onBroken:    onBroken,
+	}
+}
+
+// Run checks the due links every tenth of the interval until ctx is cancelled.
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval / 10)
+	defer ticker.Stop()
+
+	for {
+		c.checkDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkDue checks the links not checked within the interval and returns
+// when all of them are done.
+func (c *Checker) checkDue(ctx context.Context) {
+	links, err := c.store.DueChecks(time.Now().Add(-c.interval), batchSize)
+	if err != nil {
+		logger.Logger.Error("error in listing links to check", zap.Error(err))
+		return
+	}
+
+	byHost := make(map[string][]*storage.Link)
+	for _, link := range links {
+		host := ""
+		if u, err := url.Parse(link.URL); err == nil {
+			host = strings.ToLower(u.Hostname())
+		}
+		byHost[host] = append(byHost[host], link)
+	}
+
+	var wg sync.WaitGroup
+	hosts := make(chan struct{}, c.concurrency)
+	for _, hostLinks := range byHost {
+		hosts <- struct{}{}
+		wg.Add(1)
+		go func(hostLinks []*storage.Link) {
+			defer func() {
+				<-hosts
+				wg.Done()
+			}()
+			c.checkHost(ctx, hostLinks)
+		}(hostLinks)
+	}
+	wg.Wait()
+}
+
+// checkHost checks links of one host one by one.
+func (c *Checker) checkHost(ctx context.Context, links []*storage.Link) {
+	for i, link := range links {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(c.hostDelay):
+			}
+		}
+		check := c.check(ctx, link.URL)
+		if ctx.Err() != nil {
+			// остановка сервера не значит, что ссылка сломалась
+			return
+		}
+
+		updated, err := c.store.AddCheck(link.Key(), link.URL, check)
+		if err != nil {
+			// ссылку могли удалить во время проверки
+			logger.Logger.Info("error in saving link check", zap.String("link", link.Key()), zap.Error(err))
+			continue
+		}
+		if link.BrokenSince.IsZero() && !updated.BrokenSince.IsZero() {
+			logger.Logger.Info("link is broken", zap.String("link", updated.Key()),
+				zap.Int("status", check.Status), zap.String("error", check.Error))
+			if c.onBroken != nil {
+				c.onBroken(updated)
+			}
+		}
+	}
+}
+
+// check requests rawURL. HEAD is tried first; since some servers do not
+// support it, a failed HEAD is repeated as GET.
+func (c *Checker) check(ctx context.Context, rawURL string) storage.CheckResult {
+	check := storage.CheckResult{Time: time.Now()}
+	if err := safehttp.CheckURL(rawURL); err != nil {
+		check.Error = err.Error()
+		check.Broken = true
+		return check
+	}
+
+	status, err := c.request(ctx, http.MethodHead, rawURL)
+	if err != nil || status >= 400 {
+		status, err = c.request(ctx, http.MethodGet, rawURL)
+	}
+	if err != nil {
+		check.Error = err.Error()
+		// внутренние адреса мы не проверяем, но это не значит, что они не работают
+		var blocked *safehttp.BlockedAddressError
+		check.Broken = !errors.As(err, &blocked)
+		return check
+	}
+	check.Status = status
+	check.Broken = isBrokenStatus(status)
+	return check
+}
+
+func (c *Checker) request(ctx context.Context, method, rawURL string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// немного дочитываем, чтобы соединение можно было переиспользовать
+	_, _ = io.CopyN(io.Discard, resp.Body, 4096)
+	return resp.StatusCode, nil
+}
+
+// isBrokenStatus reports whether status means the destination is gone.
+// Pages behind a login (401, 403) or a rate limit (429) still exist.
+func isBrokenStatus(status int) bool {
+	return status == http.StatusNotFound || status == http.StatusGone || status >= 500
+}
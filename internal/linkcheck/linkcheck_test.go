@@ -0,0 +1,171 @@
+package linkcheck
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/safehttp"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+	mux.HandleFunc("/no-head", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	checker := NewChecker(server.Client(), storage.New(), time.Hour, 1, 0, nil)
+	for path, want := range map[string]storage.CheckResult{
+		"/ok":      {Status: http.StatusOK},
+		"/gone":    {Status: http.StatusGone, Broken: true},
+		"/no-head": {Status: http.StatusOK},
+		"/login":   {Status: http.StatusForbidden},
+		"/error":   {Status: http.StatusBadGateway, Broken: true},
+	} {
+		check := checker.check(context.Background(), server.URL+path)
+		require.False(t, check.Time.IsZero(), path)
+		check.Time = time.Time{}
+		require.Equal(t, want, check, path)
+	}
+
+	check := checker.check(context.Background(), "mailto:team@example.com")
+	require.True(t, check.Broken)
+
+	// внутренний адрес не проверить, но и сломанным его не считаем
+	checker = NewChecker(safehttp.NewClient(time.Second, nil), storage.New(), time.Hour, 1, 0, nil)
+	check = checker.check(context.Background(), server.URL+"/gone")
+	require.False(t, check.Broken)
+	require.Contains(t, check.Error, "not public")
+}
+
+func TestCheckDue(t *testing.T) {
+	var gone atomic.Bool
+	gone.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/page" && gone.Load() {
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	store := storage.New()
+	page, err := store.AddLink(storage.Link{URL: server.URL + "/page"})
+	require.NoError(t, err)
+	_, err = store.AddLink(storage.Link{URL: server.URL + "/other"})
+	require.NoError(t, err)
+
+	var broken []string
+	checker := NewChecker(server.Client(), store, time.Nanosecond, 4, 0, func(link *storage.Link) {
+		broken = append(broken, link.ID)
+	})
+
+	// одна неудачная проверка ещё не делает ссылку сломанной
+	checker.checkDue(context.Background())
+	require.Empty(t, broken)
+
+	checker.checkDue(context.Background())
+	require.Equal(t, []string{page}, broken)
+	checker.checkDue(context.Background())
+	require.Equal(t, []string{page}, broken, "notified once")
+
+	link, err := store.GetLink(page)
+	require.NoError(t, err)
+	require.Len(t, link.Checks, 3)
+	require.Equal(t, link.Checks[0].Time, link.BrokenSince)
+
+	gone.Store(false)
+	checker.checkDue(context.Background())
+	link, err = store.GetLink(page)
+	require.NoError(t, err)
+	require.True(t, link.BrokenSince.IsZero())
+}
+
+func TestCheckDuePoliteness(t *testing.T) {
+	const delay = 30 * time.Millisecond
+	var mu sync.Mutex
+	var inFlight, maxInFlight int
+	var last time.Time
+	var gaps []time.Duration
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		if !last.IsZero() {
+			gaps = append(gaps, time.Since(last))
+		}
+		last = time.Now()
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	store := storage.New()
+	for _, path := range []string{"/1", "/2", "/3"} {
+		_, err := store.AddLink(storage.Link{URL: server.URL + path})
+		require.NoError(t, err)
+	}
+	NewChecker(server.Client(), store, time.Hour, 4, delay, nil).checkDue(context.Background())
+
+	require.Equal(t, 1, maxInFlight)
+	require.Len(t, gaps, 2)
+	for _, gap := range gaps {
+		require.GreaterOrEqual(t, gap, delay)
+	}
+
+	// проверенные ссылки до следующего интервала не трогаем
+	due, err := store.DueChecks(time.Now().Add(-time.Hour), 0)
+	require.NoError(t, err)
+	require.Empty(t, due)
+}
+
+func TestWebhook(t *testing.T) {
+	received := make(chan brokenPayload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var payload brokenPayload
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received <- payload
+	}))
+	defer server.Close()
+
+	since := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	link := &storage.Link{ID: "deck", Domain: "brand-b.link", URL: "https://example.com/deck", CreatedBy: "alice",
+		BrokenSince: since, Checks: []storage.CheckResult{{Time: since, Status: http.StatusNotFound, Broken: true}}}
+	require.NoError(t, NewWebhook(server.URL, time.Second).send(context.Background(), link))
+	want := BrokenLink{ID: "deck", Domain: "brand-b.link", URL: "https://example.com/deck", Owner: "alice",
+		BrokenSince: since, Status: http.StatusNotFound}
+	require.Equal(t, brokenPayload{Event: EventLinkBroken, BrokenLink: want}, <-received)
+	require.Equal(t, want, BrokenEvent(link).Data)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	err := NewWebhook(failing.URL, time.Second).send(context.Background(), link)
+	require.Equal(t, &WebhookStatusError{code: http.StatusInternalServerError}, err)
+}
@@ -0,0 +1,112 @@
+package linkcheck
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/events"
+	"github.com/adettelle/go-url-shortener/internal/logger"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"go.uber.org/zap"
+)
+
+// EventLinkBroken is the event of the webhook payload.
+const EventLinkBroken = events.LinkBroken
+
+// BrokenLink describes a link that has just become broken.
+type BrokenLink struct {
+	ID          string    `json:"id"`
+	Domain      string    `json:"domain,omitempty"`
+	URL         string    `json:"url"`
+	Owner       string    `json:"owner,omitempty"`
+	Workspace   string    `json:"workspace,omitempty"`
+	BrokenSince time.Time `json:"broken_since"`
+	Status      int       `json:"status,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+type brokenPayload struct {
+	Event string `json:"event"`
+	BrokenLink
+}
+
+func newBrokenLink(link *storage.Link) BrokenLink {
+	b := BrokenLink{
+		ID:          link.ID,
+		Domain:      link.Domain,
+		URL:         link.URL,
+		Owner:       link.CreatedBy,
+		Workspace:   link.Workspace,
+		BrokenSince: link.BrokenSince,
+	}
+	if n := len(link.Checks); n > 0 {
+		b.Status = link.Checks[n-1].Status
+		b.Error = link.Checks[n-1].Error
+	}
+	return b
+}
+
+// BrokenEvent is the event published to the event bus when link becomes
+// broken, so that webhook subscribers learn about it too.
+func BrokenEvent(link *storage.Link) events.Event {
+	return events.Event{
+		Type:      events.LinkBroken,
+		Owner:     link.CreatedBy,
+		Workspace: link.Workspace,
+		Data:      newBrokenLink(link),
+	}
+}
+
+type WebhookStatusError struct {
+	code int
+}
+
+func (e *WebhookStatusError) Error() string {
+	return fmt.Sprintf("webhook answered %d", e.code)
+}
+
+// Webhook posts a JSON notification to an URL when a link becomes broken.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook creates a Webhook for the configured url. The url is set by
+// the operator, so unlike destinations it may point to an internal service.
+func NewWebhook(url string, timeout time.Duration) *Webhook {
+	return &Webhook{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+// LinkBroken sends the notification; it is meant to be passed to NewChecker.
+// Errors are logged, the link stays broken either way.
+func (wh *Webhook) LinkBroken(link *storage.Link) {
+	if err := wh.send(context.Background(), link); err != nil {
+		logger.Logger.Error("error in sending broken link webhook", zap.String("link", link.Key()), zap.Error(err))
+	}
+}
+
+func (wh *Webhook) send(ctx context.Context, link *storage.Link) error {
+	body, err := json.Marshal(brokenPayload{Event: EventLinkBroken, BrokenLink: newBrokenLink(link)})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := wh.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &WebhookStatusError{code: resp.StatusCode}
+	}
+	return nil
+}
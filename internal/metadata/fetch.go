@@ -0,0 +1,88 @@
+// Package metadata fetches the title, description, favicon and Open Graph
+// image of link destinations, so that listings show more than bare URLs.
+// Destinations are supplied by users, so they are fetched with a safehttp
+// client that does not reach internal addresses.
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/netip"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/safehttp"
+	"golang.org/x/net/html/charset"
+)
+
+const userAgent = "go-url-shortener metadata fetcher"
+
+// Page is the metadata of an HTML page.
+type Page struct {
+	Title       string
+	Description string
+	Favicon     string // absolute URL
+	Image       string // absolute URL of the Open Graph image
+}
+
+type StatusError struct {
+	code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d", e.code)
+}
+
+// Fetcher downloads pages and extracts their metadata.
+type Fetcher struct {
+	client   *http.Client
+	maxBytes int64
+}
+
+// NewFetcher creates a Fetcher that gives up on a page after timeout and
+// reads at most maxBytes of it.
+func NewFetcher(timeout time.Duration, maxBytes int64) *Fetcher {
+	return newFetcher(timeout, maxBytes, nil)
+}
+
+func newFetcher(timeout time.Duration, maxBytes int64, allow func(netip.AddrPort) bool) *Fetcher {
+	return &Fetcher{client: safehttp.NewClient(timeout, allow), maxBytes: maxBytes}
+}
+
+// Fetch downloads rawURL and returns the metadata of the page. Responses
+// other than HTML have no metadata, Fetch returns an empty Page for them.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (Page, error) {
+	if err := safehttp.CheckURL(rawURL); err != nil {
+		return Page{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return Page{}, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.1")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return Page{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Page{}, &StatusError{code: resp.StatusCode}
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return Page{}, nil
+	}
+
+	body, err := charset.NewReader(io.LimitReader(resp.Body, f.maxBytes), contentType)
+	if err != nil {
+		return Page{}, err
+	}
+	// после редиректов относительные ссылки считаются от последнего адреса
+	return parse(body, resp.Request.URL)
+}
@@ -0,0 +1,120 @@
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/safehttp"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// allowAll lets tests reach httptest servers on the loopback interface.
+func allowAll(netip.AddrPort) bool { return true }
+
+func TestFetch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/docs/page", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/docs/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<!DOCTYPE html><html><head>
+			<title>
+				Q3 deck &amp; notes
+			</title>
+			<meta name="description" content="Quarterly results">
+			<meta property="og:title" content="Ignored, there is a title">
+			<meta property="og:image" content="img/cover.png">
+			<link rel="Shortcut Icon" href="/static/icon.png">
+			</head><body><title>not this one</title></body></html>`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	page, err := newFetcher(time.Second, 1<<20, allowAll).Fetch(context.Background(), server.URL+"/old")
+	require.NoError(t, err)
+	require.Equal(t, Page{
+		Title:       "Q3 deck & notes",
+		Description: "Quarterly results",
+		Favicon:     server.URL + "/static/icon.png",
+		Image:       server.URL + "/docs/img/cover.png",
+	}, page)
+}
+
+func TestFetchFallbacks(t *testing.T) {
+	title, err := charmap.Windows1251.NewEncoder().String("Отчёт")
+	require.NoError(t, err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=windows-1251")
+		w.Write([]byte(`<html><head>
+			<meta property="og:title" content="` + title + `">
+			<meta property="og:description" content="From Open Graph">
+			<meta name="twitter:image" content="https://cdn.example.com/card.png">
+			<meta property="og:image" content="javascript:alert(1)">
+			</head></html>`))
+	}))
+	defer server.Close()
+
+	page, err := newFetcher(time.Second, 1<<20, allowAll).Fetch(context.Background(), server.URL)
+	require.NoError(t, err)
+	require.Equal(t, Page{
+		Title:       "Отчёт",
+		Description: "From Open Graph",
+		Favicon:     server.URL + "/favicon.ico",
+	}, page)
+}
+
+func TestFetchLimits(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte(`<title>slow</title>`))
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<head><meta name="description" content="` + strings.Repeat("x", 2000) + `"><title>late</title></head>`))
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	mux.HandleFunc("/pdf", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Write([]byte(`<title>not html</title>`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	fetcher := newFetcher(50*time.Millisecond, 1024, allowAll)
+
+	_, err := fetcher.Fetch(context.Background(), server.URL+"/slow")
+	require.Error(t, err)
+
+	// читается только первый килобайт страницы
+	page, err := fetcher.Fetch(context.Background(), server.URL+"/large")
+	require.NoError(t, err)
+	require.Empty(t, page.Title)
+
+	_, err = fetcher.Fetch(context.Background(), server.URL+"/missing")
+	require.Equal(t, &StatusError{code: http.StatusNotFound}, err)
+
+	page, err = fetcher.Fetch(context.Background(), server.URL+"/pdf")
+	require.NoError(t, err)
+	require.Equal(t, Page{}, page)
+
+	_, err = fetcher.Fetch(context.Background(), "file:///etc/passwd")
+	require.ErrorAs(t, err, new(*safehttp.UnsupportedURLError))
+}
+
+func TestFetchBlocksPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<title>secret</title>`))
+	}))
+	defer server.Close()
+
+	_, err := NewFetcher(time.Second, 1<<20).Fetch(context.Background(), server.URL)
+	require.ErrorAs(t, err, new(*safehttp.BlockedAddressError))
+}
@@ -0,0 +1,163 @@
+package metadata
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	maxTitleLength       = 300
+	maxDescriptionLength = 1000
+	maxURLLength         = 2048
+)
+
+// parse extracts metadata from the head of an HTML document. Relative URLs
+// are resolved against base. The body of the document is not read.
+func parse(r io.Reader, base *url.URL) (Page, error) {
+	var page Page
+	var title strings.Builder
+	var ogTitle, ogDescription, twitterImage string
+	var inTitle bool
+
+	z := html.NewTokenizer(r)
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if err := z.Err(); !errors.Is(err, io.EOF) {
+				return Page{}, err
+			}
+			return page.finish(title.String(), ogTitle, ogDescription, twitterImage, base), nil
+
+		case html.TextToken:
+			if inTitle {
+				title.Write(z.Text())
+			}
+
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Title:
+				inTitle = false
+			case atom.Head:
+				return page.finish(title.String(), ogTitle, ogDescription, twitterImage, base), nil
+			}
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Title:
+				// у страницы может быть несколько title, берём первый
+				inTitle = tt == html.StartTagToken && title.Len() == 0
+			case atom.Body:
+				return page.finish(title.String(), ogTitle, ogDescription, twitterImage, base), nil
+			case atom.Meta:
+				if !hasAttr {
+					continue
+				}
+				attrs := attributes(z)
+				key := strings.ToLower(attrs["property"])
+				if key == "" {
+					key = strings.ToLower(attrs["name"])
+				}
+				content := attrs["content"]
+				switch key {
+				case "description":
+					setOnce(&page.Description, content)
+				case "og:title":
+					setOnce(&ogTitle, content)
+				case "og:description":
+					setOnce(&ogDescription, content)
+				case "og:image", "og:image:url":
+					setOnce(&page.Image, content)
+				case "twitter:image":
+					setOnce(&twitterImage, content)
+				}
+			case atom.Link:
+				if !hasAttr {
+					continue
+				}
+				attrs := attributes(z)
+				for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+					if rel == "icon" {
+						setOnce(&page.Favicon, attrs["href"])
+					}
+				}
+			}
+		}
+	}
+}
+
+func attributes(z *html.Tokenizer) map[string]string {
+	attrs := make(map[string]string)
+	for {
+		key, val, more := z.TagAttr()
+		attrs[string(key)] = string(val)
+		if !more {
+			return attrs
+		}
+	}
+}
+
+// setOnce keeps the first non-empty value, pages sometimes repeat tags.
+func setOnce(dst *string, value string) {
+	if *dst == "" {
+		*dst = value
+	}
+}
+
+// finish picks the fallbacks and cleans the values up.
+func (p Page) finish(title, ogTitle, ogDescription, twitterImage string, base *url.URL) Page {
+	if title = clean(title); title == "" {
+		title = clean(ogTitle)
+	}
+	p.Title = truncate(title, maxTitleLength)
+	if p.Description = clean(p.Description); p.Description == "" {
+		p.Description = clean(ogDescription)
+	}
+	p.Description = truncate(p.Description, maxDescriptionLength)
+
+	if p.Image == "" {
+		p.Image = twitterImage
+	}
+	p.Image = resolve(base, p.Image)
+	if p.Favicon == "" {
+		p.Favicon = "/favicon.ico"
+	}
+	p.Favicon = resolve(base, p.Favicon)
+	return p
+}
+
+// clean collapses the whitespace, titles are often split over several lines.
+func clean(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// resolve returns the absolute http(s) URL of ref, or "" if there is none.
+func resolve(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	if s := u.String(); len(s) <= maxURLLength {
+		return s
+	}
+	return ""
+}
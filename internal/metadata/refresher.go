@@ -0,0 +1,147 @@
+package metadata
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/logger"
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"go.uber.org/zap"
+)
+
+// queueSize is how many links may wait for their metadata.
+const queueSize = 1000
+
+// Store is the part of the link storage the Refresher works with.
+type Store interface {
+	SetPageMeta(name, rawURL string, meta storage.PageMeta) error
+	StalePages(before time.Time, limit int) ([]*storage.Link, error)
+}
+
+type job struct {
+	name   string
+	rawURL string
+}
+
+// Refresher fetches the metadata of new links from a few background
+// goroutines started with Run and refetches it when it gets old.
+type Refresher struct {
+	fetcher *Fetcher
+	store   Store
+	workers int
+	maxAge  time.Duration
+
+	jobs chan job
+
+	mu      sync.Mutex
+	pending map[string]bool // ссылки в очереди, чтобы не качать одну страницу дважды
+}
+
+// NewRefresher creates a Refresher that fetches up to workers pages at once
+// and refreshes metadata older than maxAge.
+func NewRefresher(fetcher *Fetcher, store Store, workers int, maxAge time.Duration) *Refresher {
+	return &Refresher{
+		fetcher: fetcher,
+		store:   store,
+		workers: workers,
+		maxAge:  maxAge,
+		jobs:    make(chan job, queueSize),
+		pending: make(map[string]bool),
+	}
+}
+
+// Enqueue schedules fetching the metadata of rawURL for the link stored
+// under name. It does not block: when the queue is full Enqueue returns
+// false and the link is picked up by a later refresh.
+func (r *Refresher) Enqueue(name, rawURL string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pending[name] {
+		return true
+	}
+	select {
+	case r.jobs <- job{name: name, rawURL: rawURL}:
+		r.pending[name] = true
+		return true
+	default:
+		return false
+	}
+}
+
+// Run fetches queued pages until ctx is cancelled. Every tenth of maxAge
+// it also queues the links whose metadata is missing or older than maxAge,
+// which covers links created while the queue was full and failed fetches.
+func (r *Refresher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < r.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.work(ctx)
+		}()
+	}
+
+	r.refreshStale()
+	ticker := time.NewTicker(r.maxAge / 10)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-ticker.C:
+			r.refreshStale()
+		}
+	}
+}
+
+func (r *Refresher) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-r.jobs:
+			r.fetch(ctx, j)
+			r.mu.Lock()
+			delete(r.pending, j.name)
+			r.mu.Unlock()
+		}
+	}
+}
+
+func (r *Refresher) fetch(ctx context.Context, j job) {
+	page, err := r.fetcher.Fetch(ctx, j.rawURL)
+	meta := storage.PageMeta{
+		Title:       page.Title,
+		Description: page.Description,
+		Favicon:     page.Favicon,
+		Image:       page.Image,
+		FetchedAt:   time.Now(),
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		logger.Logger.Info("error in fetching page metadata", zap.String("link", j.name), zap.Error(err))
+		meta.Error = err.Error()
+	}
+	if err := r.store.SetPageMeta(j.name, j.rawURL, meta); err != nil {
+		// ссылку могли удалить, пока страница загружалась
+		logger.Logger.Info("error in saving page metadata", zap.String("link", j.name), zap.Error(err))
+	}
+}
+
+func (r *Refresher) refreshStale() {
+	links, err := r.store.StalePages(time.Now().Add(-r.maxAge), queueSize)
+	if err != nil {
+		logger.Logger.Error("error in listing stale page metadata", zap.Error(err))
+		return
+	}
+	for _, link := range links {
+		if !r.Enqueue(link.Key(), link.URL) {
+			return
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefresher(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Path == "/gone" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<title>Page ` + r.URL.Path + `</title>`))
+	}))
+	defer server.Close()
+
+	store := storage.New()
+	refresher := NewRefresher(newFetcher(time.Second, 1<<20, allowAll), store, 2, time.Hour)
+
+	// ссылка, созданная до запуска, загружается при старте
+	old, err := store.AddLink(storage.Link{URL: server.URL + "/old"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go refresher.Run(ctx)
+
+	gone, err := store.AddLink(storage.Link{URL: server.URL + "/gone"})
+	require.NoError(t, err)
+	require.True(t, refresher.Enqueue(gone, server.URL+"/gone"))
+
+	require.Eventually(t, func() bool {
+		link, err := store.GetLink(old)
+		return err == nil && link.Page.Title == "Page /old"
+	}, time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		link, err := store.GetLink(gone)
+		return err == nil && link.Page.Error != ""
+	}, time.Second, 10*time.Millisecond)
+
+	link, err := store.GetLink(gone)
+	require.NoError(t, err)
+	require.Equal(t, "unexpected status 404", link.Page.Error)
+	require.False(t, link.Page.FetchedAt.IsZero())
+
+	// свежие метаданные повторно не загружаются
+	before := requests.Load()
+	refresher.refreshStale()
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, before, requests.Load())
+}
@@ -7,6 +7,7 @@ package mocks
 import (
 	reflect "reflect"
 
+	storage "github.com/adettelle/go-url-shortener/internal/storage"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -48,6 +49,35 @@ func (mr *MockStoragerMockRecorder) AddAddress(arg0 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAddress", reflect.TypeOf((*MockStorager)(nil).AddAddress), arg0)
 }
 
+// AddLink mocks base method.
+func (m *MockStorager) AddLink(arg0 storage.Link) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddLink", arg0)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddLink indicates an expected call of AddLink.
+func (mr *MockStoragerMockRecorder) AddLink(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddLink", reflect.TypeOf((*MockStorager)(nil).AddLink), arg0)
+}
+
+// DeleteAddress mocks base method.
+func (m *MockStorager) DeleteAddress(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteAddress", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteAddress indicates an expected call of DeleteAddress.
+func (mr *MockStoragerMockRecorder) DeleteAddress(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAddress", reflect.TypeOf((*MockStorager)(nil).DeleteAddress), arg0)
+}
+
 // GetAddress mocks base method.
 func (m *MockStorager) GetAddress(arg0 string) (string, error) {
 	m.ctrl.T.Helper()
@@ -62,3 +92,137 @@ func (mr *MockStoragerMockRecorder) GetAddress(arg0 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAddress", reflect.TypeOf((*MockStorager)(nil).GetAddress), arg0)
 }
+
+// GetLink mocks base method.
+func (m *MockStorager) GetLink(arg0 string) (*storage.Link, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLink", arg0)
+	ret0, _ := ret[0].(*storage.Link)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLink indicates an expected call of GetLink.
+func (mr *MockStoragerMockRecorder) GetLink(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLink", reflect.TypeOf((*MockStorager)(nil).GetLink), arg0)
+}
+
+// ListLinks mocks base method.
+func (m *MockStorager) ListLinks(arg0 storage.LinkFilter) ([]*storage.Link, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListLinks", arg0)
+	ret0, _ := ret[0].([]*storage.Link)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListLinks indicates an expected call of ListLinks.
+func (mr *MockStoragerMockRecorder) ListLinks(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListLinks", reflect.TypeOf((*MockStorager)(nil).ListLinks), arg0)
+}
+
+// ListTags mocks base method.
+func (m *MockStorager) ListTags(arg0 storage.LinkFilter) ([]storage.TagCount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListTags", arg0)
+	ret0, _ := ret[0].([]storage.TagCount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTags indicates an expected call of ListTags.
+func (mr *MockStoragerMockRecorder) ListTags(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTags", reflect.TypeOf((*MockStorager)(nil).ListTags), arg0)
+}
+
+// RenameTags mocks base method.
+func (m *MockStorager) RenameTags(arg0 storage.LinkFilter, arg1 []string, arg2 string) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RenameTags", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RenameTags indicates an expected call of RenameTags.
+func (mr *MockStoragerMockRecorder) RenameTags(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RenameTags", reflect.TypeOf((*MockStorager)(nil).RenameTags), arg0, arg1, arg2)
+}
+
+// RollbackAddress mocks base method.
+func (m *MockStorager) RollbackAddress(arg0 string, arg1 int, arg2 string, arg3 int) (*storage.Link, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RollbackAddress", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*storage.Link)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RollbackAddress indicates an expected call of RollbackAddress.
+func (mr *MockStoragerMockRecorder) RollbackAddress(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RollbackAddress", reflect.TypeOf((*MockStorager)(nil).RollbackAddress), arg0, arg1, arg2, arg3)
+}
+
+// SearchLinks mocks base method.
+func (m *MockStorager) SearchLinks(arg0 storage.LinkFilter, arg1 string, arg2 int) ([]storage.SearchResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchLinks", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]storage.SearchResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchLinks indicates an expected call of SearchLinks.
+func (mr *MockStoragerMockRecorder) SearchLinks(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchLinks", reflect.TypeOf((*MockStorager)(nil).SearchLinks), arg0, arg1, arg2)
+}
+
+// SetLabels mocks base method.
+func (m *MockStorager) SetLabels(arg0 string, arg1 []string, arg2 string) (*storage.Link, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetLabels", arg0, arg1, arg2)
+	ret0, _ := ret[0].(*storage.Link)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SetLabels indicates an expected call of SetLabels.
+func (mr *MockStoragerMockRecorder) SetLabels(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLabels", reflect.TypeOf((*MockStorager)(nil).SetLabels), arg0, arg1, arg2)
+}
+
+// UpdateAddress mocks base method.
+func (m *MockStorager) UpdateAddress(arg0, arg1, arg2 string, arg3 int) (*storage.Link, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateAddress", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(*storage.Link)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateAddress indicates an expected call of UpdateAddress.
+func (mr *MockStoragerMockRecorder) UpdateAddress(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateAddress", reflect.TypeOf((*MockStorager)(nil).UpdateAddress), arg0, arg1, arg2, arg3)
+}
+
+// UseClick mocks base method.
+func (m *MockStorager) UseClick(arg0 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseClick", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UseClick indicates an expected call of UseClick.
+func (mr *MockStoragerMockRecorder) UseClick(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseClick", reflect.TypeOf((*MockStorager)(nil).UseClick), arg0)
+}
@@ -0,0 +1,106 @@
+package mware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/adettelle/go-url-shortener/internal/auth"
+	"github.com/adettelle/go-url-shortener/internal/logger"
+	"go.uber.org/zap"
+)
+
+// KeyAuthenticator resolves an API key to the principal it acts as.
+type KeyAuthenticator interface {
+	Authenticate(token string) (*auth.Principal, error)
+}
+
+// TokenVerifier resolves a bearer JWT to the user it was issued to.
+type TokenVerifier interface {
+	VerifyToken(ctx context.Context, raw string) (*auth.Principal, error)
+}
+
+// SessionReader finds the user of the session cookie of a request.
+type SessionReader interface {
+	Principal(r *http.Request) (*auth.Principal, bool)
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+// Authenticate puts the caller into the request context. A bearer token
+// that looks like a JWT is checked by tokens, any other one by keys;
+// without the header the session cookie is consulted. A wrong token gets
+// 401, requests without credentials pass through anonymously. Any of the
+// arguments may be nil.
+func Authenticate(keys KeyAuthenticator, tokens TokenVerifier, sessions SessionReader) func(http.HandlerFunc) http.HandlerFunc {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			token, ok := bearerToken(r)
+			if !ok {
+				if sessions != nil {
+					if p, ok := sessions.Principal(r); ok {
+						r = r.WithContext(auth.NewContext(r.Context(), p))
+					}
+				}
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			var p *auth.Principal
+			err := errUnsupportedToken
+			switch {
+			case tokens != nil && looksLikeJWT(token):
+				p, err = tokens.VerifyToken(r.Context(), token)
+			case keys != nil:
+				p, err = keys.Authenticate(token)
+			}
+			if err != nil {
+				logger.Logger.Info("rejected bearer token", zap.Error(err))
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			h.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), p)))
+		}
+	}
+}
+
+var errUnsupportedToken = errors.New("unsupported bearer token")
+
+// looksLikeJWT reports whether token has the three dot separated parts
+// of a JWS compact serialization.
+func looksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// RequireScope lets through callers with scope. Anonymous callers get 401
+// unless allowAnonymous is set; callers without the scope always get 403.
+func RequireScope(scope string, allowAnonymous bool) func(http.HandlerFunc) http.HandlerFunc {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			p := auth.FromContext(r.Context())
+			switch {
+			case p == nil || (p.APIKeyID == "" && p.UserID == ""):
+				if !allowAnonymous {
+					w.Header().Set("WWW-Authenticate", "Bearer")
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+			case !p.HasScope(scope):
+				w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			h.ServeHTTP(w, r)
+		}
+	}
+}
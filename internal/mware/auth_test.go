@@ -0,0 +1,146 @@
+package mware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/adettelle/go-url-shortener/internal/auth"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeKeys map[string]*auth.Principal
+
+func (k fakeKeys) Authenticate(token string) (*auth.Principal, error) {
+	if p, ok := k[token]; ok {
+		return p, nil
+	}
+	return nil, errors.New("invalid api key")
+}
+
+func TestRequireScope(t *testing.T) {
+	keys := fakeKeys{
+		"reader": {APIKeyID: "k1", Scopes: []string{auth.ScopeRead}},
+		"admin":  {APIKeyID: "k2", Scopes: []string{auth.ScopeAdmin}},
+	}
+
+	tests := []struct {
+		name           string
+		header         string
+		scope          string
+		allowAnonymous bool
+		wantCode       int
+	}{
+		{"anonymous allowed", "", auth.ScopeCreate, true, http.StatusOK},
+		{"anonymous rejected", "", auth.ScopeCreate, false, http.StatusUnauthorized},
+		{"unknown key", "Bearer nope", auth.ScopeRead, true, http.StatusUnauthorized},
+		{"other scheme is ignored", "Basic dXNlcjpwYXNz", auth.ScopeRead, true, http.StatusOK},
+		{"key with scope", "Bearer reader", auth.ScopeRead, false, http.StatusOK},
+		{"lower case scheme", "bearer reader", auth.ScopeRead, false, http.StatusOK},
+		{"key without scope", "Bearer reader", auth.ScopeCreate, true, http.StatusForbidden},
+		{"admin has every scope", "Bearer admin", auth.ScopeDelete, false, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *auth.Principal
+			f := Authenticate(keys, nil, nil)(RequireScope(tt.scope, tt.allowAnonymous)(
+				func(w http.ResponseWriter, r *http.Request) {
+					got = auth.FromContext(r.Context())
+					w.WriteHeader(http.StatusOK)
+				}))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/links/abc", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			f(w, req)
+
+			require.Equal(t, tt.wantCode, w.Code)
+			if w.Code == http.StatusUnauthorized {
+				require.Contains(t, w.Header().Get("WWW-Authenticate"), "Bearer")
+			}
+			if w.Code == http.StatusOK && tt.header != "" && got != nil {
+				require.NotEmpty(t, got.APIKeyID)
+			}
+		})
+	}
+}
+
+type fakeTokens map[string]*auth.Principal
+
+func (f fakeTokens) VerifyToken(_ context.Context, raw string) (*auth.Principal, error) {
+	if p, ok := f[raw]; ok {
+		return p, nil
+	}
+	return nil, errors.New("bad signature")
+}
+
+type fakeSessions map[string]*auth.Principal
+
+func (f fakeSessions) Principal(r *http.Request) (*auth.Principal, bool) {
+	cookie, err := r.Cookie("session")
+	if err != nil {
+		return nil, false
+	}
+	p, ok := f[cookie.Value]
+	return p, ok
+}
+
+func TestAuthenticate(t *testing.T) {
+	keys := fakeKeys{"sk_key": {APIKeyID: "k1"}}
+	tokens := fakeTokens{"a.b.c": {UserID: "jwt-user"}}
+	sessions := fakeSessions{"s1": {UserID: "cookie-user"}}
+
+	tests := []struct {
+		name     string
+		header   string
+		cookie   string
+		wantCode int
+		wantWho  string
+	}{
+		{"jwt", "Bearer a.b.c", "", http.StatusOK, "jwt-user"},
+		{"bad jwt", "Bearer a.b.x", "", http.StatusUnauthorized, ""},
+		{"api key", "Bearer sk_key", "", http.StatusOK, "k1"},
+		{"session", "", "s1", http.StatusOK, "cookie-user"},
+		{"unknown session is anonymous", "", "s2", http.StatusOK, ""},
+		{"bearer beats session", "Bearer sk_key", "s1", http.StatusOK, "k1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			who := ""
+			f := Authenticate(keys, tokens, sessions)(func(w http.ResponseWriter, r *http.Request) {
+				if p := auth.FromContext(r.Context()); p != nil {
+					who = p.UserID + p.APIKeyID
+				}
+				w.WriteHeader(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/api/links/abc", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "session", Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			f(w, req)
+
+			require.Equal(t, tt.wantCode, w.Code)
+			require.Equal(t, tt.wantWho, who)
+		})
+	}
+}
+
+func TestAuthenticateJWTWithoutVerifier(t *testing.T) {
+	f := Authenticate(nil, nil, nil)(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer a.b.c")
+	w := httptest.NewRecorder()
+	f(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
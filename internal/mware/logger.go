@@ -5,6 +5,8 @@
 // including status codes, request durations, and response sizes.
 // It also includes a custom implementation of the http.ResponseWriter
 // to capture detailed information about the HTTP response.
+// Per-client rate limiting is provided by RateLimiter, authentication with
+// API keys, bearer JWTs and sessions by Authenticate and RequireScope.
 package mware
 
 import (
@@ -0,0 +1,127 @@
+package mware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/auth"
+	"github.com/adettelle/go-url-shortener/internal/clientip"
+)
+
+// sweepInterval defines how often idle buckets are removed from memory.
+const sweepInterval = time.Minute
+
+// bucket is a token bucket of a single client.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter limits requests per client with a token bucket algorithm.
+// Clients are identified by API key, then by user ID and finally by IP address.
+type RateLimiter struct {
+	rate  float64 // tokens added per second
+	burst int     // bucket capacity
+	ips   *clientip.Resolver
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewRateLimiter creates a limiter allowing rate requests per second with bursts
+// of up to burst requests. A non-positive rate disables limiting.
+func NewRateLimiter(rate float64, burst int, ips *clientip.Resolver) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:    rate,
+		burst:   burst,
+		ips:     ips,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// clientKey identifies the caller the bucket belongs to.
+func (l *RateLimiter) clientKey(r *http.Request) string {
+	if p := auth.FromContext(r.Context()); p != nil {
+		if p.APIKeyID != "" {
+			return "key:" + p.APIKeyID
+		}
+		if p.UserID != "" {
+			return "user:" + p.UserID
+		}
+	}
+	return "ip:" + l.ips.ClientIP(r)
+}
+
+// take tries to take a token for key. It returns the tokens left and the time
+// until the next token becomes available.
+func (l *RateLimiter) take(key string) (bool, float64, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(l.burst), b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+		return false, b.tokens, wait
+	}
+	b.tokens--
+	return true, b.tokens, 0
+}
+
+// sweep drops buckets that have been idle long enough to be full again.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	full := time.Duration(float64(l.burst) / l.rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > full {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Limit wraps h and answers 429 Too Many Requests when the client has run out
+// of tokens. RateLimit-* headers are set on every response.
+func (l *RateLimiter) Limit(h http.HandlerFunc) http.HandlerFunc {
+	if l == nil || l.rate <= 0 {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ok, tokens, wait := l.take(l.clientKey(r))
+
+		// время до полного восстановления корзины
+		reset := (float64(l.burst) - tokens) / l.rate
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(l.burst))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(int(tokens)))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(int(math.Ceil(reset))))
+
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}
+}
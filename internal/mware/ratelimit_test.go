@@ -0,0 +1,85 @@
+package mware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/auth"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(1, 2, nil)
+	limiter.now = func() time.Time { return now }
+
+	f := limiter.Limit(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	do := func(remote string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		f(w, req)
+		return w
+	}
+
+	w := do("1.1.1.1:1000")
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	require.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+
+	require.Equal(t, http.StatusCreated, do("1.1.1.1:1000").Code)
+
+	w = do("1.1.1.1:1000")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "1", w.Header().Get("Retry-After"))
+	require.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	require.Equal(t, "2", w.Header().Get("RateLimit-Reset"))
+
+	// другой клиент не затронут
+	require.Equal(t, http.StatusCreated, do("2.2.2.2:1000").Code)
+
+	now = now.Add(time.Second)
+	require.Equal(t, http.StatusCreated, do("1.1.1.1:1000").Code)
+	require.Equal(t, http.StatusTooManyRequests, do("1.1.1.1:1000").Code)
+}
+
+func TestRateLimiterKeysByPrincipal(t *testing.T) {
+	limiter := NewRateLimiter(1, 1, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	require.Equal(t, "ip:192.0.2.1", limiter.clientKey(req))
+
+	req = req.WithContext(auth.NewContext(req.Context(), &auth.Principal{UserID: "u1"}))
+	require.Equal(t, "user:u1", limiter.clientKey(req))
+
+	req = req.WithContext(auth.NewContext(req.Context(), &auth.Principal{UserID: "u1", APIKeyID: "k1"}))
+	require.Equal(t, "key:k1", limiter.clientKey(req))
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	limiter := NewRateLimiter(0, 1, nil)
+	f := limiter.Limit(func(w http.ResponseWriter, r *http.Request) {})
+
+	for i := 0; i < 10; i++ {
+		w := httptest.NewRecorder()
+		f(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Empty(t, w.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(1, 1, nil)
+	limiter.now = func() time.Time { return now }
+
+	limiter.take("a")
+	now = now.Add(2 * sweepInterval)
+	limiter.take("b")
+	require.Len(t, limiter.buckets, 1)
+}
@@ -0,0 +1,168 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/auth"
+	"golang.org/x/oauth2"
+)
+
+// loginTTL is how long a started login may take to come back.
+const loginTTL = 10 * time.Minute
+
+// Config describes the relying party.
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	Audience     string // expected aud of bearer tokens; ClientID if empty
+	HTTPClient   *http.Client
+}
+
+// pendingLogin is a login the browser has been sent to the provider for.
+type pendingLogin struct {
+	verifier string
+	nonce    string
+	returnTo string
+	expires  time.Time
+}
+
+type InvalidLoginError struct {
+	reason string
+}
+
+func (e *InvalidLoginError) Error() string {
+	return fmt.Sprintf("invalid login: %s", e.reason)
+}
+
+// Client runs the authorization code flow with PKCE and validates tokens.
+type Client struct {
+	oauth      oauth2.Config
+	idVerifier *Verifier // id tokens are issued for the client
+	apiVerify  *Verifier // bearer tokens are issued for the API audience
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu      sync.Mutex
+	pending map[string]pendingLogin
+}
+
+// New discovers the provider and prepares the client.
+func New(ctx context.Context, cfg Config) (*Client, error) {
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	md, err := Discover(ctx, httpClient, cfg.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+	audience := cfg.Audience
+	if audience == "" {
+		audience = cfg.ClientID
+	}
+	keys := NewKeySet(httpClient, md.JWKSURI)
+
+	return &Client{
+		oauth: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       scopes,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  md.AuthorizationEndpoint,
+				TokenURL: md.TokenEndpoint,
+			},
+		},
+		idVerifier: NewVerifier(md.Issuer, cfg.ClientID, keys),
+		apiVerify:  NewVerifier(md.Issuer, audience, keys),
+		httpClient: httpClient,
+		now:        time.Now,
+		pending:    make(map[string]pendingLogin),
+	}, nil
+}
+
+// StartLogin remembers a new login and returns its state and the provider
+// URL to send the browser to. returnTo is where the user lands afterwards.
+func (c *Client) StartLogin(returnTo string) (state, authURL string, err error) {
+	state, err = randomString()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	c.mu.Lock()
+	now := c.now()
+	for s, p := range c.pending {
+		if now.After(p.expires) {
+			delete(c.pending, s)
+		}
+	}
+	c.pending[state] = pendingLogin{verifier: verifier, nonce: nonce, returnTo: returnTo, expires: now.Add(loginTTL)}
+	c.mu.Unlock()
+
+	authURL = c.oauth.AuthCodeURL(state,
+		oauth2.S256ChallengeOption(verifier),
+		oauth2.SetAuthURLParam("nonce", nonce))
+	return state, authURL, nil
+}
+
+// FinishLogin exchanges the code of the login started with state and
+// returns the verified id token claims and the page to return to.
+func (c *Client) FinishLogin(ctx context.Context, state, code string) (*Claims, string, error) {
+	c.mu.Lock()
+	p, ok := c.pending[state]
+	delete(c.pending, state) // state одноразовый
+	c.mu.Unlock()
+	if !ok || c.now().After(p.expires) {
+		return nil, "", &InvalidLoginError{reason: "unknown or expired state"}
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, c.httpClient)
+	token, err := c.oauth.Exchange(ctx, code, oauth2.VerifierOption(p.verifier))
+	if err != nil {
+		return nil, "", err
+	}
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		return nil, "", &InvalidLoginError{reason: "no id_token in token response"}
+	}
+	claims, err := c.idVerifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, "", err
+	}
+	if claims.Nonce != p.nonce {
+		return nil, "", &InvalidLoginError{reason: "nonce mismatch"}
+	}
+	return claims, p.returnTo, nil
+}
+
+// VerifyToken validates a bearer JWT sent to the API.
+func (c *Client) VerifyToken(ctx context.Context, raw string) (*auth.Principal, error) {
+	return c.apiVerify.VerifyToken(ctx, raw)
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,239 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/oidc/oidctest"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+)
+
+const redirectURL = "http://short.example/auth/callback"
+
+func newTestClient(t *testing.T, idp *oidctest.Provider) *Client {
+	t.Helper()
+	client, err := New(context.Background(), Config{
+		Issuer:       idp.Issuer(),
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  redirectURL,
+		Audience:     "shortener-api",
+	})
+	require.NoError(t, err)
+	return client
+}
+
+// authorize follows authURL at the provider and returns the callback query.
+func authorize(t *testing.T, authURL string) url.Values {
+	t.Helper()
+	noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := noRedirect.Get(authURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	require.Equal(t, redirectURL, location.Scheme+"://"+location.Host+location.Path)
+	return location.Query()
+}
+
+func TestLoginFlow(t *testing.T) {
+	idp := oidctest.NewProvider()
+	defer idp.Close()
+	client := newTestClient(t, idp)
+
+	state, authURL, err := client.StartLogin("/dashboard")
+	require.NoError(t, err)
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	require.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+	require.NotEmpty(t, u.Query().Get("nonce"))
+
+	callback := authorize(t, authURL)
+	require.Equal(t, state, callback.Get("state"))
+
+	claims, returnTo, err := client.FinishLogin(context.Background(), state, callback.Get("code"))
+	require.NoError(t, err)
+	require.Equal(t, "/dashboard", returnTo)
+	require.Equal(t, "alice", claims.Subject)
+	require.Equal(t, "alice@example.com", claims.Principal().Email)
+	require.True(t, claims.Principal().EmailVerified)
+
+	// state одноразовый
+	_, _, err = client.FinishLogin(context.Background(), state, callback.Get("code"))
+	require.ErrorAs(t, err, new(*InvalidLoginError))
+}
+
+func TestLoginFlowWrongCode(t *testing.T) {
+	idp := oidctest.NewProvider()
+	defer idp.Close()
+	client := newTestClient(t, idp)
+
+	state, _, err := client.StartLogin("/")
+	require.NoError(t, err)
+	_, _, err = client.FinishLogin(context.Background(), state, "forged")
+	require.Error(t, err)
+}
+
+func TestLoginExpired(t *testing.T) {
+	idp := oidctest.NewProvider()
+	defer idp.Close()
+	client := newTestClient(t, idp)
+
+	now := time.Now()
+	client.now = func() time.Time { return now }
+	state, authURL, err := client.StartLogin("/")
+	require.NoError(t, err)
+	callback := authorize(t, authURL)
+
+	now = now.Add(loginTTL + time.Second)
+	_, _, err = client.FinishLogin(context.Background(), state, callback.Get("code"))
+	require.ErrorAs(t, err, new(*InvalidLoginError))
+}
+
+func TestVerifyToken(t *testing.T) {
+	idp := oidctest.NewProvider()
+	defer idp.Close()
+	client := newTestClient(t, idp)
+	ctx := context.Background()
+
+	p, err := client.VerifyToken(ctx, idp.Sign(jwt.MapClaims{"sub": "bob", "aud": "shortener-api", "email": "bob@example.com"}))
+	require.NoError(t, err)
+	require.Equal(t, "bob", p.UserID)
+	require.Equal(t, "bob@example.com", p.Email)
+	require.False(t, p.EmailVerified, "email_verified is missing")
+
+	for _, verified := range []any{true, "true"} {
+		p, err = client.VerifyToken(ctx, idp.Sign(jwt.MapClaims{"sub": "bob", "aud": "shortener-api",
+			"email": "bob@example.com", "email_verified": verified}))
+		require.NoError(t, err)
+		require.True(t, p.EmailVerified, verified)
+	}
+	p, err = client.VerifyToken(ctx, idp.Sign(jwt.MapClaims{"sub": "bob", "aud": "shortener-api",
+		"email": "bob@example.com", "email_verified": false}))
+	require.NoError(t, err)
+	require.False(t, p.EmailVerified)
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+	}{
+		{"id token audience", jwt.MapClaims{"sub": "bob", "aud": oidctest.ClientID}},
+		{"expired", jwt.MapClaims{"sub": "bob", "aud": "shortener-api", "exp": time.Now().Add(-time.Hour).Unix()}},
+		{"other issuer", jwt.MapClaims{"sub": "bob", "aud": "shortener-api", "iss": "https://evil.example"}},
+		{"no subject", jwt.MapClaims{"aud": "shortener-api"}},
+		{"not yet valid", jwt.MapClaims{"sub": "bob", "aud": "shortener-api", "nbf": time.Now().Add(time.Hour).Unix()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.VerifyToken(ctx, idp.Sign(tt.claims))
+			require.Error(t, err)
+		})
+	}
+
+	unsigned := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+		"sub": "bob", "aud": "shortener-api", "iss": idp.Issuer(), "exp": time.Now().Add(time.Hour).Unix(),
+	})
+	raw, err := unsigned.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+	_, err = client.VerifyToken(ctx, raw)
+	require.Error(t, err)
+}
+
+func TestVerifyTokenAfterKeyRotation(t *testing.T) {
+	idp := oidctest.NewProvider()
+	defer idp.Close()
+	client := newTestClient(t, idp)
+	ctx := context.Background()
+
+	_, err := client.VerifyToken(ctx, idp.Sign(jwt.MapClaims{"sub": "bob", "aud": "shortener-api"}))
+	require.NoError(t, err)
+
+	// новый kid вызывает повторную загрузку JWKS, но не чаще раза в минуту
+	idp.RotateKey()
+	now := time.Now()
+	client.apiVerify.keys.now = func() time.Time { return now }
+	_, err = client.VerifyToken(ctx, idp.Sign(jwt.MapClaims{"sub": "bob", "aud": "shortener-api"}))
+	require.Error(t, err)
+
+	now = now.Add(minRefreshInterval)
+	_, err = client.VerifyToken(ctx, idp.Sign(jwt.MapClaims{"sub": "bob", "aud": "shortener-api"}))
+	require.NoError(t, err)
+}
+
+func TestDiscoverRejectsWrongIssuer(t *testing.T) {
+	idp := oidctest.NewProvider()
+	defer idp.Close()
+
+	_, err := Discover(context.Background(), http.DefaultClient, idp.Issuer()+"/tenant")
+	require.Error(t, err)
+}
+
+func TestKeySetRefreshDoesNotBlockCachedKeys(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	jwks := fmt.Sprintf(`{"keys":[{"kty":"RSA","kid":"k1","n":%q,"e":"AQAB"}]}`,
+		base64.RawURLEncoding.EncodeToString(private.N.Bytes()))
+
+	var fetches atomic.Int32
+	fetching := make(chan struct{}, 10)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) > 1 {
+			fetching <- struct{}{}
+			<-release // провайдер отвечает медленно
+		}
+		w.Write([]byte(jwks))
+	}))
+	defer server.Close()
+
+	ks := NewKeySet(server.Client(), server.URL)
+	now := time.Now()
+	ks.now = func() time.Time { return now }
+	ctx := context.Background()
+	_, err = ks.Key(ctx, "k1")
+	require.NoError(t, err)
+
+	now = now.Add(minRefreshInterval)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := ks.Key(ctx, "k2")
+			require.Equal(t, &UnknownKeyError{kid: "k2"}, err)
+		}()
+	}
+	<-fetching
+
+	// известный ключ выдаётся, пока JWKS загружается
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := ks.Key(ctx, "k1")
+		require.NoError(t, err)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("cached key waits for the jwks refresh")
+	}
+
+	close(release)
+	wg.Wait()
+	require.Equal(t, int32(2), fetches.Load(), "concurrent refreshes make one request")
+}
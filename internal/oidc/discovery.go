@@ -0,0 +1,60 @@
+// Package oidc implements the parts of OpenID Connect the shortener needs:
+// the authorization code flow with PKCE for browser logins and validation
+// of bearer JWTs issued by the same provider.
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Metadata is the subset of the provider configuration document we use.
+type Metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type DiscoveryError struct {
+	issuer string
+	reason string
+}
+
+func (e *DiscoveryError) Error() string {
+	return fmt.Sprintf("oidc discovery for %s: %s", e.issuer, e.reason)
+}
+
+// Discover loads the provider configuration from
+// {issuer}/.well-known/openid-configuration.
+func Discover(ctx context.Context, client *http.Client, issuer string) (*Metadata, error) {
+	issuer = strings.TrimSuffix(issuer, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &DiscoveryError{issuer: issuer, reason: resp.Status}
+	}
+	var md Metadata
+	if err := json.NewDecoder(resp.Body).Decode(&md); err != nil {
+		return nil, err
+	}
+	// подмена issuer позволила бы принимать чужие токены
+	if strings.TrimSuffix(md.Issuer, "/") != issuer {
+		return nil, &DiscoveryError{issuer: issuer, reason: fmt.Sprintf("document names issuer %q", md.Issuer)}
+	}
+	if md.AuthorizationEndpoint == "" || md.TokenEndpoint == "" || md.JWKSURI == "" {
+		return nil, &DiscoveryError{issuer: issuer, reason: "endpoints missing"}
+	}
+	return &md, nil
+}
@@ -0,0 +1,187 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// minRefreshInterval protects the provider from a refetch on every token
+// with an unknown key id.
+const minRefreshInterval = time.Minute
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type UnknownKeyError struct {
+	kid string
+}
+
+func (e *UnknownKeyError) Error() string {
+	return fmt.Sprintf("no signing key %q in jwks", e.kid)
+}
+
+// KeySet caches the public keys of the provider. Keys are refetched when
+// a token names a key id that isn't cached yet, which handles rotation.
+// The provider is asked without holding the cache lock, so a slow answer
+// delays only the tokens with the new key id; concurrent refetches are
+// collapsed into one request.
+type KeySet struct {
+	url     string
+	client  *http.Client
+	now     func() time.Time
+	refetch singleflight.Group
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	lastRefresh time.Time
+}
+
+func NewKeySet(client *http.Client, url string) *KeySet {
+	return &KeySet{url: url, client: client, now: time.Now}
+}
+
+// Key returns the public key with the given id.
+func (ks *KeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	key, ok, fresh := ks.cached(kid)
+	if ok {
+		return key, nil
+	}
+	if fresh {
+		return nil, &UnknownKeyError{kid: kid}
+	}
+	if _, err, _ := ks.refetch.Do("jwks", func() (any, error) {
+		return nil, ks.refresh(ctx)
+	}); err != nil {
+		return nil, err
+	}
+	if key, ok, _ := ks.cached(kid); ok {
+		return key, nil
+	}
+	return nil, &UnknownKeyError{kid: kid}
+}
+
+// cached returns the key with the given id from the cache; fresh tells
+// whether the cache was refreshed too recently to be refetched again.
+func (ks *KeySet) cached(kid string) (key crypto.PublicKey, ok, fresh bool) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	key, ok = ks.keys[kid]
+	fresh = ks.keys != nil && ks.now().Sub(ks.lastRefresh) < minRefreshInterval
+	return key, ok, fresh
+}
+
+func (ks *KeySet) refresh(ctx context.Context) error {
+	// пока этот вызов ждал своей очереди, ключи могли уже обновить
+	if _, _, fresh := ks.cached(""); fresh {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ks.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := ks.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching jwks: %s", resp.Status)
+	}
+
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			// ключи неизвестных типов пропускаем, остальные остаются рабочими
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys = keys
+	ks.lastRefresh = ks.now()
+	return nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("rsa exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
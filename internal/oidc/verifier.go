@@ -0,0 +1,86 @@
+package oidc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/auth"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// clockSkew is tolerated between us and the provider.
+const clockSkew = time.Minute
+
+// Claims are the token claims we read.
+type Claims struct {
+	jwt.RegisteredClaims
+	Email         string `json:"email"`
+	EmailVerified flag   `json:"email_verified"`
+	Name          string `json:"name"`
+	Nonce         string `json:"nonce"`
+}
+
+// flag is a boolean claim. Some providers send it as a string, "true".
+type flag bool
+
+func (f *flag) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	*f = s == "true" || s == `"true"`
+	return nil
+}
+
+// Verifier checks signed JWTs of one issuer for one audience.
+type Verifier struct {
+	issuer   string
+	audience string
+	keys     *KeySet
+	now      func() time.Time
+}
+
+func NewVerifier(issuer, audience string, keys *KeySet) *Verifier {
+	return &Verifier{issuer: issuer, audience: audience, keys: keys, now: time.Now}
+}
+
+// Verify checks the signature, issuer, audience and lifetime of raw.
+func (v *Verifier) Verify(ctx context.Context, raw string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.keys.Key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384"}),
+		jwt.WithIssuer(v.issuer),
+		jwt.WithAudience(v.audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(clockSkew),
+		jwt.WithTimeFunc(v.now),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("token has no subject")
+	}
+	return claims, nil
+}
+
+// VerifyToken checks a bearer token and returns the user it was issued to.
+func (v *Verifier) VerifyToken(ctx context.Context, raw string) (*auth.Principal, error) {
+	claims, err := v.Verify(ctx, raw)
+	if err != nil {
+		return nil, err
+	}
+	return claims.Principal(), nil
+}
+
+// Principal maps the claims to a signed in user.
+func (c *Claims) Principal() *auth.Principal {
+	return &auth.Principal{
+		UserID:        c.Subject,
+		Email:         c.Email,
+		EmailVerified: bool(c.EmailVerified),
+		Name:          c.Name,
+		Scopes:        append([]string(nil), auth.UserScopes...),
+	}
+}
@@ -0,0 +1,129 @@
+package policy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+)
+
+type ruleKind int
+
+const (
+	ruleSuffix ruleKind = iota
+	ruleWildcard
+	ruleExact
+)
+
+// hosts-file entries that describe the local machine rather than a blocked host.
+var skippedHosts = map[string]struct{}{
+	"localhost":             {},
+	"localhost.localdomain": {},
+	"local":                 {},
+	"broadcasthost":         {},
+	"ip6-localhost":         {},
+	"ip6-loopback":          {},
+	"0.0.0.0":               {},
+}
+
+// ruleSet is a compiled set of rules from one or more files.
+type ruleSet struct {
+	trie    *suffixTrie
+	regexps []*regexp.Regexp
+	size    int
+}
+
+func newRuleSet() *ruleSet {
+	return &ruleSet{trie: newSuffixTrie()}
+}
+
+func (rs *ruleSet) match(host string) bool {
+	if rs == nil || rs.size == 0 {
+		return false
+	}
+	if rs.trie.match(host) {
+		return true
+	}
+	for _, re := range rs.regexps {
+		if re.MatchString(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// parse reads rules in either hosts-file or plain-list format. Both formats
+// can be mixed in one file, the format is detected line by line:
+//
+//	0.0.0.0 ads.example.com   hosts-file entry, blocks the exact host
+//	example.com               the domain and all of its subdomains
+//	*.example.com             subdomains only
+//	=example.com              the exact host only
+//	/^ads\d+\./               regular expression matched against the host
+//
+// Everything after '#' is a comment.
+func (rs *ruleSet) parse(r io.Reader, name string) error {
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "/") && strings.HasSuffix(line, "/") && len(line) > 2 {
+			re, err := regexp.Compile(line[1 : len(line)-1])
+			if err != nil {
+				return fmt.Errorf("%s:%d: invalid regexp: %w", name, lineNo, err)
+			}
+			rs.regexps = append(rs.regexps, re)
+			rs.size++
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) > 1 && net.ParseIP(fields[0]) != nil {
+			for _, host := range fields[1:] {
+				host = normalizeHost(host)
+				if _, skip := skippedHosts[host]; skip || host == "" {
+					continue
+				}
+				rs.trie.insert(host, ruleExact)
+				rs.size++
+			}
+			continue
+		}
+		if len(fields) > 1 {
+			return fmt.Errorf("%s:%d: unexpected rule %q", name, lineNo, line)
+		}
+
+		rule := fields[0]
+		kind := ruleSuffix
+		switch {
+		case strings.HasPrefix(rule, "*."):
+			kind = ruleWildcard
+			rule = rule[2:]
+		case strings.HasPrefix(rule, "="):
+			kind = ruleExact
+			rule = rule[1:]
+		}
+		rule = normalizeHost(rule)
+		if rule == "" {
+			return fmt.Errorf("%s:%d: empty domain", name, lineNo)
+		}
+		rs.trie.insert(rule, kind)
+		rs.size++
+	}
+	return scanner.Err()
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
@@ -0,0 +1,170 @@
+// Package policy decides which destination hosts may be shortened and
+// followed. Rules are loaded from local allowlist and denylist files and are
+// reloaded automatically when the files change on disk.
+package policy
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/logger"
+	"go.uber.org/zap"
+)
+
+type BlockedError struct {
+	host string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("host %s is blocked by policy", e.host)
+}
+
+// NoHostError blocks URLs the policy can not be applied to: ones that do not
+// parse or have no host, such as the opaque https:evil.com.
+type NoHostError struct {
+	rawURL string
+}
+
+func (e *NoHostError) Error() string {
+	return fmt.Sprintf("URL %q has no host, blocked by policy", e.rawURL)
+}
+
+// rules is an immutable snapshot of both lists, swapped atomically on reload.
+type rules struct {
+	allow *ruleSet
+	deny  *ruleSet
+}
+
+// Engine checks URLs against the configured lists. An allowlist entry always
+// wins over a denylist entry, so the allowlist is used for exceptions.
+type Engine struct {
+	allowFiles []string
+	denyFiles  []string
+
+	current atomic.Pointer[rules]
+
+	mu       sync.Mutex
+	modTimes map[string]time.Time
+}
+
+// New creates an Engine and loads the rule files. It fails if any of the
+// files cannot be read or parsed.
+func New(allowFiles, denyFiles []string) (*Engine, error) {
+	e := &Engine{
+		allowFiles: allowFiles,
+		denyFiles:  denyFiles,
+		modTimes:   make(map[string]time.Time),
+	}
+	if err := e.Reload(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Check returns a *BlockedError if the host of rawURL is denied. URLs whose
+// host can not be told are blocked with a *NoHostError, otherwise forms
+// like https:evil.com would slip past the denylist.
+func (e *Engine) Check(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return &NoHostError{rawURL: rawURL}
+	}
+	host := normalizeHost(u.Hostname())
+	if host == "" {
+		return &NoHostError{rawURL: rawURL}
+	}
+
+	r := e.current.Load()
+	if r.allow.match(host) {
+		return nil
+	}
+	if r.deny.match(host) {
+		return &BlockedError{host: host}
+	}
+	return nil
+}
+
+// Reload re-reads all rule files. On error the previously loaded rules stay active.
+func (e *Engine) Reload() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	modTimes := make(map[string]time.Time)
+	allow, err := loadFiles(e.allowFiles, modTimes)
+	if err != nil {
+		return err
+	}
+	deny, err := loadFiles(e.denyFiles, modTimes)
+	if err != nil {
+		return err
+	}
+
+	e.current.Store(&rules{allow: allow, deny: deny})
+	e.modTimes = modTimes
+	return nil
+}
+
+// Watch polls the rule files every interval and reloads them when any of
+// them changes. It blocks until ctx is cancelled.
+func (e *Engine) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !e.changed() {
+				continue
+			}
+			if err := e.Reload(); err != nil {
+				logger.Logger.Error("error in reloading policy files", zap.Error(err))
+				continue
+			}
+			logger.Logger.Info("policy files reloaded")
+		}
+	}
+}
+
+func (e *Engine) changed() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, name := range append(append([]string{}, e.allowFiles...), e.denyFiles...) {
+		info, err := os.Stat(name)
+		if err != nil {
+			// a file that is being replaced may be missing for a moment
+			continue
+		}
+		if !info.ModTime().Equal(e.modTimes[name]) {
+			return true
+		}
+	}
+	return false
+}
+
+func loadFiles(names []string, modTimes map[string]time.Time) (*ruleSet, error) {
+	rs := newRuleSet()
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		info, err := f.Stat()
+		if err == nil {
+			modTimes[name] = info.ModTime()
+		}
+		err = rs.parse(f, name)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rs, nil
+}
@@ -0,0 +1,129 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestParseFormats(t *testing.T) {
+	rs := newRuleSet()
+	err := rs.parse(strings.NewReader(`
+# hosts file section
+127.0.0.1 localhost
+0.0.0.0 ads.example.com tracker.example.com # inline comment
+
+# plain list section
+bad.org
+*.wild.net
+=exact.io
+/^phish[0-9]+\./
+`), "test")
+	require.NoError(t, err)
+
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"ads.example.com", true},
+		{"tracker.example.com", true},
+		{"example.com", false},
+		{"sub.ads.example.com", false},
+		{"localhost", false},
+		{"bad.org", true},
+		{"a.b.bad.org", true},
+		{"notbad.org", false},
+		{"wild.net", false},
+		{"x.wild.net", true},
+		{"exact.io", true},
+		{"www.exact.io", false},
+		{"phish42.com", true},
+		{"phish.com", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			require.Equal(t, tt.want, rs.match(tt.host))
+		})
+	}
+}
+
+func TestParseInvalidRegexp(t *testing.T) {
+	rs := newRuleSet()
+	err := rs.parse(strings.NewReader("/[/\n"), "test")
+	require.Error(t, err)
+}
+
+func TestCheckAllowOverridesDeny(t *testing.T) {
+	dir := t.TempDir()
+	deny := writeFile(t, dir, "deny.txt", "example.com\n")
+	allow := writeFile(t, dir, "allow.txt", "docs.example.com\n")
+
+	e, err := New([]string{allow}, []string{deny})
+	require.NoError(t, err)
+
+	require.Equal(t, &BlockedError{host: "example.com"}, e.Check("https://Example.COM./path"))
+	require.Error(t, e.Check("http://www.example.com"))
+	require.NoError(t, e.Check("https://docs.example.com/q3"))
+	require.NoError(t, e.Check("https://practicum.yandex.ru/"))
+}
+
+func TestCheckBlocksURLsWithoutHost(t *testing.T) {
+	dir := t.TempDir()
+	deny := writeFile(t, dir, "deny.txt", "evil.com\n")
+
+	e, err := New(nil, []string{deny})
+	require.NoError(t, err)
+
+	for _, rawURL := range []string{
+		"https:evil.com",
+		"https:/evil.com/path",
+		"http:///evil.com",
+		"not a url",
+		"",
+		"http://[::1",
+		"mailto:team@evil.com",
+	} {
+		require.Equal(t, &NoHostError{rawURL: rawURL}, e.Check(rawURL), rawURL)
+	}
+}
+
+func TestReloadOnChange(t *testing.T) {
+	dir := t.TempDir()
+	deny := writeFile(t, dir, "deny.txt", "first.com\n")
+
+	e, err := New(nil, []string{deny})
+	require.NoError(t, err)
+	require.Error(t, e.Check("http://first.com"))
+	require.False(t, e.changed())
+
+	writeFile(t, dir, "deny.txt", "second.com\n")
+	require.NoError(t, os.Chtimes(deny, time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+	require.True(t, e.changed())
+
+	require.NoError(t, e.Reload())
+	require.NoError(t, e.Check("http://first.com"))
+	require.Error(t, e.Check("http://second.com"))
+}
+
+func TestReloadKeepsRulesOnError(t *testing.T) {
+	dir := t.TempDir()
+	deny := writeFile(t, dir, "deny.txt", "first.com\n")
+
+	e, err := New(nil, []string{deny})
+	require.NoError(t, err)
+
+	writeFile(t, dir, "deny.txt", "/[/\n")
+	require.Error(t, e.Reload())
+	require.Error(t, e.Check("http://first.com"))
+}
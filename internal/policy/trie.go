@@ -0,0 +1,66 @@
+package policy
+
+import "strings"
+
+// node is a single label of the reversed domain trie. For "ads.example.com"
+// the path from the root is "com" -> "example" -> "ads".
+type node struct {
+	children map[string]*node
+	suffix   bool // rule matches this domain and every subdomain
+	wildcard bool // rule matches subdomains only (*.example.com)
+	exact    bool // rule matches this exact host only
+}
+
+// suffixTrie matches host names against domain rules in O(number of labels).
+type suffixTrie struct {
+	root *node
+}
+
+func newSuffixTrie() *suffixTrie {
+	return &suffixTrie{root: &node{}}
+}
+
+func (t *suffixTrie) insert(domain string, kind ruleKind) {
+	labels := strings.Split(domain, ".")
+	n := t.root
+	for i := len(labels) - 1; i >= 0; i-- {
+		if n.children == nil {
+			n.children = make(map[string]*node)
+		}
+		child, ok := n.children[labels[i]]
+		if !ok {
+			child = &node{}
+			n.children[labels[i]] = child
+		}
+		n = child
+	}
+
+	switch kind {
+	case ruleSuffix:
+		n.suffix = true
+	case ruleWildcard:
+		n.wildcard = true
+	case ruleExact:
+		n.exact = true
+	}
+}
+
+// match reports whether host is covered by any rule in the trie.
+func (t *suffixTrie) match(host string) bool {
+	labels := strings.Split(host, ".")
+	n := t.root
+	for i := len(labels) - 1; i >= 0; i-- {
+		child, ok := n.children[labels[i]]
+		if !ok {
+			return false
+		}
+		n = child
+		if n.suffix {
+			return true
+		}
+		if n.wildcard && i > 0 {
+			return true
+		}
+	}
+	return n.exact
+}
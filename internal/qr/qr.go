@@ -0,0 +1,151 @@
+// Package qr renders QR codes as PNG or SVG images. Codes are built locally
+// with a pure Go encoder, no external service is involved.
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	MinSize       = 64
+	MaxSize       = 2048
+	MaxMargin     = 16
+	DefaultSize   = 256
+	DefaultMargin = 4 // quiet zone recommended by the standard, in modules
+)
+
+// Options describe the rendered image.
+type Options struct {
+	Size   int    // width and height of the image in pixels
+	Level  string // error correction level: L, M, Q or H
+	Margin int    // quiet zone around the code in modules
+}
+
+// DefaultOptions returns options suitable for printing.
+func DefaultOptions() Options {
+	return Options{Size: DefaultSize, Level: "M", Margin: DefaultMargin}
+}
+
+type InvalidOptionsError struct {
+	reason string
+}
+
+func (e *InvalidOptionsError) Error() string {
+	return "invalid qr options: " + e.reason
+}
+
+// Validate checks that the options are within the supported limits.
+func (o Options) Validate() error {
+	if o.Size < MinSize || o.Size > MaxSize {
+		return &InvalidOptionsError{reason: fmt.Sprintf("size must be between %d and %d", MinSize, MaxSize)}
+	}
+	if o.Margin < 0 || o.Margin > MaxMargin {
+		return &InvalidOptionsError{reason: fmt.Sprintf("margin must be between 0 and %d", MaxMargin)}
+	}
+	if _, err := recoveryLevel(o.Level); err != nil {
+		return err
+	}
+	return nil
+}
+
+func recoveryLevel(level string) (qrcode.RecoveryLevel, error) {
+	switch strings.ToUpper(level) {
+	case "L":
+		return qrcode.Low, nil
+	case "M", "":
+		return qrcode.Medium, nil
+	case "Q":
+		return qrcode.High, nil
+	case "H":
+		return qrcode.Highest, nil
+	}
+	return 0, &InvalidOptionsError{reason: "level must be one of L, M, Q, H"}
+}
+
+// modules encodes content and returns the code without a quiet zone.
+func modules(content string, o Options) ([][]bool, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	level, _ := recoveryLevel(o.Level)
+	code, err := qrcode.New(content, level)
+	if err != nil {
+		return nil, err
+	}
+	code.DisableBorder = true
+	return code.Bitmap(), nil
+}
+
+// PNG renders content as a Size x Size PNG image.
+func PNG(content string, o Options) ([]byte, error) {
+	bitmap, err := modules(content, o)
+	if err != nil {
+		return nil, err
+	}
+
+	total := len(bitmap) + 2*o.Margin
+	scale := o.Size / total
+	if scale < 1 {
+		return nil, &InvalidOptionsError{reason: "size is too small for this content"}
+	}
+	// остаток пикселей делим поровну между сторонами, чтобы код был по центру
+	offset := (o.Size-scale*total)/2 + scale*o.Margin
+
+	img := image.NewPaletted(image.Rect(0, 0, o.Size, o.Size),
+		color.Palette{color.White, color.Black})
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetColorIndex(offset+x*scale+dx, offset+y*scale+dy, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG renders content as a scalable image with Size as its nominal size.
+// Horizontal runs of dark modules are merged into a single path segment.
+func SVG(content string, o Options) ([]byte, error) {
+	bitmap, err := modules(content, o)
+	if err != nil {
+		return nil, err
+	}
+
+	total := len(bitmap) + 2*o.Margin
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		o.Size, o.Size, total, total)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, total, total)
+	for y, row := range bitmap {
+		for x := 0; x < len(row); {
+			if !row[x] {
+				x++
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", start+o.Margin, y+o.Margin, x-start, x-start)
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,54 @@
+package qr
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPNG(t *testing.T) {
+	data, err := PNG("http://localhost:8080/qqVjJVf", DefaultOptions())
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, DefaultSize, img.Bounds().Dx())
+	require.Equal(t, DefaultSize, img.Bounds().Dy())
+
+	// угол находится в зоне отступа и должен быть белым
+	r, g, b, _ := img.At(0, 0).RGBA()
+	require.Equal(t, []uint32{0xffff, 0xffff, 0xffff}, []uint32{r, g, b})
+}
+
+func TestPNGMarginZero(t *testing.T) {
+	o := Options{Size: 210, Level: "L", Margin: 0}
+	data, err := PNG("http://localhost:8080/qqVjJVf", o)
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	// version 2 code is 25 modules wide, 210/25 = 8px per module, 5px padding
+	r, _, _, _ := img.At(5, 5).RGBA()
+	require.Equal(t, uint32(0), r, "finder pattern starts right after padding")
+}
+
+func TestSVG(t *testing.T) {
+	data, err := SVG("http://localhost:8080/qqVjJVf", Options{Size: 300, Level: "H", Margin: 2})
+	require.NoError(t, err)
+
+	svg := string(data)
+	require.True(t, strings.HasPrefix(svg, "<svg"))
+	require.Contains(t, svg, `width="300"`)
+	// finder pattern: 7 dark modules in the first row, shifted by the margin
+	require.Contains(t, svg, "M2 2h7v1h-7z")
+}
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, DefaultOptions().Validate())
+	require.Error(t, Options{Size: 10, Level: "M"}.Validate())
+	require.Error(t, Options{Size: 256, Level: "X"}.Validate())
+	require.Error(t, Options{Size: 256, Level: "M", Margin: -1}.Validate())
+}
@@ -0,0 +1,111 @@
+// Package safehttp builds HTTP clients for requests to URLs supplied by
+// users, such as link destinations.
+//
+// Such clients refuse to connect to loopback, private and other non-public
+// addresses. The check is done on the resolved address of every connection,
+// redirects included, so a public name pointing to an internal address does
+// not get through either.
+package safehttp
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+const maxRedirects = 5
+
+type BlockedAddressError struct {
+	addr string
+}
+
+func (e *BlockedAddressError) Error() string {
+	return fmt.Sprintf("address %s is not public", e.addr)
+}
+
+type UnsupportedURLError struct {
+	rawURL string
+}
+
+func (e *UnsupportedURLError) Error() string {
+	return fmt.Sprintf("cannot fetch %q: not an http(s) URL", e.rawURL)
+}
+
+// non-public ranges that netip.Addr methods do not cover
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64 may lead to any IPv4 address
+}
+
+// IsPublic reports whether addr is a public unicast address.
+func IsPublic(addr netip.AddrPort) bool {
+	ip := addr.Addr().Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// NewClient returns a client that gives up on a request after timeout and
+// connects only to the addresses allow accepts; nil allow means IsPublic.
+// Only http and https URLs are followed, at most five redirects deep.
+func NewClient(timeout time.Duration, allow func(netip.AddrPort) bool) *http.Client {
+	if allow == nil {
+		allow = IsPublic
+	}
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		// вызывается для уже разрешённого адреса, перед каждым соединением
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addr, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !allow(addr) {
+				return &BlockedAddressError{addr: addr.Addr().String()}
+			}
+			return nil
+		},
+	}
+	transport := &http.Transport{
+		Proxy:                 nil, // через прокси проверка адреса потеряла бы смысл
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       time.Minute,
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return CheckURL(req.URL.String())
+		},
+	}
+}
+
+// CheckURL returns *UnsupportedURLError unless rawURL is an absolute
+// http(s) URL.
+func CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return &UnsupportedURLError{rawURL: rawURL}
+	}
+	return nil
+}
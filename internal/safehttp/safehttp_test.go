@@ -0,0 +1,63 @@
+package safehttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientBlocksPrivateAddresses(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer internal.Close()
+
+	_, err := NewClient(time.Second, nil).Get(internal.URL)
+	require.ErrorAs(t, err, new(*BlockedAddressError))
+
+	// публичный сервер перенаправляет на внутренний
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL, http.StatusFound)
+	}))
+	defer public.Close()
+	publicAddr := netip.MustParseAddrPort(strings.TrimPrefix(public.URL, "http://"))
+	client := NewClient(time.Second, func(addr netip.AddrPort) bool { return addr == publicAddr })
+	_, err = client.Get(public.URL)
+	require.ErrorAs(t, err, new(*BlockedAddressError))
+
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+	}))
+	defer redirect.Close()
+	_, err = NewClient(time.Second, func(netip.AddrPort) bool { return true }).Get(redirect.URL)
+	require.ErrorAs(t, err, new(*UnsupportedURLError))
+}
+
+func TestIsPublic(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"93.184.216.34:80":      true,
+		"[2606:4700::1111]:443": true,
+		"127.0.0.1:80":          false,
+		"10.1.2.3:80":           false,
+		"172.16.0.1:80":         false,
+		"192.168.1.1:80":        false,
+		"169.254.169.254:80":    false, // метаданные облака
+		"100.64.0.1:80":         false,
+		"0.0.0.0:80":            false,
+		"[::1]:80":              false,
+		"[fd00::1]:80":          false,
+		"[fe80::1]:80":          false,
+		"[::ffff:127.0.0.1]:80": false,
+		"[64:ff9b::a00:1]:80":   false,
+		"255.255.255.255:80":    false,
+		"[ff02::1]:80":          false,
+		"[2001:db8::1]:80":      true, // документационный диапазон не опасен
+	} {
+		require.Equal(t, want, IsPublic(netip.MustParseAddrPort(addr)), addr)
+	}
+}
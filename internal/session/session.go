@@ -0,0 +1,117 @@
+// Package session keeps browser sessions of signed in users. The cookie
+// carries only a random session id, everything else stays on the server,
+// so a session can be ended at any moment.
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/auth"
+)
+
+// CookieName is the name of the session cookie.
+const CookieName = "session"
+
+// Session is a signed in browser.
+type Session struct {
+	ID        string
+	Principal auth.Principal
+	ExpiresAt time.Time
+}
+
+// Store keeps sessions in memory.
+type Store struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]*Session
+}
+
+func NewStore(ttl time.Duration) *Store {
+	return &Store{ttl: ttl, now: time.Now, sessions: make(map[string]*Session)}
+}
+
+// New starts a session for p.
+func (s *Store) New(p auth.Principal) (*Session, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for id, sess := range s.sessions {
+		if !now.Before(sess.ExpiresAt) {
+			delete(s.sessions, id)
+		}
+	}
+	sess := &Session{
+		ID:        base64.RawURLEncoding.EncodeToString(b),
+		Principal: p,
+		ExpiresAt: now.Add(s.ttl),
+	}
+	s.sessions[sess.ID] = sess
+	sessCopy := *sess
+	return &sessCopy, nil
+}
+
+// Get returns the live session with the given id.
+func (s *Store) Get(id string) (*Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[id]
+	if !ok {
+		return nil, false
+	}
+	if !s.now().Before(sess.ExpiresAt) {
+		delete(s.sessions, id)
+		return nil, false
+	}
+	sessCopy := *sess
+	return &sessCopy, true
+}
+
+// Delete ends the session.
+func (s *Store) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, id)
+}
+
+// Principal returns the user of the session cookie of r.
+func (s *Store) Principal(r *http.Request) (*auth.Principal, bool) {
+	cookie, err := r.Cookie(CookieName)
+	if err != nil {
+		return nil, false
+	}
+	sess, ok := s.Get(cookie.Value)
+	if !ok {
+		return nil, false
+	}
+	p := sess.Principal
+	p.Scopes = append([]string(nil), p.Scopes...)
+	return &p, true
+}
+
+// Cookie returns the cookie that carries sess. secure should be set when
+// the service is reached over HTTPS.
+func Cookie(sess *Session, secure bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     CookieName,
+		Value:    sess.ID,
+		Path:     "/",
+		Expires:  sess.ExpiresAt,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
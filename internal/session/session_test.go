@@ -0,0 +1,39 @@
+package session
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/auth"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store := NewStore(time.Hour)
+	store.now = func() time.Time { return now }
+
+	sess, err := store.New(auth.Principal{UserID: "u1", Scopes: []string{auth.ScopeRead}})
+	require.NoError(t, err)
+	require.Len(t, sess.ID, 43)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(Cookie(sess, false))
+	p, ok := store.Principal(req)
+	require.True(t, ok)
+	require.Equal(t, "u1", p.UserID)
+
+	now = now.Add(time.Hour)
+	_, ok = store.Principal(req)
+	require.False(t, ok, "expired session")
+
+	sess, err = store.New(auth.Principal{UserID: "u2"})
+	require.NoError(t, err)
+	store.Delete(sess.ID)
+	_, ok = store.Get(sess.ID)
+	require.False(t, ok)
+
+	_, ok = store.Principal(httptest.NewRequest("GET", "/", nil))
+	require.False(t, ok)
+}
@@ -2,20 +2,165 @@ package storage
 
 import (
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/adettelle/go-url-shortener/internal/targeting"
 	"golang.org/x/exp/rand"
 )
 
 const charSet = "aAbBcCdDeEfFgGhHiIjJkKlLmMnNoOpPqQrRsStTuUvVwWxXyYzZ"
 
+// Link is a short link together with its settings.
+type Link struct {
+	ID           string
+	Domain       string // namespace of the link, see domain.Domain.Name; empty for the default domain
+	URL          string
+	Title        string // human readable name of the link; empty if unknown
+	CreatedAt    time.Time
+	PasswordHash string // bcrypt hash; empty for links without a password
+
+	MaxClicks       int // how many redirects are allowed; 0 means unlimited
+	RemainingClicks int // redirects left when MaxClicks is set
+
+	NotBefore time.Time // link is not active before this moment; zero means no limit
+	NotAfter  time.Time // link expires at this moment; zero means no limit
+
+	RedirectType int // HTTP status of the redirect (301, 302, 307, 308); 0 means server default
+
+	Targets []targeting.Rule // alternative destinations by device, language and country; first match wins
+
+	Variants []Variant // weighted A/B destinations used instead of URL when no target matches
+
+	Query QueryOptions // how the query of the short link request reaches the destination
+
+	CreatedBy string // who created the link, see Revision.Actor
+	Workspace string // id of the workspace sharing the link; empty for personal links
+
+	Tags     []string   // labels for finding the link, lowercase and unique
+	Folder   string     // slash separated path like "marketing/2024"; empty for the root
+	Revision int        // incremented on every change of URL
+	History  []Revision // all destinations of the link, oldest first
+
+	Page PageMeta // what the destination page says about itself, see SetPageMeta
+
+	Checks      []CheckResult // recent checks of the destination, oldest first, see AddCheck
+	BrokenSince time.Time     // when the destination stopped working; zero while it works
+}
+
+// CheckResult is the outcome of one request to the destination.
+type CheckResult struct {
+	Time   time.Time
+	Status int    // HTTP status; 0 if there was no response
+	Error  string // why there was no response
+	Broken bool
+}
+
+const (
+	// BrokenAfter failed checks in a row make a link broken, a single
+	// timeout does not.
+	BrokenAfter = 2
+	maxChecks   = 20
+)
+
+// PageMeta is the metadata of the destination page. It is fetched in the
+// background, so a new link has none yet.
+type PageMeta struct {
+	Title       string
+	Description string
+	Favicon     string // absolute URL
+	Image       string // absolute URL of the Open Graph image
+	FetchedAt   time.Time
+	Error       string // why the last fetch failed; empty if it succeeded
+}
+
+// Variant is one of the destinations of an A/B split.
+type Variant struct {
+	Name   string
+	URL    string
+	Weight int // relative share of visitors
+}
+
+// QueryOptions controls the query string of the destination.
+type QueryOptions struct {
+	Passthrough bool              // append the visitor's query parameters
+	UTM         map[string]string // utm_* parameters added unless the destination has them
+	VisitorWins bool              // visitor parameters replace the link's ones with the same key
+}
+
+// Revision is one version of the link destination.
+type Revision struct {
+	Rev        int
+	URL        string
+	Time       time.Time
+	Actor      string // user or API key that made the change
+	RollbackOf int    // revision restored by a rollback; 0 for regular edits
+}
+
+// Key identifies a link across all domains. Storage methods taking a name
+// expect a key; for links on the default domain it is just the id.
+func Key(domain, id string) string {
+	if domain == "" {
+		return id
+	}
+	return domain + "/" + id
+}
+
+// Key returns the key of the link, see Key.
+func (l *Link) Key() string {
+	return Key(l.Domain, l.ID)
+}
+
+// clone returns a copy of the link that shares no memory with it.
+func (l *Link) clone() *Link {
+	linkCopy := *l
+	linkCopy.History = append([]Revision(nil), l.History...)
+	linkCopy.Targets = append([]targeting.Rule(nil), l.Targets...)
+	linkCopy.Variants = append([]Variant(nil), l.Variants...)
+	linkCopy.Tags = append([]string(nil), l.Tags...)
+	linkCopy.Checks = append([]CheckResult(nil), l.Checks...)
+	if l.Query.UTM != nil {
+		linkCopy.Query.UTM = make(map[string]string, len(l.Query.UTM))
+		for k, v := range l.Query.UTM {
+			linkCopy.Query.UTM[k] = v
+		}
+	}
+	return &linkCopy
+}
+
 type AddressStorage struct {
-	Addresses map[string]string
+	mu     sync.RWMutex
+	links  map[string]*Link
+	tags   map[string]map[string]struct{} // индекс: тег -> ключи ссылок с этим тегом
+	search *searchIndex
 }
 
 func New() *AddressStorage {
-	addresses := make(map[string]string)
-	return &AddressStorage{Addresses: addresses}
+	links := make(map[string]*Link)
+	return &AddressStorage{links: links, tags: make(map[string]map[string]struct{}), search: newSearchIndex()}
+}
+
+func (a *AddressStorage) indexTags(link *Link) {
+	for _, tag := range link.Tags {
+		keys, ok := a.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			a.tags[tag] = keys
+		}
+		keys[link.Key()] = struct{}{}
+	}
+}
+
+func (a *AddressStorage) unindexTags(link *Link) {
+	for _, tag := range link.Tags {
+		delete(a.tags[tag], link.Key())
+		if len(a.tags[tag]) == 0 {
+			delete(a.tags, tag)
+		}
+	}
 }
 
 type NoEntryError struct {
@@ -28,15 +173,108 @@ func (e *NoEntryError) Error() string {
 
 // возращает полный url по ключу (короткому url)
 func (a *AddressStorage) GetAddress(name string) (string, error) {
-	if addr, ok := a.Addresses[name]; ok {
-		return addr, nil
+	link, err := a.GetLink(name)
+	if err != nil {
+		return "", err
 	}
+	return link.URL, nil
+}
+
+// GetLink returns a copy of the link stored under name, see Key.
+func (a *AddressStorage) GetLink(name string) (*Link, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
 
-	return "", &NoEntryError{
+	if link, ok := a.links[name]; ok {
+		return link.clone(), nil
+	}
+
+	return nil, &NoEntryError{
 		name: name,
 	}
 }
 
+// LinkFilter selects links for ListLinks.
+type LinkFilter struct {
+	Workspace string   // links of this workspace; empty selects personal links
+	CreatedBy string   // only links created by this actor; empty means anyone
+	Tags      []string // only links having all of these tags
+	Folder    string   // only links in this folder or its subfolders; empty means any folder
+	Broken    bool     // only links with a broken destination
+}
+
+func (f LinkFilter) match(link *Link) bool {
+	if link.Workspace != f.Workspace || (f.CreatedBy != "" && link.CreatedBy != f.CreatedBy) {
+		return false
+	}
+	if f.Folder != "" && link.Folder != f.Folder && !strings.HasPrefix(link.Folder, f.Folder+"/") {
+		return false
+	}
+	if f.Broken && link.BrokenSince.IsZero() {
+		return false
+	}
+	for _, tag := range f.Tags {
+		if !slices.Contains(link.Tags, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// candidates returns the links that may match filter. With tags in the
+// filter only the links having the rarest of them are looked at.
+func (a *AddressStorage) candidates(filter LinkFilter) []*Link {
+	if len(filter.Tags) == 0 {
+		links := make([]*Link, 0, len(a.links))
+		for _, link := range a.links {
+			links = append(links, link)
+		}
+		return links
+	}
+
+	rarest := a.tags[filter.Tags[0]]
+	for _, tag := range filter.Tags[1:] {
+		if len(a.tags[tag]) < len(rarest) {
+			rarest = a.tags[tag]
+		}
+	}
+	links := make([]*Link, 0, len(rarest))
+	for key := range rarest {
+		links = append(links, a.links[key])
+	}
+	return links
+}
+
+// ListLinks returns copies of the links matching filter, oldest first.
+func (a *AddressStorage) ListLinks(filter LinkFilter) ([]*Link, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var links []*Link
+	for _, link := range a.candidates(filter) {
+		if filter.match(link) {
+			links = append(links, link.clone())
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if !links[i].CreatedAt.Equal(links[j].CreatedAt) {
+			return links[i].CreatedAt.Before(links[j].CreatedAt)
+		}
+		return links[i].ID < links[j].ID
+	})
+	return links, nil
+}
+
+// AliasTakenError is returned when a link with the requested id already
+// exists on the domain.
+type AliasTakenError struct {
+	name string
+}
+
+func (e *AliasTakenError) Error() string {
+	return fmt.Sprintf("Name %s is already taken", e.name)
+}
+
 type EmptyAddressError struct{}
 
 func (e *EmptyAddressError) Error() string {
@@ -44,22 +282,383 @@ func (e *EmptyAddressError) Error() string {
 }
 
 func (a *AddressStorage) AddAddress(fullAddress string) (string, error) {
-	if fullAddress == "" {
+	return a.AddLink(Link{URL: fullAddress})
+}
+
+// AddLink stores link and returns its id. Links without an ID get a new
+// random one, an ID chosen by the caller must be free on link.Domain.
+// CreatedAt of link is filled in by the storage.
+func (a *AddressStorage) AddLink(link Link) (string, error) {
+	if link.URL == "" {
 		return "", &EmptyAddressError{}
 	}
-	rangeStart := 2
-	rangeEnd := 10
-	offset := rangeEnd - rangeStart
-	randLength := seededRand.Intn(offset) + rangeStart
 
-	randString, err := stringWithCharset(randLength, charSet)
-	if err != nil {
-		return "", err
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if link.ID != "" {
+		if _, exists := a.links[link.Key()]; exists {
+			return "", &AliasTakenError{name: link.Key()}
+		}
 	}
+	for link.ID == "" {
+		rangeStart := 2
+		rangeEnd := 10
+		offset := rangeEnd - rangeStart
+		randLength := seededRand.Intn(offset) + rangeStart
+
+		randString, err := stringWithCharset(randLength, charSet)
+		if err != nil {
+			return "", err
+		}
+		// короткие имена могут совпасть, перезаписывать чужую ссылку нельзя
+		if _, exists := a.links[Key(link.Domain, randString)]; !exists {
+			link.ID = randString
+		}
+	}
+
+	link.CreatedAt = time.Now()
+	link.RemainingClicks = link.MaxClicks
+	link.Revision = 1
+	link.History = []Revision{{Rev: 1, URL: link.URL, Time: link.CreatedAt, Actor: link.CreatedBy}}
+	a.links[link.Key()] = &link
+	a.indexTags(&link)
+	a.search.add(&link)
+
+	return link.ID, nil
+}
+
+type ClicksExhaustedError struct {
+	name string
+}
+
+func (e *ClicksExhaustedError) Error() string {
+	return fmt.Sprintf("No clicks left for name %s", e.name)
+}
 
-	a.Addresses[randString] = fullAddress
+// UseClick atomically spends one of the remaining redirects of a link with
+// a click limit. It returns *ClicksExhaustedError when none are left.
+// Links without a limit are not affected.
+func (a *AddressStorage) UseClick(name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-	return randString, nil
+	link, ok := a.links[name]
+	if !ok {
+		return &NoEntryError{name: name}
+	}
+	if link.MaxClicks == 0 {
+		return nil
+	}
+	if link.RemainingClicks <= 0 {
+		return &ClicksExhaustedError{name: name}
+	}
+	link.RemainingClicks--
+	return nil
+}
+
+// DeleteAddress removes the link, its id may be issued again later.
+func (a *AddressStorage) DeleteAddress(name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	link, ok := a.links[name]
+	if !ok {
+		return &NoEntryError{name: name}
+	}
+	a.unindexTags(link)
+	a.search.remove(name)
+	delete(a.links, name)
+	return nil
+}
+
+type RevisionMismatchError struct {
+	name     string
+	current  int
+	expected int
+}
+
+func (e *RevisionMismatchError) Error() string {
+	return fmt.Sprintf("Revision of %s is %d, not %d", e.name, e.current, e.expected)
+}
+
+// UpdateAddress changes the destination of a link if its current revision
+// is ifRevision, which gives callers optimistic concurrency control.
+// The previous destination stays in the link history.
+func (a *AddressStorage) UpdateAddress(name, fullAddress, actor string, ifRevision int) (*Link, error) {
+	if fullAddress == "" {
+		return nil, &EmptyAddressError{}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	link, ok := a.links[name]
+	if !ok {
+		return nil, &NoEntryError{name: name}
+	}
+	if link.Revision != ifRevision {
+		return nil, &RevisionMismatchError{name: name, current: link.Revision, expected: ifRevision}
+	}
+
+	link.addRevision(Revision{URL: fullAddress, Actor: actor})
+	a.search.add(link)
+	return link.clone(), nil
+}
+
+type NoRevisionError struct {
+	name string
+	rev  int
+}
+
+func (e *NoRevisionError) Error() string {
+	return fmt.Sprintf("No revision %d for name %s", e.rev, e.name)
+}
+
+// RollbackAddress restores the destination the link had in revision rev.
+// History is append-only: the rollback itself becomes a new revision.
+// Unless ifRevision is 0, the current revision must be ifRevision, as in
+// UpdateAddress.
+func (a *AddressStorage) RollbackAddress(name string, rev int, actor string, ifRevision int) (*Link, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	link, ok := a.links[name]
+	if !ok {
+		return nil, &NoEntryError{name: name}
+	}
+	if ifRevision != 0 && link.Revision != ifRevision {
+		return nil, &RevisionMismatchError{name: name, current: link.Revision, expected: ifRevision}
+	}
+	if rev < 1 || rev > len(link.History) {
+		return nil, &NoRevisionError{name: name, rev: rev}
+	}
+
+	// ревизии нумеруются с 1 и хранятся по порядку
+	link.addRevision(Revision{URL: link.History[rev-1].URL, Actor: actor, RollbackOf: rev})
+	a.search.add(link)
+	return link.clone(), nil
+}
+
+// SetPageMeta stores the metadata fetched from rawURL. If the destination
+// of the link has changed since, the metadata is outdated and ignored.
+// A failed fetch, meta.Error set, keeps what was fetched before.
+func (a *AddressStorage) SetPageMeta(name, rawURL string, meta PageMeta) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	link, ok := a.links[name]
+	if !ok {
+		return &NoEntryError{name: name}
+	}
+	if link.URL != rawURL {
+		return nil
+	}
+	if meta.Error != "" {
+		link.Page.FetchedAt = meta.FetchedAt
+		link.Page.Error = meta.Error
+		return nil
+	}
+	link.Page = meta
+	a.search.add(link)
+	return nil
+}
+
+// StalePages returns up to limit links whose page metadata was fetched
+// before the given moment or never, least recently fetched first.
+func (a *AddressStorage) StalePages(before time.Time, limit int) ([]*Link, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var links []*Link
+	for _, link := range a.links {
+		if link.Page.FetchedAt.Before(before) {
+			links = append(links, link)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].Page.FetchedAt.Before(links[j].Page.FetchedAt)
+	})
+	if limit > 0 && len(links) > limit {
+		links = links[:limit]
+	}
+	for i, link := range links {
+		links[i] = link.clone()
+	}
+	return links, nil
+}
+
+// lastChecked returns when the destination was checked last, zero if never.
+func (l *Link) lastChecked() time.Time {
+	if len(l.Checks) == 0 {
+		return time.Time{}
+	}
+	return l.Checks[len(l.Checks)-1].Time
+}
+
+// DueChecks returns up to limit links whose destination was last checked
+// before the given moment or never, least recently checked first.
+func (a *AddressStorage) DueChecks(before time.Time, limit int) ([]*Link, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var links []*Link
+	for _, link := range a.links {
+		if link.lastChecked().Before(before) {
+			links = append(links, link)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].lastChecked().Before(links[j].lastChecked())
+	})
+	if limit > 0 && len(links) > limit {
+		links = links[:limit]
+	}
+	for i, link := range links {
+		links[i] = link.clone()
+	}
+	return links, nil
+}
+
+// AddCheck appends the result of checking rawURL to the history of the
+// link, keeping the last 20 results. After BrokenAfter failed checks in a
+// row the link becomes broken, a successful check repairs it. If the
+// destination of the link has changed since, the result is ignored.
+// AddCheck returns the updated link.
+func (a *AddressStorage) AddCheck(name, rawURL string, check CheckResult) (*Link, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	link, ok := a.links[name]
+	if !ok {
+		return nil, &NoEntryError{name: name}
+	}
+	if link.URL != rawURL {
+		return link.clone(), nil
+	}
+	link.Checks = append(link.Checks, check)
+	if len(link.Checks) > maxChecks {
+		link.Checks = append([]CheckResult(nil), link.Checks[len(link.Checks)-maxChecks:]...)
+	}
+
+	if !check.Broken {
+		link.BrokenSince = time.Time{}
+		return link.clone(), nil
+	}
+	if !link.BrokenSince.IsZero() || len(link.Checks) < BrokenAfter {
+		return link.clone(), nil
+	}
+	failed := link.Checks[len(link.Checks)-BrokenAfter:]
+	for _, c := range failed {
+		if !c.Broken {
+			return link.clone(), nil
+		}
+	}
+	// ссылка сломана с первой неудачной проверки подряд
+	link.BrokenSince = failed[0].Time
+	return link.clone(), nil
+}
+
+// SetLabels replaces the tags and the folder of a link. Labels are not
+// part of the link history.
+func (a *AddressStorage) SetLabels(name string, tags []string, folder string) (*Link, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	link, ok := a.links[name]
+	if !ok {
+		return nil, &NoEntryError{name: name}
+	}
+	a.unindexTags(link)
+	link.Tags = append([]string(nil), tags...)
+	link.Folder = folder
+	a.indexTags(link)
+	a.search.add(link)
+	return link.clone(), nil
+}
+
+// TagCount is the number of links having a tag.
+type TagCount struct {
+	Tag   string
+	Links int
+}
+
+// ListTags counts the tags of the links matching filter, most used first.
+func (a *AddressStorage) ListTags(filter LinkFilter) ([]TagCount, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	counts := make([]TagCount, 0, len(a.tags))
+	for tag, keys := range a.tags {
+		n := 0
+		for key := range keys {
+			if filter.match(a.links[key]) {
+				n++
+			}
+		}
+		if n > 0 {
+			counts = append(counts, TagCount{Tag: tag, Links: n})
+		}
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Links != counts[j].Links {
+			return counts[i].Links > counts[j].Links
+		}
+		return counts[i].Tag < counts[j].Tag
+	})
+	return counts, nil
+}
+
+// RenameTags replaces the tags from with the tag to on the links matching
+// filter, which merges tags when from has several of them or to is already
+// used. It returns the number of changed links.
+func (a *AddressStorage) RenameTags(filter LinkFilter, from []string, to string) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// сначала собираем ссылки, индекс меняется по ходу переименования
+	changed := make(map[string]*Link)
+	for _, tag := range from {
+		if tag == to {
+			continue
+		}
+		for key := range a.tags[tag] {
+			if link := a.links[key]; filter.match(link) {
+				changed[key] = link
+			}
+		}
+	}
+
+	for _, link := range changed {
+		a.unindexTags(link)
+		tags := make([]string, 0, len(link.Tags))
+		for _, tag := range link.Tags {
+			if slices.Contains(from, tag) {
+				tag = to
+			}
+			if !slices.Contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+		link.Tags = tags
+		a.indexTags(link)
+		a.search.add(link)
+	}
+	return len(changed), nil
+}
+
+func (l *Link) addRevision(rev Revision) {
+	if rev.URL != l.URL {
+		// метаданные и проверки относились к прежней странице
+		l.Page = PageMeta{}
+		l.Checks = nil
+		l.BrokenSince = time.Time{}
+	}
+	l.Revision++
+	l.URL = rev.URL
+	rev.Rev = l.Revision
+	rev.Time = time.Now()
+	l.History = append(l.History, rev)
 }
 
 type InvalidLengthError struct{}
@@ -1,9 +1,13 @@
 package storage
 
 import (
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/adettelle/go-url-shortener/internal/logger"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
@@ -92,3 +96,298 @@ func TestStringWithCharsetInvalidCharSet(t *testing.T) {
 	require.Equal(t, err, &InvalidCharSetError{})
 	require.Empty(t, newStr)
 }
+
+func TestAddLink(t *testing.T) {
+	addressStorage := New()
+
+	name, err := addressStorage.AddLink(Link{URL: "http://localhost:8080/", PasswordHash: "hash"})
+	require.NoError(t, err)
+
+	link, err := addressStorage.GetLink(name)
+	require.NoError(t, err)
+	require.Equal(t, name, link.ID)
+	require.Equal(t, "http://localhost:8080/", link.URL)
+	require.Equal(t, "hash", link.PasswordHash)
+	require.False(t, link.CreatedAt.IsZero())
+
+	// изменение копии не меняет хранилище
+	link.URL = "changed"
+	fullAddress, err := addressStorage.GetAddress(name)
+	require.NoError(t, err)
+	require.Equal(t, "http://localhost:8080/", fullAddress)
+}
+
+func TestUseClickConcurrent(t *testing.T) {
+	addressStorage := New()
+
+	name, err := addressStorage.AddLink(Link{URL: "http://localhost:8080/", MaxClicks: 10})
+	require.NoError(t, err)
+
+	var succeeded, exhausted atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := addressStorage.UseClick(name)
+			if err == nil {
+				succeeded.Add(1)
+				return
+			}
+			assert.Equal(t, &ClicksExhaustedError{name: name}, err)
+			exhausted.Add(1)
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, int32(10), succeeded.Load())
+	require.Equal(t, int32(90), exhausted.Load())
+
+	link, err := addressStorage.GetLink(name)
+	require.NoError(t, err)
+	require.Equal(t, 0, link.RemainingClicks)
+}
+
+func TestUseClickUnlimited(t *testing.T) {
+	addressStorage := New()
+
+	name, err := addressStorage.AddAddress("http://localhost:8080/")
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, addressStorage.UseClick(name))
+	}
+
+	require.Equal(t, &NoEntryError{name: "aaa"}, addressStorage.UseClick("aaa"))
+}
+
+func TestUpdateAddress(t *testing.T) {
+	addressStorage := New()
+
+	name, err := addressStorage.AddAddress("http://localhost:8080/old")
+	require.NoError(t, err)
+
+	link, err := addressStorage.UpdateAddress(name, "http://localhost:8080/new", "alice", 1)
+	require.NoError(t, err)
+	require.Equal(t, 2, link.Revision)
+	require.Equal(t, "http://localhost:8080/new", link.URL)
+	require.Len(t, link.History, 2)
+	require.Equal(t, "http://localhost:8080/old", link.History[0].URL)
+	require.Equal(t, "http://localhost:8080/new", link.History[1].URL)
+
+	_, err = addressStorage.UpdateAddress(name, "http://localhost:8080/other", "bob", 1)
+	require.Equal(t, &RevisionMismatchError{name: name, current: 2, expected: 1}, err)
+
+	_, err = addressStorage.UpdateAddress("aaa", "http://localhost:8080/", "bob", 1)
+	require.Equal(t, &NoEntryError{name: "aaa"}, err)
+}
+
+func TestRollbackAddress(t *testing.T) {
+	addressStorage := New()
+
+	name, err := addressStorage.AddLink(Link{URL: "http://localhost:8080/v1", CreatedBy: "alice"})
+	require.NoError(t, err)
+	_, err = addressStorage.UpdateAddress(name, "http://localhost:8080/v2", "bob", 1)
+	require.NoError(t, err)
+
+	// ссылку успели изменить после того, как клиент прочитал ревизию 1
+	_, err = addressStorage.RollbackAddress(name, 1, "carol", 1)
+	require.Equal(t, &RevisionMismatchError{name: name, current: 2, expected: 1}, err)
+
+	link, err := addressStorage.RollbackAddress(name, 1, "carol", 2)
+	require.NoError(t, err)
+	require.Equal(t, 3, link.Revision)
+	require.Equal(t, "http://localhost:8080/v1", link.URL)
+
+	require.Len(t, link.History, 3)
+	require.Equal(t, "alice", link.History[0].Actor)
+	require.Equal(t, "bob", link.History[1].Actor)
+	require.Equal(t, Revision{Rev: 3, URL: "http://localhost:8080/v1", Time: link.History[2].Time, Actor: "carol", RollbackOf: 1},
+		link.History[2])
+
+	_, err = addressStorage.RollbackAddress(name, 4, "carol", 0)
+	require.Equal(t, &NoRevisionError{name: name, rev: 4}, err)
+}
+
+func TestAddLinkAliasPerDomain(t *testing.T) {
+	addressStorage := New()
+
+	_, err := addressStorage.AddLink(Link{ID: "sale", URL: "http://brand-a.com/sale"})
+	require.NoError(t, err)
+	name, err := addressStorage.AddLink(Link{ID: "sale", Domain: "brand-b.link", URL: "http://brand-b.com/sale"})
+	require.NoError(t, err)
+	require.Equal(t, "sale", name)
+
+	_, err = addressStorage.AddLink(Link{ID: "sale", Domain: "brand-b.link", URL: "http://brand-b.com/other"})
+	require.Equal(t, &AliasTakenError{name: "brand-b.link/sale"}, err)
+
+	address, err := addressStorage.GetAddress("sale")
+	require.NoError(t, err)
+	require.Equal(t, "http://brand-a.com/sale", address)
+	link, err := addressStorage.GetLink(Key("brand-b.link", "sale"))
+	require.NoError(t, err)
+	require.Equal(t, "http://brand-b.com/sale", link.URL)
+	require.Equal(t, "brand-b.link/sale", link.Key())
+}
+
+func TestListLinks(t *testing.T) {
+	addressStorage := New()
+
+	personal, err := addressStorage.AddLink(Link{URL: "http://localhost:8080/1", CreatedBy: "alice"})
+	require.NoError(t, err)
+	_, err = addressStorage.AddLink(Link{URL: "http://localhost:8080/2", CreatedBy: "bob"})
+	require.NoError(t, err)
+	shared1, err := addressStorage.AddLink(Link{URL: "http://localhost:8080/3", CreatedBy: "alice", Workspace: "ws_1"})
+	require.NoError(t, err)
+	shared2, err := addressStorage.AddLink(Link{URL: "http://localhost:8080/4", CreatedBy: "bob", Workspace: "ws_1"})
+	require.NoError(t, err)
+	_, err = addressStorage.AddLink(Link{URL: "http://localhost:8080/5", CreatedBy: "bob", Workspace: "ws_2"})
+	require.NoError(t, err)
+
+	links, err := addressStorage.ListLinks(LinkFilter{CreatedBy: "alice"})
+	require.NoError(t, err)
+	require.Equal(t, []string{personal}, linkIDs(links))
+
+	links, err = addressStorage.ListLinks(LinkFilter{Workspace: "ws_1"})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{shared1, shared2}, linkIDs(links))
+
+	links, err = addressStorage.ListLinks(LinkFilter{Workspace: "ws_1", CreatedBy: "bob"})
+	require.NoError(t, err)
+	require.Equal(t, []string{shared2}, linkIDs(links))
+}
+
+func linkIDs(links []*Link) []string {
+	var ids []string
+	for _, link := range links {
+		ids = append(ids, link.ID)
+	}
+	return ids
+}
+
+func TestTags(t *testing.T) {
+	addressStorage := New()
+
+	promo, err := addressStorage.AddLink(Link{URL: "http://localhost:8080/1", CreatedBy: "alice",
+		Tags: []string{"promo", "spring"}, Folder: "marketing/2024"})
+	require.NoError(t, err)
+	sale, err := addressStorage.AddLink(Link{URL: "http://localhost:8080/2", CreatedBy: "alice",
+		Tags: []string{"sale", "spring"}, Folder: "marketing"})
+	require.NoError(t, err)
+	_, err = addressStorage.AddLink(Link{URL: "http://localhost:8080/3", CreatedBy: "bob", Tags: []string{"spring"}})
+	require.NoError(t, err)
+
+	links, err := addressStorage.ListLinks(LinkFilter{CreatedBy: "alice", Tags: []string{"spring"}})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{promo, sale}, linkIDs(links))
+	links, err = addressStorage.ListLinks(LinkFilter{CreatedBy: "alice", Tags: []string{"spring", "promo"}})
+	require.NoError(t, err)
+	require.Equal(t, []string{promo}, linkIDs(links))
+	links, err = addressStorage.ListLinks(LinkFilter{CreatedBy: "alice", Folder: "marketing"})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{promo, sale}, linkIDs(links))
+	links, err = addressStorage.ListLinks(LinkFilter{CreatedBy: "alice", Folder: "market"})
+	require.NoError(t, err)
+	require.Empty(t, links)
+
+	// слияние promo и sale в campaign затрагивает только ссылки alice
+	n, err := addressStorage.RenameTags(LinkFilter{CreatedBy: "alice"}, []string{"promo", "sale"}, "campaign")
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	tags, err := addressStorage.ListTags(LinkFilter{CreatedBy: "alice"})
+	require.NoError(t, err)
+	require.Equal(t, []TagCount{{Tag: "campaign", Links: 2}, {Tag: "spring", Links: 2}}, tags)
+
+	n, err = addressStorage.RenameTags(LinkFilter{CreatedBy: "alice"}, []string{"campaign"}, "spring")
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	link, err := addressStorage.GetLink(promo)
+	require.NoError(t, err)
+	require.Equal(t, []string{"spring"}, link.Tags)
+
+	link, err = addressStorage.SetLabels(sale, []string{"archive"}, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"archive"}, link.Tags)
+	require.NoError(t, addressStorage.DeleteAddress(promo))
+	tags, err = addressStorage.ListTags(LinkFilter{CreatedBy: "alice"})
+	require.NoError(t, err)
+	require.Equal(t, []TagCount{{Tag: "archive", Links: 1}}, tags)
+	require.Len(t, addressStorage.tags, 2) // archive и spring у ссылки bob
+}
+
+func TestSetPageMeta(t *testing.T) {
+	addressStorage := New()
+
+	name, err := addressStorage.AddLink(Link{URL: "https://example.com/deck"})
+	require.NoError(t, err)
+	stale, err := addressStorage.StalePages(time.Now(), 0)
+	require.NoError(t, err)
+	require.Equal(t, []string{name}, linkIDs(stale))
+
+	fetched := time.Now()
+	err = addressStorage.SetPageMeta(name, "https://example.com/deck", PageMeta{Title: "Q3 deck", FetchedAt: fetched})
+	require.NoError(t, err)
+	stale, err = addressStorage.StalePages(fetched, 0)
+	require.NoError(t, err)
+	require.Empty(t, stale)
+	results, err := addressStorage.SearchLinks(LinkFilter{}, "deck q3", 0)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	// неудачная загрузка не стирает прежние метаданные
+	err = addressStorage.SetPageMeta(name, "https://example.com/deck", PageMeta{FetchedAt: time.Now(), Error: "timeout"})
+	require.NoError(t, err)
+	link, err := addressStorage.GetLink(name)
+	require.NoError(t, err)
+	require.Equal(t, "Q3 deck", link.Page.Title)
+	require.Equal(t, "timeout", link.Page.Error)
+
+	// после смены адреса метаданные старой страницы не нужны
+	_, err = addressStorage.UpdateAddress(name, "https://example.com/other", "alice", 1)
+	require.NoError(t, err)
+	err = addressStorage.SetPageMeta(name, "https://example.com/deck", PageMeta{Title: "Q3 deck", FetchedAt: time.Now()})
+	require.NoError(t, err)
+	link, err = addressStorage.GetLink(name)
+	require.NoError(t, err)
+	require.Equal(t, PageMeta{}, link.Page)
+
+	err = addressStorage.SetPageMeta("aaa", "https://example.com/deck", PageMeta{})
+	require.Equal(t, &NoEntryError{name: "aaa"}, err)
+}
+
+func TestAddCheck(t *testing.T) {
+	addressStorage := New()
+
+	name, err := addressStorage.AddLink(Link{URL: "https://example.com/deck", CreatedBy: "alice"})
+	require.NoError(t, err)
+	_, err = addressStorage.AddLink(Link{URL: "https://example.com/ok", CreatedBy: "alice"})
+	require.NoError(t, err)
+
+	first := time.Now()
+	link, err := addressStorage.AddCheck(name, "https://example.com/deck", CheckResult{Time: first, Status: 404, Broken: true})
+	require.NoError(t, err)
+	require.True(t, link.BrokenSince.IsZero())
+	link, err = addressStorage.AddCheck(name, "https://example.com/deck", CheckResult{Time: first.Add(time.Hour), Status: 404, Broken: true})
+	require.NoError(t, err)
+	require.Equal(t, first, link.BrokenSince)
+
+	links, err := addressStorage.ListLinks(LinkFilter{CreatedBy: "alice", Broken: true})
+	require.NoError(t, err)
+	require.Equal(t, []string{name}, linkIDs(links))
+
+	// результат проверки прежнего адреса не учитывается
+	_, err = addressStorage.UpdateAddress(name, "https://example.com/deck-v2", "alice", 1)
+	require.NoError(t, err)
+	link, err = addressStorage.AddCheck(name, "https://example.com/deck", CheckResult{Time: time.Now(), Status: 404, Broken: true})
+	require.NoError(t, err)
+	require.Empty(t, link.Checks)
+	require.True(t, link.BrokenSince.IsZero())
+
+	for i := 0; i < maxChecks+5; i++ {
+		link, err = addressStorage.AddCheck(name, "https://example.com/deck-v2", CheckResult{Time: time.Now(), Status: 200})
+		require.NoError(t, err)
+	}
+	require.Len(t, link.Checks, maxChecks)
+
+	_, err = addressStorage.AddCheck("aaa", "https://example.com/deck", CheckResult{})
+	require.Equal(t, &NoEntryError{name: "aaa"}, err)
+}
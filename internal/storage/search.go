@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Weights of the fields a term was found in: a hit in the alias says more
+// about the link than a hit somewhere in its URL.
+const (
+	weightAlias = 4
+	weightTitle = 3
+	weightTag   = 3
+	weightURL   = 1
+)
+
+// Discounts of inexact matches.
+const (
+	exactMatch  = 1.0
+	prefixMatch = 0.6
+	fuzzyMatch  = 0.3
+
+	minPrefixLength = 2 // короткие префиксы совпадают почти со всем
+	minFuzzyLength  = 4
+)
+
+// URL parts that are in nearly every link and only add noise.
+var urlStopWords = map[string]bool{"http": true, "https": true, "www": true}
+
+// SearchResult is a link found by SearchLinks.
+type SearchResult struct {
+	Link  *Link
+	Score float64
+}
+
+// searchIndex is an inverted index from terms to the links containing them.
+// It is not safe for concurrent use, AddressStorage guards it with its mutex.
+type searchIndex struct {
+	postings map[string]map[string]float64 // term -> key -> weight of the best field
+	terms    map[string][]string           // key -> terms, to remove a link
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		postings: make(map[string]map[string]float64),
+		terms:    make(map[string][]string),
+	}
+}
+
+// add indexes link, replacing what was indexed for it before.
+func (idx *searchIndex) add(link *Link) {
+	key := link.Key()
+	idx.remove(key)
+
+	weights := make(map[string]float64)
+	put := func(weight float64, terms ...string) {
+		for _, term := range terms {
+			if weight > weights[term] {
+				weights[term] = weight
+			}
+		}
+	}
+	put(weightAlias, strings.ToLower(link.ID))
+	put(weightAlias, tokenize(link.ID)...)
+	put(weightTitle, tokenize(link.Title)...)
+	put(weightTitle, tokenize(link.Page.Title)...)
+	for _, tag := range link.Tags {
+		put(weightTag, tag)
+		put(weightTag, tokenize(tag)...)
+	}
+	for _, term := range tokenize(link.URL) {
+		if !urlStopWords[term] {
+			put(weightURL, term)
+		}
+	}
+
+	terms := make([]string, 0, len(weights))
+	for term, weight := range weights {
+		keys, ok := idx.postings[term]
+		if !ok {
+			keys = make(map[string]float64)
+			idx.postings[term] = keys
+		}
+		keys[key] = weight
+		terms = append(terms, term)
+	}
+	idx.terms[key] = terms
+}
+
+func (idx *searchIndex) remove(key string) {
+	for _, term := range idx.terms[key] {
+		delete(idx.postings[term], key)
+		if len(idx.postings[term]) == 0 {
+			delete(idx.postings, term)
+		}
+	}
+	delete(idx.terms, key)
+}
+
+// search scores the links matching every word of query. A word matches
+// a term exactly, as its prefix or, for longer words, with a typo or two.
+func (idx *searchIndex) search(query string) map[string]float64 {
+	words := tokenize(query)
+	if len(words) == 0 {
+		return nil
+	}
+
+	var scores map[string]float64
+	for _, word := range words {
+		best := idx.match(word)
+		if scores == nil {
+			scores = best
+			continue
+		}
+		// ссылка должна подходить под все слова запроса
+		for key, score := range scores {
+			if s, ok := best[key]; ok {
+				scores[key] = score + s
+			} else {
+				delete(scores, key)
+			}
+		}
+	}
+	return scores
+}
+
+// match returns the best score of word in every link it matches.
+func (idx *searchIndex) match(word string) map[string]float64 {
+	best := make(map[string]float64)
+	collect := func(term string, discount float64) {
+		for key, weight := range idx.postings[term] {
+			if s := weight * discount; s > best[key] {
+				best[key] = s
+			}
+		}
+	}
+
+	collect(word, exactMatch)
+	wordLength := utf8.RuneCountInString(word)
+	maxDistance := 0
+	switch {
+	case wordLength >= 8:
+		maxDistance = 2
+	case wordLength >= minFuzzyLength:
+		maxDistance = 1
+	}
+	for term := range idx.postings {
+		switch {
+		case term == word:
+		case wordLength >= minPrefixLength && strings.HasPrefix(term, word):
+			collect(term, prefixMatch)
+		case maxDistance > 0 && withinDistance(word, term, maxDistance):
+			collect(term, fuzzyMatch)
+		}
+	}
+	return best
+}
+
+// tokenize splits s into lowercase words of letters and digits.
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// withinDistance reports whether the Levenshtein distance between a and b
+// is at most maxDist.
+func withinDistance(a, b string, maxDist int) bool {
+	ra, rb := []rune(a), []rune(b)
+	if d := len(ra) - len(rb); d > maxDist || -d > maxDist {
+		return false
+	}
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, cur[j])
+		}
+		// дальше расстояние только растёт
+		if rowMin > maxDist {
+			return false
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)] <= maxDist
+}
+
+// SearchLinks finds the links matching filter and query, best first.
+// Results with equal scores are ordered from newest to oldest.
+// limit <= 0 means no limit.
+func (a *AddressStorage) SearchLinks(filter LinkFilter, query string, limit int) ([]SearchResult, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var results []SearchResult
+	for key, score := range a.search.search(query) {
+		link := a.links[key]
+		if filter.match(link) {
+			results = append(results, SearchResult{Link: link.clone(), Score: score})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		if !results[i].Link.CreatedAt.Equal(results[j].Link.CreatedAt) {
+			return results[i].Link.CreatedAt.After(results[j].Link.CreatedAt)
+		}
+		return results[i].Link.Key() < results[j].Link.Key()
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func resultIDs(results []SearchResult) []string {
+	var ids []string
+	for _, res := range results {
+		ids = append(ids, res.Link.ID)
+	}
+	return ids
+}
+
+func TestSearchLinksRanking(t *testing.T) {
+	addressStorage := New()
+
+	byURL, err := addressStorage.AddLink(Link{URL: "https://golang.org/doc", CreatedBy: "alice"})
+	require.NoError(t, err)
+	byTitle, err := addressStorage.AddLink(Link{URL: "https://example.com/b", Title: "Golang weekly", CreatedBy: "alice"})
+	require.NoError(t, err)
+	byAlias, err := addressStorage.AddLink(Link{ID: "golang", URL: "https://example.com/a", CreatedBy: "alice"})
+	require.NoError(t, err)
+	_, err = addressStorage.AddLink(Link{URL: "https://example.com/c", Title: "Kubernetes guide", Tags: []string{"ops"}, CreatedBy: "alice"})
+	require.NoError(t, err)
+	_, err = addressStorage.AddLink(Link{URL: "https://golang.org/blog", CreatedBy: "bob"})
+	require.NoError(t, err)
+
+	alice := LinkFilter{CreatedBy: "alice"}
+	for query, want := range map[string][]string{
+		"golang":        {byAlias, byTitle, byURL},
+		"GoLang":        {byAlias, byTitle, byURL},
+		"gola":          {byAlias, byTitle, byURL}, // префикс
+		"golang weekly": {byTitle},                 // нужны все слова
+		"golang rust":   nil,
+		"https":         nil,
+		"g":             nil,
+	} {
+		results, err := addressStorage.SearchLinks(alice, query, 0)
+		require.NoError(t, err)
+		require.Equal(t, want, resultIDs(results), query)
+	}
+
+	// опечатка находит ссылку, но ниже точного совпадения
+	results, err := addressStorage.SearchLinks(alice, "kubernets", 0)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, "Kubernetes guide", results[0].Link.Title)
+	exact, err := addressStorage.SearchLinks(alice, "kubernetes", 0)
+	require.NoError(t, err)
+	require.Greater(t, exact[0].Score, results[0].Score)
+
+	results, err = addressStorage.SearchLinks(alice, "ops guide", 0)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+
+	results, err = addressStorage.SearchLinks(alice, "golang", 1)
+	require.NoError(t, err)
+	require.Equal(t, []string{byAlias}, resultIDs(results))
+}
+
+func TestSearchLinksReindex(t *testing.T) {
+	addressStorage := New()
+
+	name, err := addressStorage.AddLink(Link{URL: "https://golang.org/", CreatedBy: "alice"})
+	require.NoError(t, err)
+
+	_, err = addressStorage.UpdateAddress(name, "https://go.dev/", "alice", 1)
+	require.NoError(t, err)
+	results, err := addressStorage.SearchLinks(LinkFilter{}, "golang", 0)
+	require.NoError(t, err)
+	require.Empty(t, results)
+	results, err = addressStorage.SearchLinks(LinkFilter{}, "dev", 0)
+	require.NoError(t, err)
+	require.Equal(t, []string{name}, resultIDs(results))
+
+	_, err = addressStorage.SetLabels(name, []string{"tooling"}, "")
+	require.NoError(t, err)
+	results, err = addressStorage.SearchLinks(LinkFilter{}, "tooling", 0)
+	require.NoError(t, err)
+	require.Equal(t, []string{name}, resultIDs(results))
+
+	_, err = addressStorage.RenameTags(LinkFilter{}, []string{"tooling"}, "tools")
+	require.NoError(t, err)
+	results, err = addressStorage.SearchLinks(LinkFilter{}, "tooling", 0)
+	require.NoError(t, err)
+	require.Empty(t, results)
+
+	require.NoError(t, addressStorage.DeleteAddress(name))
+	results, err = addressStorage.SearchLinks(LinkFilter{}, "dev", 0)
+	require.NoError(t, err)
+	require.Empty(t, results)
+	require.Empty(t, addressStorage.search.postings)
+}
+
+func TestWithinDistance(t *testing.T) {
+	require.True(t, withinDistance("kubernets", "kubernetes", 1))
+	require.True(t, withinDistance("goland", "golang", 1))
+	require.False(t, withinDistance("golnag", "golang", 1))
+	require.True(t, withinDistance("golnag", "golang", 2))
+	require.False(t, withinDistance("go", "golang", 2))
+}
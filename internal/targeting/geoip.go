@@ -0,0 +1,39 @@
+package targeting
+
+import (
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// GeoIPDB resolves countries with a local MaxMind-format database
+// (GeoLite2-Country, GeoIP2-Country, DB-IP and similar).
+type GeoIPDB struct {
+	reader *maxminddb.Reader
+}
+
+// OpenGeoIPDB opens the database file at path.
+func OpenGeoIPDB(path string) (*GeoIPDB, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &GeoIPDB{reader: reader}, nil
+}
+
+// Country returns the ISO code of the country of ip or "" if it is unknown.
+func (db *GeoIPDB) Country(ip net.IP) (string, error) {
+	var record struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+	if err := db.reader.Lookup(ip, &record); err != nil {
+		return "", err
+	}
+	return record.Country.ISOCode, nil
+}
+
+func (db *GeoIPDB) Close() error {
+	return db.reader.Close()
+}
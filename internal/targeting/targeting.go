@@ -0,0 +1,224 @@
+// Package targeting picks the destination of a link depending on the visitor:
+// operating system and device class from User-Agent, preferred language from
+// Accept-Language and country from a GeoIP database.
+package targeting
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Known values of Rule.OS and Rule.Devices.
+var (
+	KnownOS      = []string{"ios", "android", "windows", "macos", "linux", "chromeos"}
+	KnownDevices = []string{"mobile", "tablet", "desktop", "bot"}
+)
+
+// MaxRules limits the number of rules of a single link.
+const MaxRules = 20
+
+// Rule sends visitors matching all of its non-empty conditions to URL.
+// Inside one condition any of the listed values matches.
+type Rule struct {
+	OS        []string // see KnownOS
+	Devices   []string // see KnownDevices
+	Languages []string // language tags; "de" also matches "de-AT"
+	Countries []string // ISO 3166-1 alpha-2 codes
+	URL       string
+}
+
+// Visitor holds the request properties rules are evaluated against.
+type Visitor struct {
+	OS       string
+	Device   string
+	Language string // most preferred language, lower case
+	Country  string // upper case, empty if unknown
+}
+
+// CountryResolver maps an IP address to an ISO country code.
+type CountryResolver interface {
+	Country(ip net.IP) (string, error)
+}
+
+type InvalidRuleError struct {
+	index  int
+	reason string
+}
+
+func (e *InvalidRuleError) Error() string {
+	return fmt.Sprintf("invalid targeting rule %d: %s", e.index, e.reason)
+}
+
+// Validate checks rules and normalizes their values in place.
+func Validate(rules []Rule) error {
+	if len(rules) > MaxRules {
+		return &InvalidRuleError{index: MaxRules, reason: "too many rules"}
+	}
+	for i := range rules {
+		r := &rules[i]
+		if r.URL == "" {
+			return &InvalidRuleError{index: i, reason: "url is empty"}
+		}
+		if len(r.OS)+len(r.Devices)+len(r.Languages)+len(r.Countries) == 0 {
+			return &InvalidRuleError{index: i, reason: "rule has no conditions"}
+		}
+		for j, os := range r.OS {
+			r.OS[j] = strings.ToLower(os)
+			if !contains(KnownOS, r.OS[j]) {
+				return &InvalidRuleError{index: i, reason: "unknown os " + os}
+			}
+		}
+		for j, device := range r.Devices {
+			r.Devices[j] = strings.ToLower(device)
+			if !contains(KnownDevices, r.Devices[j]) {
+				return &InvalidRuleError{index: i, reason: "unknown device " + device}
+			}
+		}
+		for j, lang := range r.Languages {
+			r.Languages[j] = strings.ToLower(lang)
+			if lang == "" {
+				return &InvalidRuleError{index: i, reason: "empty language"}
+			}
+		}
+		for j, country := range r.Countries {
+			r.Countries[j] = strings.ToUpper(country)
+			if len(country) != 2 {
+				return &InvalidRuleError{index: i, reason: "invalid country " + country}
+			}
+		}
+	}
+	return nil
+}
+
+// Resolve returns the URL of the first rule matching v.
+func Resolve(rules []Rule, v Visitor) (string, bool) {
+	for _, r := range rules {
+		if r.matches(v) {
+			return r.URL, true
+		}
+	}
+	return "", false
+}
+
+func (r Rule) matches(v Visitor) bool {
+	if len(r.OS) > 0 && !contains(r.OS, v.OS) {
+		return false
+	}
+	if len(r.Devices) > 0 && !contains(r.Devices, v.Device) {
+		return false
+	}
+	if len(r.Countries) > 0 && !contains(r.Countries, v.Country) {
+		return false
+	}
+	if len(r.Languages) > 0 {
+		matched := false
+		for _, lang := range r.Languages {
+			if v.Language == lang || strings.HasPrefix(v.Language, lang+"-") {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// NewVisitor describes the visitor behind r. clientIP is the already resolved
+// client address; geo may be nil, then the country stays unknown.
+func NewVisitor(r *http.Request, clientIP string, geo CountryResolver) Visitor {
+	v := Visitor{}
+	v.OS, v.Device = ParseUserAgent(r.UserAgent())
+	if langs := ParseAcceptLanguage(r.Header.Get("Accept-Language")); len(langs) > 0 {
+		v.Language = langs[0]
+	}
+	if geo != nil {
+		if ip := net.ParseIP(clientIP); ip != nil {
+			if country, err := geo.Country(ip); err == nil {
+				v.Country = strings.ToUpper(country)
+			}
+		}
+	}
+	return v
+}
+
+// ParseUserAgent returns the operating system and device class of ua.
+// Unknown values are returned as empty strings.
+func ParseUserAgent(ua string) (os, device string) {
+	ua = strings.ToLower(ua)
+	switch {
+	case strings.Contains(ua, "bot") || strings.Contains(ua, "crawler") || strings.Contains(ua, "spider"):
+		return "", "bot"
+	case strings.Contains(ua, "ipad"):
+		return "ios", "tablet"
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipod"):
+		return "ios", "mobile"
+	case strings.Contains(ua, "android"):
+		// планшеты на Android не передают "Mobile"
+		if strings.Contains(ua, "mobile") {
+			return "android", "mobile"
+		}
+		return "android", "tablet"
+	case strings.Contains(ua, "windows phone"):
+		return "windows", "mobile"
+	case strings.Contains(ua, "windows"):
+		return "windows", "desktop"
+	case strings.Contains(ua, "cros"):
+		return "chromeos", "desktop"
+	case strings.Contains(ua, "macintosh") || strings.Contains(ua, "mac os x"):
+		return "macos", "desktop"
+	case strings.Contains(ua, "linux"):
+		return "linux", "desktop"
+	}
+	return "", ""
+}
+
+// ParseAcceptLanguage returns the accepted language tags, most preferred
+// first. Tags with q=0 and the wildcard are skipped.
+func ParseAcceptLanguage(header string) []string {
+	type langQ struct {
+		tag string
+		q   float64
+	}
+	var langs []langQ
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+		langs = append(langs, langQ{tag: tag, q: q})
+	}
+
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].q > langs[j].q })
+	tags := make([]string, 0, len(langs))
+	for _, l := range langs {
+		tags = append(tags, l.tag)
+	}
+	return tags
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package targeting
+
+import (
+	"errors"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	iPhoneUA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1"
+	iPadUA    = "Mozilla/5.0 (iPad; CPU OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1"
+	androidUA = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Mobile Safari/537.36"
+	androidTb = "Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+	windowsUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36"
+	macUA     = "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_2) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15"
+	linuxUA   = "Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0"
+	botUA     = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		ua, os, device string
+	}{
+		{iPhoneUA, "ios", "mobile"},
+		{iPadUA, "ios", "tablet"},
+		{androidUA, "android", "mobile"},
+		{androidTb, "android", "tablet"},
+		{windowsUA, "windows", "desktop"},
+		{macUA, "macos", "desktop"},
+		{linuxUA, "linux", "desktop"},
+		{botUA, "", "bot"},
+		{"curl/8.0", "", ""},
+	}
+	for _, tt := range tests {
+		os, device := ParseUserAgent(tt.ua)
+		require.Equal(t, tt.os, os, tt.ua)
+		require.Equal(t, tt.device, device, tt.ua)
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	require.Equal(t, []string{"de-at", "de", "en"}, ParseAcceptLanguage("de-AT, en;q=0.5, de;q=0.8, *;q=0.1"))
+	require.Equal(t, []string{"fr"}, ParseAcceptLanguage("ru;q=0, fr"))
+	require.Empty(t, ParseAcceptLanguage(""))
+	require.Empty(t, ParseAcceptLanguage("en;q=abc"))
+}
+
+type fakeGeo map[string]string
+
+func (g fakeGeo) Country(ip net.IP) (string, error) {
+	country, ok := g[ip.String()]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return country, nil
+}
+
+func TestResolveWithSyntheticRequests(t *testing.T) {
+	rules := []Rule{
+		{OS: []string{"iOS"}, URL: "https://apps.apple.com/app/id1"},
+		{OS: []string{"android"}, URL: "https://play.google.com/store/apps/details?id=app"},
+		{Countries: []string{"de", "at"}, Languages: []string{"de"}, URL: "https://example.com/de"},
+		{Devices: []string{"bot"}, URL: "https://example.com/bots"},
+	}
+	require.NoError(t, Validate(rules))
+
+	geo := fakeGeo{"203.0.113.1": "DE", "203.0.113.2": "US"}
+
+	tests := []struct {
+		name    string
+		ua      string
+		lang    string
+		ip      string
+		want    string
+		matched bool
+	}{
+		{"iphone", iPhoneUA, "en", "203.0.113.2", "https://apps.apple.com/app/id1", true},
+		{"ipad goes to app store too", iPadUA, "de", "203.0.113.1", "https://apps.apple.com/app/id1", true},
+		{"android", androidUA, "", "", "https://play.google.com/store/apps/details?id=app", true},
+		{"german desktop", windowsUA, "de-DE,en;q=0.5", "203.0.113.1", "https://example.com/de", true},
+		{"english desktop in germany", windowsUA, "en-US,de;q=0.9", "203.0.113.1", "", false},
+		{"german speaker abroad", macUA, "de", "203.0.113.2", "", false},
+		{"unknown country", linuxUA, "de", "198.51.100.1", "", false},
+		{"crawler", botUA, "", "", "https://example.com/bots", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/abc", nil)
+			r.Header.Set("User-Agent", tt.ua)
+			if tt.lang != "" {
+				r.Header.Set("Accept-Language", tt.lang)
+			}
+			url, ok := Resolve(rules, NewVisitor(r, tt.ip, geo))
+			require.Equal(t, tt.matched, ok)
+			require.Equal(t, tt.want, url)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	require.Error(t, Validate([]Rule{{OS: []string{"ios"}}}))
+	require.Error(t, Validate([]Rule{{URL: "https://example.com/"}}))
+	require.Error(t, Validate([]Rule{{OS: []string{"symbian"}, URL: "https://example.com/"}}))
+	require.Error(t, Validate([]Rule{{Devices: []string{"watch"}, URL: "https://example.com/"}}))
+	require.Error(t, Validate([]Rule{{Countries: []string{"DEU"}, URL: "https://example.com/"}}))
+	require.Error(t, Validate(make([]Rule, MaxRules+1)))
+}
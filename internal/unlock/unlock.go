@@ -0,0 +1,133 @@
+// Package unlock supports password-protected links: it issues short-lived
+// signed tokens for visitors who entered the right password and throttles
+// password guessing per link.
+package unlock
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Signer issues and verifies unlock tokens. A token is bound to the link ID
+// and to a fingerprint of the password hash, so changing the password
+// invalidates all tokens issued before.
+type Signer struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// NewSigner creates a Signer. An empty secret is replaced by a random one,
+// tokens then do not survive a restart.
+func NewSigner(secret []byte, ttl time.Duration) *Signer {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(err)
+		}
+	}
+	return &Signer{secret: secret, ttl: ttl, now: time.Now}
+}
+
+// Token returns a token for id valid until the returned time.
+func (s *Signer) Token(id, fingerprint string) (string, time.Time) {
+	expires := s.now().Add(s.ttl)
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return exp + "." + s.sign(id, fingerprint, exp), expires
+}
+
+// Valid reports whether token was issued for id and has not expired.
+func (s *Signer) Valid(token, id, fingerprint string) bool {
+	exp, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || s.now().Unix() >= unix {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(s.sign(id, fingerprint, exp)))
+}
+
+func (s *Signer) sign(id, fingerprint, exp string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(id + "|" + fingerprint + "|" + exp))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Throttle limits password attempts per link: after maxAttempts attempts
+// within window further attempts are rejected until the window ends. An
+// attempt is counted when it is allowed, before the password is checked, so
+// parallel requests can not get past the limit; a correct password resets
+// the count.
+type Throttle struct {
+	maxAttempts int
+	window      time.Duration
+	now         func() time.Time
+
+	mu       sync.Mutex
+	attempts map[string]*attempts
+	cleaned  time.Time // когда истёкшие окна удалялись в последний раз
+}
+
+type attempts struct {
+	count int
+	start time.Time
+}
+
+func NewThrottle(maxAttempts int, window time.Duration) *Throttle {
+	return &Throttle{
+		maxAttempts: maxAttempts,
+		window:      window,
+		now:         time.Now,
+		attempts:    make(map[string]*attempts),
+	}
+}
+
+// Allow reserves an attempt for id. If the limit is reached, it returns
+// false and how long the caller has to wait.
+func (t *Throttle) Allow(id string) (bool, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	t.cleanup(now)
+
+	a, ok := t.attempts[id]
+	if !ok || now.Sub(a.start) >= t.window {
+		a = &attempts{start: now}
+		t.attempts[id] = a
+	}
+	if a.count >= t.maxAttempts {
+		return false, a.start.Add(t.window).Sub(now)
+	}
+	a.count++
+	return true, 0
+}
+
+// Reset forgets the attempts for id after a successful one.
+func (t *Throttle) Reset(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.attempts, id)
+}
+
+// cleanup drops expired windows so that the map does not grow forever. The
+// map is scanned at most once per window.
+func (t *Throttle) cleanup(now time.Time) {
+	if now.Sub(t.cleaned) < t.window {
+		return
+	}
+	t.cleaned = now
+	for id, a := range t.attempts {
+		if now.Sub(a.start) >= t.window {
+			delete(t.attempts, id)
+		}
+	}
+}
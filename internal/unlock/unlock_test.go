@@ -0,0 +1,98 @@
+package unlock
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewSigner([]byte("secret"), 15*time.Minute)
+	s.now = func() time.Time { return now }
+
+	token, expires := s.Token("abc", "fp")
+	require.Equal(t, now.Add(15*time.Minute), expires)
+	require.True(t, s.Valid(token, "abc", "fp"))
+
+	require.False(t, s.Valid(token, "other", "fp"), "token is bound to the link")
+	require.False(t, s.Valid(token, "abc", "new-password"), "password change revokes tokens")
+	require.False(t, s.Valid("garbage", "abc", "fp"))
+	require.False(t, s.Valid(token+"x", "abc", "fp"))
+
+	other := NewSigner([]byte("another secret"), 15*time.Minute)
+	require.False(t, other.Valid(token, "abc", "fp"))
+
+	now = now.Add(15 * time.Minute)
+	require.False(t, s.Valid(token, "abc", "fp"), "token expired")
+}
+
+func TestThrottle(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	th := NewThrottle(3, time.Minute)
+	th.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		ok, _ := th.Allow("abc")
+		require.True(t, ok)
+	}
+
+	now = now.Add(20 * time.Second)
+	ok, wait := th.Allow("abc")
+	require.False(t, ok)
+	require.Equal(t, 40*time.Second, wait)
+
+	ok, _ = th.Allow("other")
+	require.True(t, ok)
+
+	now = now.Add(40 * time.Second)
+	ok, _ = th.Allow("abc")
+	require.True(t, ok)
+
+	// верный пароль обнуляет счётчик
+	ok, _ = th.Allow("abc")
+	require.True(t, ok)
+	th.Reset("abc")
+	for i := 0; i < 3; i++ {
+		ok, _ = th.Allow("abc")
+		require.True(t, ok)
+	}
+}
+
+func TestThrottleCleanup(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	th := NewThrottle(3, time.Minute)
+	th.now = func() time.Time { return now }
+
+	for _, id := range []string{"a", "b", "c"} {
+		ok, _ := th.Allow(id)
+		require.True(t, ok)
+	}
+	require.Len(t, th.attempts, 3)
+
+	now = now.Add(time.Minute)
+	ok, _ := th.Allow("d")
+	require.True(t, ok)
+	require.Len(t, th.attempts, 1)
+}
+
+func TestThrottleParallelAttempts(t *testing.T) {
+	th := NewThrottle(5, time.Minute)
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _ := th.Allow("abc"); ok {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(5), allowed.Load())
+}
@@ -0,0 +1,322 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/events"
+	"github.com/adettelle/go-url-shortener/internal/logger"
+	"github.com/adettelle/go-url-shortener/internal/safehttp"
+	"go.uber.org/zap"
+)
+
+const (
+	userAgent = "go-url-shortener webhooks"
+	// maxPending is how many deliveries may wait for their next attempt;
+	// beyond that new deliveries go straight to the dead-letter list.
+	maxPending = 10000
+	// redeliverQueueSize is how many dead letters may wait to be sent again.
+	redeliverQueueSize = 100
+)
+
+// Headers of a webhook request.
+const (
+	HeaderID        = "Webhook-Id" // the same for every attempt of a delivery
+	HeaderEvent     = "Webhook-Event"
+	HeaderSignature = "Webhook-Signature"
+)
+
+type payload struct {
+	ID    string    `json:"id"`
+	Type  string    `json:"type"`
+	Time  time.Time `json:"time"`
+	Actor string    `json:"actor,omitempty"`
+	Data  any       `json:"data"`
+}
+
+type StatusError struct {
+	code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webhook answered %d", e.code)
+}
+
+type RedeliveryUnavailableError struct{}
+
+func (e *RedeliveryUnavailableError) Error() string {
+	return "webhook redelivery is not available now, try again later"
+}
+
+type InvalidSignatureError struct {
+	reason string
+}
+
+func (e *InvalidSignatureError) Error() string {
+	return "invalid webhook signature: " + e.reason
+}
+
+// Sign returns the Webhook-Signature header of body sent at timestamp:
+// "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>" with secret>".
+// The timestamp is signed too, so a captured request can not be replayed
+// later with a new one.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	t := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + t + ",v1=" + signature(secret, t, body)
+}
+
+// Verify checks the Webhook-Signature header of body and that it was made
+// no longer than tolerance ago. It is what a receiver written in Go needs.
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var t, sig string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			t = value
+		case "v1":
+			sig = value
+		}
+	}
+	unix, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || sig == "" {
+		return &InvalidSignatureError{reason: "malformed header"}
+	}
+	if !hmac.Equal([]byte(sig), []byte(signature(secret, t, body))) {
+		return &InvalidSignatureError{reason: "signature mismatch"}
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return &InvalidSignatureError{reason: "timestamp out of tolerance"}
+	}
+	return nil
+}
+
+func signature(secret, t string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(t))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher sends the events it receives to the subscriptions of its Store.
+type Dispatcher struct {
+	*Store
+
+	client      *http.Client
+	maxAttempts int
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+
+	sending   chan struct{} // ограничивает число одновременных запросов
+	pending   atomic.Int64
+	redeliver chan *Delivery
+	running   atomic.Bool // Run забирает повторные отправки из redeliver
+}
+
+// NewDispatcher creates a Dispatcher that makes up to maxAttempts attempts
+// per delivery, waiting baseDelay after the first failure and twice as long
+// after every next one, but no more than maxDelay. At most concurrency
+// requests are made at once. Subscription URLs come from users, so client
+// should refuse internal addresses, see safehttp.NewClient.
+func NewDispatcher(store *Store, client *http.Client, maxAttempts int, baseDelay, maxDelay time.Duration,
+	concurrency int) *Dispatcher {
+	return &Dispatcher{
+		Store:       store,
+		client:      client,
+		maxAttempts: maxAttempts,
+		baseDelay:   baseDelay,
+		maxDelay:    maxDelay,
+		sending:     make(chan struct{}, concurrency),
+		redeliver:   make(chan *Delivery, redeliverQueueSize),
+	}
+}
+
+// Run delivers the events from in until ctx is cancelled. Deliveries that
+// are still pending then are dropped.
+func (d *Dispatcher) Run(ctx context.Context, in <-chan events.Event) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	d.running.Store(true)
+	defer d.running.Store(false)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-in:
+			d.dispatch(ctx, &wg, e)
+		case delivery := <-d.redeliver:
+			d.start(ctx, &wg, delivery)
+		}
+	}
+}
+
+// Redeliver sends a delivery from the dead-letter list of the subscription
+// again, with a fresh set of attempts. It does not block: if the delivery
+// can not be queued, it stays in the dead-letter list and Redeliver returns
+// a *RedeliveryUnavailableError.
+func (d *Dispatcher) Redeliver(subID, id string) error {
+	delivery, err := d.Store.takeDeadLetter(subID, id)
+	if err != nil {
+		return err
+	}
+	if d.running.Load() {
+		select {
+		case d.redeliver <- delivery:
+			return nil
+		default: // очередь полна
+		}
+	}
+	d.Store.restoreDeadLetter(delivery)
+	return &RedeliveryUnavailableError{}
+}
+
+func (d *Dispatcher) dispatch(ctx context.Context, wg *sync.WaitGroup, e events.Event) {
+	subs := d.Store.matching(e)
+	if len(subs) == 0 {
+		return
+	}
+	body, err := json.Marshal(payload{ID: e.ID, Type: e.Type, Time: e.Time, Actor: e.Actor, Data: e.Data})
+	if err != nil {
+		logger.Logger.Error("error in encoding event", zap.String("event", e.ID), zap.Error(err))
+		return
+	}
+
+	for _, sub := range subs {
+		id, err := randomHex(8)
+		if err != nil {
+			logger.Logger.Error("error in creating delivery", zap.Error(err))
+			return
+		}
+		delivery := &Delivery{
+			ID:             "dl_" + id,
+			SubscriptionID: sub.ID,
+			EventID:        e.ID,
+			EventType:      e.Type,
+			Payload:        body,
+			State:          StatePending,
+			CreatedAt:      time.Now(),
+		}
+		d.Store.addDelivery(delivery)
+		d.start(ctx, wg, delivery)
+	}
+}
+
+func (d *Dispatcher) start(ctx context.Context, wg *sync.WaitGroup, delivery *Delivery) {
+	if d.pending.Add(1) > maxPending {
+		d.pending.Add(-1)
+		d.Store.addAttempt(delivery, Attempt{Time: time.Now(), Error: "too many pending deliveries"}, StateFailed)
+		return
+	}
+	wg.Add(1)
+	go func() {
+		defer func() {
+			d.pending.Add(-1)
+			wg.Done()
+		}()
+		d.deliver(ctx, delivery)
+	}()
+}
+
+// deliver makes the attempts of one delivery. Network errors, timeouts and
+// 408, 429 and 5xx answers are retried; any other answer is final.
+func (d *Dispatcher) deliver(ctx context.Context, delivery *Delivery) {
+	for i := 1; ; i++ {
+		sub, err := d.Store.Get(delivery.SubscriptionID)
+		if err != nil {
+			// подписку удалили, доставлять некому
+			return
+		}
+
+		d.sending <- struct{}{}
+		attempt, err := d.send(ctx, sub, delivery)
+		<-d.sending
+		if ctx.Err() != nil {
+			return
+		}
+
+		var status *StatusError
+		var blocked *safehttp.BlockedAddressError
+		switch {
+		case err == nil:
+			d.Store.addAttempt(delivery, attempt, StateDelivered)
+			return
+		case i >= d.maxAttempts,
+			errors.As(err, &status) && !retryable(status.code),
+			errors.As(err, &blocked):
+			d.Store.addAttempt(delivery, attempt, StateFailed)
+			logger.Logger.Info("webhook delivery failed", zap.String("subscription", sub.ID),
+				zap.String("delivery", delivery.ID), zap.Int("attempts", i), zap.Error(err))
+			return
+		}
+		d.Store.addAttempt(delivery, attempt, StatePending)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d.backoff(i)):
+		}
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, sub *Subscription, delivery *Delivery) (Attempt, error) {
+	attempt := Attempt{Time: time.Now()}
+	err := func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(delivery.Payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", userAgent)
+		req.Header.Set(HeaderID, delivery.ID)
+		req.Header.Set(HeaderEvent, delivery.EventType)
+		req.Header.Set(HeaderSignature, Sign(sub.Secret, attempt.Time, delivery.Payload))
+
+		resp, err := d.client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		_, _ = io.CopyN(io.Discard, resp.Body, 4096)
+		attempt.Status = resp.StatusCode
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return &StatusError{code: resp.StatusCode}
+		}
+		return nil
+	}()
+	attempt.Duration = time.Since(attempt.Time)
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	return attempt, err
+}
+
+// backoff returns the pause after the n-th failed attempt, with a random
+// spread of ±20%, so retries to one receiver do not come in bursts.
+func (d *Dispatcher) backoff(n int) time.Duration {
+	delay := d.baseDelay
+	for i := 1; i < n && delay < d.maxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, d.maxDelay)
+	return delay - delay/5 + rand.N(delay*2/5+1)
+}
+
+func retryable(status int) bool {
+	return status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= 500
+}
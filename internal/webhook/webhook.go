@@ -0,0 +1,322 @@
+// Package webhook sends link events to URLs subscribed to them.
+//
+// Every request carries an HMAC-SHA256 signature of its body made with the
+// secret of the subscription, see Sign. Failed deliveries are retried with
+// exponential backoff; those that still fail end up in the dead-letter list
+// of the subscription, from where they can be sent again. The last
+// deliveries of every subscription are kept with all their attempts.
+package webhook
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/events"
+	"github.com/adettelle/go-url-shortener/internal/safehttp"
+)
+
+const (
+	maxLog  = 100  // сколько последних доставок хранить для журнала
+	maxDead = 1000 // сколько недоставленных событий хранить
+)
+
+// States of a delivery.
+const (
+	StatePending   = "pending"
+	StateDelivered = "delivered"
+	StateFailed    = "failed" // the delivery is in the dead-letter list
+)
+
+// Subscription asks for events of the links of Owner, or of Workspace if
+// it is set, to be posted to URL.
+type Subscription struct {
+	ID        string
+	URL       string
+	Secret    string   // key of the signatures
+	Events    []string // event types; empty means all
+	Owner     string   // who created the subscription
+	Workspace string
+	CreatedAt time.Time
+}
+
+// Wants reports whether e should be sent to the subscription.
+func (s *Subscription) Wants(e events.Event) bool {
+	if len(s.Events) > 0 && !slices.Contains(s.Events, e.Type) {
+		return false
+	}
+	if s.Workspace != "" {
+		return e.Workspace == s.Workspace
+	}
+	return e.Workspace == "" && e.Owner == s.Owner
+}
+
+// Attempt is one request of a delivery.
+type Attempt struct {
+	Time     time.Time
+	Status   int // HTTP status; 0 if there was no response
+	Error    string
+	Duration time.Duration
+}
+
+// Delivery is one event sent to one subscription.
+type Delivery struct {
+	ID             string
+	SubscriptionID string
+	EventID        string
+	EventType      string
+	Payload        []byte
+	State          string
+	Attempts       []Attempt
+	CreatedAt      time.Time
+}
+
+func (d *Delivery) clone() Delivery {
+	c := *d
+	c.Attempts = append([]Attempt(nil), d.Attempts...)
+	return c
+}
+
+type NoSubscriptionError struct {
+	id string
+}
+
+func (e *NoSubscriptionError) Error() string {
+	return fmt.Sprintf("no webhook subscription %s", e.id)
+}
+
+type NoDeliveryError struct {
+	id string
+}
+
+func (e *NoDeliveryError) Error() string {
+	return fmt.Sprintf("no failed delivery %s", e.id)
+}
+
+type InvalidSubscriptionError struct {
+	reason string
+}
+
+func (e *InvalidSubscriptionError) Error() string {
+	return "invalid webhook subscription: " + e.reason
+}
+
+// Store keeps subscriptions and their deliveries in memory.
+type Store struct {
+	mu         sync.Mutex
+	subs       map[string]*Subscription
+	deliveries map[string][]*Delivery // id подписки -> последние доставки, старые первыми
+	dead       map[string][]*Delivery // id подписки -> недоставленные, старые первыми
+}
+
+func NewStore() *Store {
+	return &Store{
+		subs:       make(map[string]*Subscription),
+		deliveries: make(map[string][]*Delivery),
+		dead:       make(map[string][]*Delivery),
+	}
+}
+
+// Create adds a subscription with a new random secret.
+func (s *Store) Create(url string, eventTypes []string, owner, workspace string) (*Subscription, error) {
+	if err := safehttp.CheckURL(url); err != nil {
+		return nil, &InvalidSubscriptionError{reason: err.Error()}
+	}
+	var types []string
+	for _, t := range eventTypes {
+		if !slices.Contains(events.Types, t) {
+			return nil, &InvalidSubscriptionError{reason: fmt.Sprintf("unknown event %q", t)}
+		}
+		if !slices.Contains(types, t) {
+			types = append(types, t)
+		}
+	}
+	id, err := randomHex(6)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &Subscription{
+		ID:        "wh_" + id,
+		URL:       url,
+		Secret:    "whsec_" + secret,
+		Events:    types,
+		Owner:     owner,
+		Workspace: workspace,
+		CreatedAt: time.Now(),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subs[sub.ID] = sub
+	return clone(sub), nil
+}
+
+// Get returns the subscription with the given id.
+func (s *Store) Get(id string) (*Subscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subs[id]
+	if !ok {
+		return nil, &NoSubscriptionError{id: id}
+	}
+	return clone(sub), nil
+}
+
+// List returns the subscriptions of workspace or, if it is empty, the
+// personal subscriptions of owner, oldest first.
+func (s *Store) List(owner, workspace string) []Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var subs []Subscription
+	for _, sub := range s.subs {
+		if sub.Workspace == workspace && (workspace != "" || sub.Owner == owner) {
+			subs = append(subs, *clone(sub))
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].CreatedAt.Before(subs[j].CreatedAt)
+	})
+	return subs
+}
+
+// Delete removes the subscription together with its deliveries.
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subs[id]; !ok {
+		return &NoSubscriptionError{id: id}
+	}
+	delete(s.subs, id)
+	delete(s.deliveries, id)
+	delete(s.dead, id)
+	return nil
+}
+
+// matching returns the subscriptions that want e.
+func (s *Store) matching(e events.Event) []Subscription {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var subs []Subscription
+	for _, sub := range s.subs {
+		if sub.Wants(e) {
+			subs = append(subs, *clone(sub))
+		}
+	}
+	return subs
+}
+
+// Deliveries returns the last deliveries of the subscription, newest first.
+func (s *Store) Deliveries(id string) ([]Delivery, error) {
+	return s.list(s.deliveries, id)
+}
+
+// DeadLetters returns the deliveries of the subscription that failed,
+// newest first.
+func (s *Store) DeadLetters(id string) ([]Delivery, error) {
+	return s.list(s.dead, id)
+}
+
+func (s *Store) list(lists map[string][]*Delivery, id string) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subs[id]; !ok {
+		return nil, &NoSubscriptionError{id: id}
+	}
+	ds := make([]Delivery, 0, len(lists[id]))
+	for i := len(lists[id]) - 1; i >= 0; i-- {
+		ds = append(ds, lists[id][i].clone())
+	}
+	return ds, nil
+}
+
+// addDelivery records a new delivery in the log of its subscription.
+func (s *Store) addDelivery(d *Delivery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries[d.SubscriptionID] = appendBounded(s.deliveries[d.SubscriptionID], d, maxLog)
+}
+
+// addAttempt records an attempt of d and its new state. A failed delivery
+// moves to the dead-letter list.
+func (s *Store) addAttempt(d *Delivery, a Attempt, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d.Attempts = append(d.Attempts, a)
+	d.State = state
+	if state == StateFailed {
+		if _, ok := s.subs[d.SubscriptionID]; ok {
+			s.dead[d.SubscriptionID] = appendBounded(s.dead[d.SubscriptionID], d, maxDead)
+		}
+	}
+}
+
+// takeDeadLetter removes a delivery from the dead-letter list to send it
+// again. It is logged once more as a pending delivery.
+func (s *Store) takeDeadLetter(subID, id string) (*Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.subs[subID]; !ok {
+		return nil, &NoSubscriptionError{id: subID}
+	}
+	dead := s.dead[subID]
+	for i, d := range dead {
+		if d.ID == id {
+			s.dead[subID] = slices.Delete(dead, i, i+1)
+			d.State = StatePending
+			if !slices.Contains(s.deliveries[subID], d) {
+				s.deliveries[subID] = appendBounded(s.deliveries[subID], d, maxLog)
+			}
+			return d, nil
+		}
+	}
+	return nil, &NoDeliveryError{id: id}
+}
+
+// restoreDeadLetter puts back a delivery taken with takeDeadLetter that
+// could not be sent again.
+func (s *Store) restoreDeadLetter(d *Delivery) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d.State = StateFailed
+	if _, ok := s.subs[d.SubscriptionID]; ok {
+		s.dead[d.SubscriptionID] = appendBounded(s.dead[d.SubscriptionID], d, maxDead)
+	}
+}
+
+func appendBounded(list []*Delivery, d *Delivery, limit int) []*Delivery {
+	list = append(list, d)
+	if len(list) > limit {
+		list = slices.Delete(list, 0, len(list)-limit)
+	}
+	return list
+}
+
+func clone(sub *Subscription) *Subscription {
+	c := *sub
+	c.Events = append([]string(nil), sub.Events...)
+	return &c
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
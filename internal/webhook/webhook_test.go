@@ -0,0 +1,222 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/adettelle/go-url-shortener/internal/events"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":"evt_1"}`)
+	now := time.Now()
+	header := Sign("whsec_test", now, body)
+	require.NoError(t, Verify("whsec_test", header, body, time.Minute))
+
+	require.Error(t, Verify("whsec_other", header, body, time.Minute))
+	require.Error(t, Verify("whsec_test", header, []byte(`{"id":"evt_2"}`), time.Minute))
+	require.Error(t, Verify("whsec_test", Sign("whsec_test", now.Add(-time.Hour), body), body, time.Minute))
+	require.Error(t, Verify("whsec_test", "v1=abc", body, time.Minute))
+}
+
+func TestSubscriptionWants(t *testing.T) {
+	personal := Subscription{Owner: "alice"}
+	team := Subscription{Owner: "alice", Workspace: "ws_1", Events: []string{events.LinkClicked}}
+
+	for _, tc := range []struct {
+		e              events.Event
+		personal, team bool
+	}{
+		{events.Event{Type: events.LinkCreated, Owner: "alice"}, true, false},
+		{events.Event{Type: events.LinkClicked, Owner: "bob"}, false, false},
+		{events.Event{Type: events.LinkClicked, Owner: "bob", Workspace: "ws_1"}, false, true},
+		{events.Event{Type: events.LinkCreated, Owner: "alice", Workspace: "ws_1"}, false, false},
+	} {
+		require.Equal(t, tc.personal, personal.Wants(tc.e), tc.e)
+		require.Equal(t, tc.team, team.Wants(tc.e), tc.e)
+	}
+}
+
+func TestStore(t *testing.T) {
+	store := NewStore()
+	_, err := store.Create("ftp://example.com/hook", nil, "alice", "")
+	require.IsType(t, &InvalidSubscriptionError{}, err)
+	_, err = store.Create("https://example.com/hook", []string{"link.renamed"}, "alice", "")
+	require.IsType(t, &InvalidSubscriptionError{}, err)
+
+	sub, err := store.Create("https://example.com/hook", []string{events.LinkCreated, events.LinkCreated}, "alice", "")
+	require.NoError(t, err)
+	require.Equal(t, []string{events.LinkCreated}, sub.Events)
+	_, err = store.Create("https://example.com/team", nil, "alice", "ws_1")
+	require.NoError(t, err)
+
+	require.Equal(t, []Subscription{*sub}, store.List("alice", ""))
+	require.Empty(t, store.List("bob", ""))
+	require.Len(t, store.List("bob", "ws_1"), 1)
+
+	require.NoError(t, store.Delete(sub.ID))
+	_, err = store.Get(sub.ID)
+	require.Equal(t, &NoSubscriptionError{id: sub.ID}, err)
+}
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+func newTestDispatcher(t *testing.T, maxAttempts int, handler http.HandlerFunc) (*Dispatcher, *Subscription, *events.Bus) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	store := NewStore()
+	sub, err := store.Create(server.URL, nil, "alice", "")
+	require.NoError(t, err)
+	dispatcher := NewDispatcher(store, server.Client(), maxAttempts, time.Millisecond, 5*time.Millisecond, 2)
+
+	bus := events.NewBus()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go dispatcher.Run(ctx, bus.Subscribe(10))
+	return dispatcher, sub, bus
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	var calls atomic.Int32
+	requests := make(chan received, 10)
+	dispatcher, sub, bus := newTestDispatcher(t, 5, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- received{header: r.Header, body: body}
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+
+	// событие чужой ссылки подписчику не отправляется
+	bus.Publish(events.Event{Type: events.LinkCreated, Owner: "bob"})
+	bus.Publish(events.Event{Type: events.LinkCreated, Owner: "alice", Actor: "alice", Data: map[string]string{"id": "deck"}})
+
+	require.Eventually(t, func() bool {
+		ds, err := dispatcher.Deliveries(sub.ID)
+		return err == nil && len(ds) == 1 && ds[0].State == StateDelivered
+	}, time.Second, 5*time.Millisecond)
+	ds, err := dispatcher.Deliveries(sub.ID)
+	require.NoError(t, err)
+	require.Len(t, ds[0].Attempts, 3)
+	require.Equal(t, http.StatusServiceUnavailable, ds[0].Attempts[0].Status)
+	require.Equal(t, http.StatusOK, ds[0].Attempts[2].Status)
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		r := <-requests
+		require.NoError(t, Verify(sub.Secret, r.header.Get(HeaderSignature), r.body, time.Minute))
+		require.Equal(t, events.LinkCreated, r.header.Get(HeaderEvent))
+		ids = append(ids, r.header.Get(HeaderID))
+
+		var p map[string]any
+		require.NoError(t, json.Unmarshal(r.body, &p))
+		require.Equal(t, events.LinkCreated, p["type"])
+		require.Equal(t, "alice", p["actor"])
+		require.Equal(t, map[string]any{"id": "deck"}, p["data"])
+	}
+	require.Equal(t, []string{ds[0].ID, ds[0].ID, ds[0].ID}, ids)
+
+	dead, err := dispatcher.DeadLetters(sub.ID)
+	require.NoError(t, err)
+	require.Empty(t, dead)
+}
+
+func TestDispatcherDeadLetters(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusInternalServerError)
+	var calls atomic.Int32
+	dispatcher, sub, bus := newTestDispatcher(t, 3, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(int(status.Load()))
+	})
+
+	bus.Publish(events.Event{Type: events.LinkDeleted, Owner: "alice"})
+	require.Eventually(t, func() bool {
+		dead, err := dispatcher.DeadLetters(sub.ID)
+		return err == nil && len(dead) == 1
+	}, time.Second, 5*time.Millisecond)
+	require.Equal(t, int32(3), calls.Load())
+
+	dead, err := dispatcher.DeadLetters(sub.ID)
+	require.NoError(t, err)
+	require.Equal(t, StateFailed, dead[0].State)
+	require.Equal(t, "webhook answered 500", dead[0].Attempts[2].Error)
+
+	// получатель починился, событие можно отправить ещё раз
+	status.Store(http.StatusNoContent)
+	require.NoError(t, dispatcher.Redeliver(sub.ID, dead[0].ID))
+	require.Eventually(t, func() bool {
+		ds, err := dispatcher.Deliveries(sub.ID)
+		return err == nil && ds[0].State == StateDelivered
+	}, time.Second, 5*time.Millisecond)
+	ds, err := dispatcher.Deliveries(sub.ID)
+	require.NoError(t, err)
+	require.Len(t, ds, 1)
+	require.Len(t, ds[0].Attempts, 4)
+	dead, err = dispatcher.DeadLetters(sub.ID)
+	require.NoError(t, err)
+	require.Empty(t, dead)
+
+	require.Equal(t, &NoDeliveryError{id: ds[0].ID}, dispatcher.Redeliver(sub.ID, ds[0].ID))
+
+	// ошибка клиента не повторяется
+	status.Store(http.StatusGone)
+	calls.Store(0)
+	bus.Publish(events.Event{Type: events.LinkDeleted, Owner: "alice"})
+	require.Eventually(t, func() bool {
+		dead, err := dispatcher.DeadLetters(sub.ID)
+		return err == nil && len(dead) == 1
+	}, time.Second, 5*time.Millisecond)
+	require.Equal(t, int32(1), calls.Load())
+}
+
+func TestRedeliverUnavailable(t *testing.T) {
+	store := NewStore()
+	sub, err := store.Create("https://example.com/hook", nil, "alice", "")
+	require.NoError(t, err)
+	delivery := &Delivery{ID: "dl_1", SubscriptionID: sub.ID, State: StatePending}
+	store.addDelivery(delivery)
+	store.addAttempt(delivery, Attempt{Error: "webhook answered 500"}, StateFailed)
+	dispatcher := NewDispatcher(store, http.DefaultClient, 3, time.Millisecond, time.Millisecond, 1)
+
+	requireDeadLetter := func() {
+		t.Helper()
+		dead, err := dispatcher.DeadLetters(sub.ID)
+		require.NoError(t, err)
+		require.Len(t, dead, 1)
+		require.Equal(t, "dl_1", dead[0].ID)
+		require.Equal(t, StateFailed, dead[0].State)
+	}
+
+	// Run не запущен, забирать повторные отправки некому
+	require.Equal(t, &RedeliveryUnavailableError{}, dispatcher.Redeliver(sub.ID, "dl_1"))
+	requireDeadLetter()
+
+	// очередь полна: Redeliver не ждёт, доставка остаётся в списке недоставленных
+	dispatcher.running.Store(true)
+	for range redeliverQueueSize {
+		dispatcher.redeliver <- &Delivery{}
+	}
+	require.Equal(t, &RedeliveryUnavailableError{}, dispatcher.Redeliver(sub.ID, "dl_1"))
+	requireDeadLetter()
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(NewStore(), http.DefaultClient, 10, time.Second, time.Minute, 1)
+	for n, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 9: time.Minute} {
+		delay := d.backoff(n)
+		require.GreaterOrEqual(t, delay, want*4/5, n)
+		require.LessOrEqual(t, delay, want*6/5, n)
+	}
+}
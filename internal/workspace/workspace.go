@@ -0,0 +1,271 @@
+// Package workspace keeps the teams that own links together. Every member
+// of a workspace may manage its links; owners also manage the membership.
+package workspace
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	idPrefix      = "ws_"
+	idBytes       = 6
+	maxNameLength = 100
+)
+
+// Role is the role of a member inside one workspace.
+type Role string
+
+const (
+	RoleMember Role = "member" // manages the links of the workspace
+	RoleOwner  Role = "owner"  // also adds and removes members
+)
+
+// Member is a user or an API key belonging to a workspace, see auth.Principal.Actor.
+type Member struct {
+	Actor   string
+	Role    Role
+	AddedAt time.Time
+}
+
+// Workspace is a team that owns links.
+type Workspace struct {
+	ID        string
+	Name      string
+	CreatedAt time.Time
+	CreatedBy string
+	Members   []Member // sorted by actor
+}
+
+// RoleOf returns the role of actor in the workspace and false if actor
+// is not a member. It is safe to call on a nil workspace.
+func (ws *Workspace) RoleOf(actor string) (Role, bool) {
+	if ws == nil || actor == "" {
+		return "", false
+	}
+	for _, m := range ws.Members {
+		if m.Actor == actor {
+			return m.Role, true
+		}
+	}
+	return "", false
+}
+
+// IsMember reports whether actor belongs to the workspace.
+func (ws *Workspace) IsMember(actor string) bool {
+	_, ok := ws.RoleOf(actor)
+	return ok
+}
+
+// IsOwner reports whether actor owns the workspace.
+func (ws *Workspace) IsOwner(actor string) bool {
+	role, _ := ws.RoleOf(actor)
+	return role == RoleOwner
+}
+
+func (ws *Workspace) clone() *Workspace {
+	wsCopy := *ws
+	wsCopy.Members = append([]Member(nil), ws.Members...)
+	return &wsCopy
+}
+
+type NoWorkspaceError struct {
+	id string
+}
+
+func (e *NoWorkspaceError) Error() string {
+	return fmt.Sprintf("no workspace %s", e.id)
+}
+
+type NoMemberError struct {
+	id    string
+	actor string
+}
+
+func (e *NoMemberError) Error() string {
+	return fmt.Sprintf("%s is not a member of workspace %s", e.actor, e.id)
+}
+
+// LastOwnerError is returned when a change would leave a workspace without owners.
+type LastOwnerError struct {
+	id string
+}
+
+func (e *LastOwnerError) Error() string {
+	return fmt.Sprintf("workspace %s must keep at least one owner", e.id)
+}
+
+type InvalidNameError struct {
+	name string
+}
+
+func (e *InvalidNameError) Error() string {
+	return fmt.Sprintf("invalid workspace name %q", e.name)
+}
+
+type InvalidMemberError struct {
+	reason string
+}
+
+func (e *InvalidMemberError) Error() string {
+	return "invalid member: " + e.reason
+}
+
+// Store keeps workspaces in memory.
+type Store struct {
+	mu         sync.RWMutex
+	workspaces map[string]*Workspace
+	now        func() time.Time
+}
+
+func NewStore() *Store {
+	return &Store{workspaces: make(map[string]*Workspace), now: time.Now}
+}
+
+// Create makes a workspace, creator becomes its first owner.
+func (s *Store) Create(name, creator string) (*Workspace, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxNameLength {
+		return nil, &InvalidNameError{name: name}
+	}
+	if creator == "" {
+		return nil, &InvalidMemberError{reason: "anonymous callers can't own workspaces"}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var id string
+	for {
+		var err error
+		id, err = randomID()
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := s.workspaces[id]; !exists {
+			break
+		}
+	}
+
+	now := s.now()
+	ws := &Workspace{
+		ID:        id,
+		Name:      name,
+		CreatedAt: now,
+		CreatedBy: creator,
+		Members:   []Member{{Actor: creator, Role: RoleOwner, AddedAt: now}},
+	}
+	s.workspaces[id] = ws
+	return ws.clone(), nil
+}
+
+// Get returns a copy of the workspace.
+func (s *Store) Get(id string) (*Workspace, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ws, ok := s.workspaces[id]
+	if !ok {
+		return nil, &NoWorkspaceError{id: id}
+	}
+	return ws.clone(), nil
+}
+
+// ListFor returns the workspaces actor is a member of, ordered by name.
+func (s *Store) ListFor(actor string) []*Workspace {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var list []*Workspace
+	for _, ws := range s.workspaces {
+		if ws.IsMember(actor) {
+			list = append(list, ws.clone())
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].ID < list[j].ID
+	})
+	return list
+}
+
+// SetMember adds actor to the workspace or changes the role of a member.
+func (s *Store) SetMember(id, actor string, role Role) (*Workspace, error) {
+	if actor == "" {
+		return nil, &InvalidMemberError{reason: "empty member"}
+	}
+	if role != RoleMember && role != RoleOwner {
+		return nil, &InvalidMemberError{reason: fmt.Sprintf("unknown role %q", role)}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ws, ok := s.workspaces[id]
+	if !ok {
+		return nil, &NoWorkspaceError{id: id}
+	}
+	for i, m := range ws.Members {
+		if m.Actor != actor {
+			continue
+		}
+		if m.Role == RoleOwner && role != RoleOwner && ws.owners() == 1 {
+			return nil, &LastOwnerError{id: id}
+		}
+		ws.Members[i].Role = role
+		return ws.clone(), nil
+	}
+
+	ws.Members = append(ws.Members, Member{Actor: actor, Role: role, AddedAt: s.now()})
+	sort.Slice(ws.Members, func(i, j int) bool {
+		return ws.Members[i].Actor < ws.Members[j].Actor
+	})
+	return ws.clone(), nil
+}
+
+// RemoveMember takes actor out of the workspace. The last owner can't be removed.
+func (s *Store) RemoveMember(id, actor string) (*Workspace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ws, ok := s.workspaces[id]
+	if !ok {
+		return nil, &NoWorkspaceError{id: id}
+	}
+	for i, m := range ws.Members {
+		if m.Actor != actor {
+			continue
+		}
+		if m.Role == RoleOwner && ws.owners() == 1 {
+			return nil, &LastOwnerError{id: id}
+		}
+		ws.Members = append(ws.Members[:i], ws.Members[i+1:]...)
+		return ws.clone(), nil
+	}
+	return nil, &NoMemberError{id: id, actor: actor}
+}
+
+func (ws *Workspace) owners() int {
+	n := 0
+	for _, m := range ws.Members {
+		if m.Role == RoleOwner {
+			n++
+		}
+	}
+	return n
+}
+
+func randomID() (string, error) {
+	b := make([]byte, idBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return idPrefix + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,76 @@
+package workspace
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreate(t *testing.T) {
+	s := NewStore()
+
+	ws, err := s.Create("  Marketing ", "alice")
+	require.NoError(t, err)
+	require.Equal(t, "Marketing", ws.Name)
+	require.True(t, ws.IsOwner("alice"))
+
+	got, err := s.Get(ws.ID)
+	require.NoError(t, err)
+	require.Equal(t, ws, got)
+
+	_, err = s.Create(" ", "alice")
+	require.ErrorAs(t, err, new(*InvalidNameError))
+	_, err = s.Create("Team", "")
+	require.ErrorAs(t, err, new(*InvalidMemberError))
+	_, err = s.Get("ws_nope")
+	require.ErrorAs(t, err, new(*NoWorkspaceError))
+}
+
+func TestMembership(t *testing.T) {
+	s := NewStore()
+	ws, err := s.Create("Team", "alice")
+	require.NoError(t, err)
+
+	ws, err = s.SetMember(ws.ID, "bob", RoleMember)
+	require.NoError(t, err)
+	require.True(t, ws.IsMember("bob"))
+	require.False(t, ws.IsOwner("bob"))
+	require.Equal(t, []string{"Team"}, names(s.ListFor("bob")))
+	require.Empty(t, s.ListFor("carol"))
+
+	// единственный владелец не может уйти или стать участником
+	_, err = s.SetMember(ws.ID, "alice", RoleMember)
+	require.ErrorAs(t, err, new(*LastOwnerError))
+	_, err = s.RemoveMember(ws.ID, "alice")
+	require.ErrorAs(t, err, new(*LastOwnerError))
+
+	_, err = s.SetMember(ws.ID, "bob", RoleOwner)
+	require.NoError(t, err)
+	ws, err = s.RemoveMember(ws.ID, "alice")
+	require.NoError(t, err)
+	require.False(t, ws.IsMember("alice"))
+
+	_, err = s.RemoveMember(ws.ID, "alice")
+	require.ErrorAs(t, err, new(*NoMemberError))
+	_, err = s.SetMember(ws.ID, "carol", Role("guest"))
+	require.ErrorAs(t, err, new(*InvalidMemberError))
+}
+
+func TestGetReturnsCopy(t *testing.T) {
+	s := NewStore()
+	ws, err := s.Create("Team", "alice")
+	require.NoError(t, err)
+
+	ws.Members[0].Role = RoleMember
+	got, err := s.Get(ws.ID)
+	require.NoError(t, err)
+	require.True(t, got.IsOwner("alice"))
+}
+
+func names(list []*Workspace) []string {
+	var n []string
+	for _, ws := range list {
+		n = append(n, ws.Name)
+	}
+	return n
+}